@@ -0,0 +1,322 @@
+package processctrl
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// Controller is the set of control operations shared by processes started
+// with New and processes attached to with Attach.
+type Controller interface {
+	Pause() error
+	Resume() error
+	Signal(sig os.Signal) error
+	// Terminate and Kill send SIGTERM and force termination if the
+	// process does not exit within the default timeout.
+	Terminate() error
+	Kill() error
+	// KillWithTimeout forces termination at once.
+	KillWithTimeout(timeout time.Duration) error
+	IsRunning() bool
+	IsPaused() bool
+	PID() int
+	Done() <-chan struct{}
+	Events() <-chan Event
+}
+
+var (
+	_ Controller = (*Process)(nil)
+	_ Controller = (*AttachedProcess)(nil)
+)
+
+// ProcessInfo contains metadata about a running process.
+type ProcessInfo struct {
+	PID        int       // Process ID
+	PPID       int       // Parent process ID
+	Name       string    // Command name as reported by the kernel
+	Cmdline    []string  // Command line arguments, including the program
+	Executable string    // Path of the executable, if readable
+	Dir        string    // Current working directory, if readable
+	State      string    // Kernel scheduling state (e.g. "R", "S", "T")
+	StartTime  time.Time // Time the process was started
+	UID        int       // Real user ID
+	GID        int       // Real group ID
+}
+
+// AttachedProcess is a handle to a process that was not started by
+// processctrl, such as a job launched by cron or a service manager.
+// It supports the same control operations as Process, but provides no
+// access to the process's standard streams or exit status.
+type AttachedProcess struct {
+	pid           int
+	mu            sync.RWMutex
+	paused        bool
+	detached      bool
+	transitioning bool // Pause or Resume is waiting with a.mu released
+	exited        chan struct{}
+	exitOnce      sync.Once
+	stop          chan struct{}
+	handle        attachHandle
+	events        *eventEmitter
+}
+
+// Attach returns a handle for controlling the already-running process
+// with the given process ID.
+//
+// On Linux the handle is backed by a pidfd, so it keeps referring to the
+// same process even if the PID is later reused. On kernels without pidfd
+// support the process start time is recorded instead and verified before
+// every signal. macOS has no pidfds and always uses the start time from the
+// kern.proc.pid sysctl; a zombie counts as exited there.
+//
+// Parameters:
+//   - pid: The process ID to attach to
+//
+// Returns an error if the process does not exist or cannot be accessed.
+func Attach(pid int) (*AttachedProcess, error) {
+	if pid <= 0 {
+		return nil, fmt.Errorf("invalid process ID: %d", pid)
+	}
+
+	a := &AttachedProcess{
+		pid:    pid,
+		exited: make(chan struct{}),
+		stop:   make(chan struct{}),
+		events: newEventEmitter(),
+	}
+
+	if err := a.openImpl(); err != nil {
+		return nil, fmt.Errorf("failed to attach to process %d: %w", pid, err)
+	}
+	a.watchImpl()
+	a.watchState()
+
+	return a, nil
+}
+
+// markExited records that the attached process has exited.
+func (a *AttachedProcess) markExited() {
+	a.exitOnce.Do(func() {
+		close(a.exited)
+		a.events.emit(EventExited, a.pid, "")
+		a.events.close()
+	})
+}
+
+// checkRunning returns an error if the process has exited or the handle
+// has been detached. It must be called with a.mu held.
+func (a *AttachedProcess) checkRunning() error {
+	if a.detached {
+		return fmt.Errorf("process is detached")
+	}
+	select {
+	case <-a.exited:
+		return fmt.Errorf("process is not running")
+	default:
+		return nil
+	}
+}
+
+// waitUnlocked runs wait with a.mu released, so that a Pause or Resume
+// waiting for the process to confirm the transition does not block other
+// operations. Further Pause and Resume calls are rejected meanwhile.
+// It must be called with a.mu held and reports an error if the process
+// exited or the handle was detached during the wait.
+func (a *AttachedProcess) waitUnlocked(wait func() error) error {
+	a.transitioning = true
+	a.mu.Unlock()
+	err := wait()
+	a.mu.Lock()
+	a.transitioning = false
+
+	if err != nil {
+		return err
+	}
+	return a.checkRunning()
+}
+
+// Pause suspends the attached process.
+// See Process.Pause for the platform-specific mechanism used.
+//
+// Returns an error if:
+//   - The process is not running or the handle is detached
+//   - The process is already paused
+//   - The platform-specific pause operation fails
+func (a *AttachedProcess) Pause() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if err := a.checkRunning(); err != nil {
+		return err
+	}
+	if a.paused {
+		return fmt.Errorf("process not running or already paused")
+	}
+	if a.transitioning {
+		return fmt.Errorf("pause or resume already in progress")
+	}
+
+	if err := a.pauseImpl(); err != nil {
+		return err
+	}
+
+	a.paused = true
+	a.events.emit(EventPaused, a.pid, "")
+	return nil
+}
+
+// Resume continues the execution of an attached process paused with Pause.
+//
+// Returns an error if:
+//   - The process is not running or the handle is detached
+//   - The process is not currently paused
+//   - The platform-specific resume operation fails
+func (a *AttachedProcess) Resume() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if err := a.checkRunning(); err != nil {
+		return err
+	}
+	if !a.paused {
+		return fmt.Errorf("process not running or not paused")
+	}
+	if a.transitioning {
+		return fmt.Errorf("pause or resume already in progress")
+	}
+
+	if err := a.resumeImpl(); err != nil {
+		return err
+	}
+
+	a.paused = false
+	a.events.emit(EventResumed, a.pid, "")
+	return nil
+}
+
+// Signal sends a signal to the attached process.
+// On Windows only os.Kill is supported.
+//
+// Returns an error if the process is not running, the handle is detached
+// or the signal cannot be delivered.
+func (a *AttachedProcess) Signal(sig os.Signal) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if err := a.checkRunning(); err != nil {
+		return err
+	}
+	return a.signalImpl(sig)
+}
+
+// Terminate attempts to gracefully stop the attached process, forcing
+// termination if it does not exit within the default timeout.
+// A paused process is continued after the termination request so that it
+// can handle it.
+//
+// Returns an error if the operation fails.
+func (a *AttachedProcess) Terminate() error {
+	return a.killWithSignal(defaultKillTimeout, true)
+}
+
+// KillWithTimeout terminates the attached process with a custom timeout,
+// the same way as Process.KillWithTimeout.
+//
+// Parameters:
+//   - timeout: Maximum time to wait before force-killing the process
+//
+// Returns an error if the operation fails.
+func (a *AttachedProcess) KillWithTimeout(timeout time.Duration) error {
+	return a.killWithSignal(timeout, false)
+}
+
+// Kill terminates the attached process the same way as Process.Kill.
+//
+// Returns an error if the process is not running or termination fails.
+func (a *AttachedProcess) Kill() error {
+	return a.killWithSignal(defaultKillTimeout, true)
+}
+
+// killWithSignal delegates termination to the platform-specific
+// implementation and clears the paused state on success.
+func (a *AttachedProcess) killWithSignal(timeout time.Duration, graceful bool) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if err := a.checkRunning(); err != nil {
+		return err
+	}
+
+	err := a.killWithSignalImpl(timeout, graceful)
+	if err == nil {
+		a.paused = false
+	}
+	return err
+}
+
+// IsRunning returns true if the attached process has not exited and the
+// handle has not been detached.
+func (a *AttachedProcess) IsRunning() bool {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.checkRunning() == nil
+}
+
+// IsPaused returns true if the attached process is paused. On Linux the
+// state is reconciled with the kernel, so stops and continues caused
+// outside of processctrl are reflected as well.
+func (a *AttachedProcess) IsPaused() bool {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.paused
+}
+
+// PID returns the process ID of the attached process.
+func (a *AttachedProcess) PID() int {
+	return a.pid
+}
+
+// Done returns a channel that is closed when the attached process exits.
+// The channel is not closed by Detach.
+func (a *AttachedProcess) Done() <-chan struct{} {
+	return a.exited
+}
+
+// Events returns a channel that receives state changes of the attached
+// process. The channel is buffered; events are dropped when the buffer is
+// full. It is closed when the process exits or the handle is detached.
+func (a *AttachedProcess) Events() <-chan Event {
+	return a.events.ch
+}
+
+// Info returns metadata about the attached process.
+// Only Linux is supported; other platforms return ErrNotSupported.
+func (a *AttachedProcess) Info() (*ProcessInfo, error) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	if err := a.checkRunning(); err != nil {
+		return nil, err
+	}
+	return a.infoImpl()
+}
+
+// Detach releases the handle without affecting the process.
+// A paused process stays paused. All further control operations fail.
+//
+// Returns an error if the handle is already detached.
+func (a *AttachedProcess) Detach() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.detached {
+		return fmt.Errorf("process is detached")
+	}
+	a.detached = true
+	close(a.stop)
+	a.closeImpl()
+	a.events.close()
+	return nil
+}
@@ -0,0 +1,115 @@
+//go:build darwin
+
+// Package processctrl macOS attach implementation
+//
+// This file contains the macOS-specific handle for attached processes.
+// macOS has no pidfds, so processes are signaled by PID and their liveness
+// is checked periodically. The process start time from the kern.proc.pid
+// sysctl is used to detect PID reuse, and zombies count as exited.
+
+package processctrl
+
+import (
+	"os"
+	"syscall"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// Process states reported in kinfo_proc.kp_proc.p_stat.
+const (
+	darwinStateStopped = 4 // SSTOP
+	darwinStateZombie  = 5 // SZOMB
+)
+
+// attachHandle holds the macOS-specific state of an attached process.
+type attachHandle struct {
+	startTime unix.Timeval  // start time of the process
+	watchDone chan struct{} // closed when the exit watcher returns
+}
+
+// readKinfoProc returns the kernel process information for pid.
+// A missing process is reported as os.ErrProcessDone.
+func readKinfoProc(pid int) (*unix.KinfoProc, error) {
+	info, err := unix.SysctlKinfoProc("kern.proc.pid", pid)
+	if err != nil {
+		// The sysctl returns no data, reported as EIO, for unknown PIDs
+		if err == unix.EIO || err == unix.ESRCH {
+			return nil, os.ErrProcessDone
+		}
+		return nil, err
+	}
+	if info.Proc.P_pid != int32(pid) {
+		return nil, os.ErrProcessDone
+	}
+	return info, nil
+}
+
+// openImpl checks that the process exists and records its start time.
+func (a *AttachedProcess) openImpl() error {
+	info, err := readKinfoProc(a.pid)
+	if err != nil {
+		return err
+	}
+	if info.Proc.P_stat == darwinStateZombie {
+		return os.ErrProcessDone
+	}
+	a.handle.startTime = info.Proc.P_starttime
+	a.paused = info.Proc.P_stat == darwinStateStopped
+	return nil
+}
+
+// verifyStartTime checks that the PID still refers to the process that
+// was attached to.
+func (a *AttachedProcess) verifyStartTime() error {
+	info, err := readKinfoProc(a.pid)
+	if err != nil || info.Proc.P_starttime != a.handle.startTime || info.Proc.P_stat == darwinStateZombie {
+		return os.ErrProcessDone
+	}
+	return nil
+}
+
+// watchImpl starts a goroutine that closes the exited channel when the
+// process no longer exists, has become a zombie or its PID was reused.
+func (a *AttachedProcess) watchImpl() {
+	a.handle.watchDone = make(chan struct{})
+
+	go func() {
+		defer close(a.handle.watchDone)
+
+		for {
+			select {
+			case <-a.stop:
+				return
+			case <-time.After(attachPollInterval):
+			}
+			if a.verifyStartTime() != nil {
+				a.markExited()
+				return
+			}
+		}
+	}()
+}
+
+// closeImpl stops the exit watcher.
+// It must be called with a.mu held.
+func (a *AttachedProcess) closeImpl() {
+	if a.handle.watchDone != nil {
+		<-a.handle.watchDone
+	}
+}
+
+// sendSignal delivers sig to the process by PID after verifying the
+// start time.
+func (a *AttachedProcess) sendSignal(sig syscall.Signal) error {
+	if err := a.verifyStartTime(); err != nil {
+		return err
+	}
+	return mapESRCH(syscall.Kill(a.pid, sig))
+}
+
+// infoImpl is not supported on macOS.
+func (a *AttachedProcess) infoImpl() (*ProcessInfo, error) {
+	return nil, ErrNotSupported
+}
@@ -0,0 +1,148 @@
+//go:build linux
+
+// Package processctrl Linux attach implementation
+//
+// This file contains the Linux-specific handle for attached processes.
+// The handle is a pidfd obtained with pidfd_open. On kernels without pidfd
+// support the process start time from /proc is used to detect PID reuse.
+
+package processctrl
+
+import (
+	"errors"
+	"os"
+	"syscall"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// attachHandle holds the Linux-specific state of an attached process.
+type attachHandle struct {
+	pidfd     int           // pidfd of the process, -1 if unavailable
+	startTime uint64        // start time in clock ticks since boot
+	watchDone chan struct{} // closed when the exit watcher returns
+}
+
+// openImpl opens a pidfd for the process and records its start time.
+func (a *AttachedProcess) openImpl() error {
+	a.handle.pidfd = -1
+
+	st, err := readProcStat(a.pid)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return os.ErrProcessDone
+		}
+		return err
+	}
+	a.handle.startTime = st.startTime
+	a.paused = st.state == 'T' || st.state == 't'
+
+	fd, err := unix.PidfdOpen(a.pid, 0)
+	switch {
+	case err == nil:
+		a.handle.pidfd = fd
+	case errors.Is(err, unix.ESRCH):
+		return os.ErrProcessDone
+	case errors.Is(err, unix.ENOSYS):
+		// Fall back to PID-based signaling guarded by the start time
+	default:
+		return err
+	}
+
+	// The PID could have been reused between reading the stat file and
+	// opening the pidfd, so verify the start time again.
+	if err := a.verifyStartTime(); err != nil {
+		a.closeImpl()
+		return err
+	}
+	return nil
+}
+
+// verifyStartTime checks that the PID still refers to the process that
+// was attached to.
+func (a *AttachedProcess) verifyStartTime() error {
+	st, err := readProcStat(a.pid)
+	if err != nil || st.startTime != a.handle.startTime || st.state == 'Z' || st.state == 'X' {
+		return os.ErrProcessDone
+	}
+	return nil
+}
+
+// watchImpl starts a goroutine that closes the exited channel when the
+// process exits. With a pidfd the exit is detected by polling the pidfd;
+// otherwise /proc is checked periodically.
+func (a *AttachedProcess) watchImpl() {
+	a.handle.watchDone = make(chan struct{})
+	fd := a.handle.pidfd
+
+	go func() {
+		defer close(a.handle.watchDone)
+
+		for {
+			var exited bool
+			if fd >= 0 {
+				var err error
+				exited, err = pollPidfd(fd, attachPollInterval)
+				if err != nil {
+					return
+				}
+			} else {
+				exited = a.verifyStartTime() != nil
+			}
+
+			if exited {
+				a.markExited()
+				return
+			}
+
+			if fd >= 0 {
+				// pollPidfd already waited for the poll interval
+				select {
+				case <-a.stop:
+					return
+				default:
+				}
+				continue
+			}
+
+			select {
+			case <-a.stop:
+				return
+			case <-time.After(attachPollInterval):
+			}
+		}
+	}()
+}
+
+// closeImpl stops the exit watcher and releases the pidfd.
+// It must be called with a.mu held.
+func (a *AttachedProcess) closeImpl() {
+	if a.handle.watchDone != nil {
+		<-a.handle.watchDone
+	}
+	if a.handle.pidfd >= 0 {
+		_ = unix.Close(a.handle.pidfd) // Ignore error during cleanup
+		a.handle.pidfd = -1
+	}
+}
+
+// sendSignal delivers sig through the pidfd, or by PID after verifying
+// the start time when no pidfd is available.
+func (a *AttachedProcess) sendSignal(sig syscall.Signal) error {
+	if a.handle.pidfd < 0 {
+		if err := a.verifyStartTime(); err != nil {
+			return err
+		}
+		return mapESRCH(syscall.Kill(a.pid, sig))
+	}
+	return mapESRCH(unix.PidfdSendSignal(a.handle.pidfd, sig, nil, 0))
+}
+
+// infoImpl reads the process metadata from /proc.
+func (a *AttachedProcess) infoImpl() (*ProcessInfo, error) {
+	if err := a.verifyStartTime(); err != nil {
+		return nil, err
+	}
+	return readProcInfo(a.pid)
+}
@@ -0,0 +1,78 @@
+//go:build linux || darwin
+
+// Package processctrl Unix attach implementation
+//
+// This file contains the signal-based control operations for attached
+// processes shared by Linux and macOS.
+
+package processctrl
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"syscall"
+	"time"
+)
+
+// attachPollInterval is how often the liveness of an attached process is
+// checked when the kernel cannot notify us of its exit directly.
+const attachPollInterval = 250 * time.Millisecond
+
+// mapESRCH converts ESRCH to os.ErrProcessDone, matching os.Process.
+func mapESRCH(err error) error {
+	if errors.Is(err, syscall.ESRCH) {
+		return os.ErrProcessDone
+	}
+	return err
+}
+
+// pauseImpl suspends the attached process using SIGSTOP.
+func (a *AttachedProcess) pauseImpl() error {
+	if err := a.sendSignal(syscall.SIGSTOP); err != nil {
+		return fmt.Errorf("failed to pause process: %w", err)
+	}
+	return a.confirmStopped(true)
+}
+
+// resumeImpl continues the attached process using SIGCONT.
+func (a *AttachedProcess) resumeImpl() error {
+	if err := a.sendSignal(syscall.SIGCONT); err != nil {
+		return fmt.Errorf("failed to resume process: %w", err)
+	}
+	return a.confirmStopped(false)
+}
+
+// signalImpl sends an arbitrary signal to the attached process.
+func (a *AttachedProcess) signalImpl(sig os.Signal) error {
+	s, ok := sig.(syscall.Signal)
+	if !ok {
+		return fmt.Errorf("unsupported signal type %T", sig)
+	}
+	return a.sendSignal(s)
+}
+
+// killWithSignalImpl terminates the attached process. When graceful is
+// true, SIGTERM is sent first (followed by SIGCONT if the process is
+// paused, so it can act on it) and SIGKILL only after the timeout.
+// It must be called with a.mu held.
+func (a *AttachedProcess) killWithSignalImpl(timeout time.Duration, graceful bool) error {
+	if graceful {
+		if err := a.sendSignal(syscall.SIGTERM); err != nil {
+			return fmt.Errorf("failed to send SIGTERM: %w", err)
+		}
+		if a.paused || a.transitioning {
+			_ = a.sendSignal(syscall.SIGCONT) // Ignore error - process might already be dead
+		}
+
+		select {
+		case <-a.exited:
+			// Process exited gracefully
+			return nil
+		case <-time.After(timeout):
+			// Timeout, force kill
+		}
+	}
+
+	return a.sendSignal(syscall.SIGKILL)
+}
@@ -0,0 +1,174 @@
+//go:build linux || darwin
+
+package processctrl
+
+import (
+	"os"
+	"os/exec"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// startExternal starts a process outside of processctrl and reaps it in
+// the background so that it does not linger as a zombie.
+func startExternal(t *testing.T, name string, args ...string) *exec.Cmd {
+	t.Helper()
+
+	cmd := exec.Command(name, args...)
+	if err := cmd.Start(); err != nil {
+		t.Fatalf("failed to start %s: %v", name, err)
+	}
+	go func() { _ = cmd.Wait() }()
+	t.Cleanup(func() { _ = cmd.Process.Kill() })
+	return cmd
+}
+
+func TestAttachInvalidPID(t *testing.T) {
+	if _, err := Attach(0); err == nil {
+		t.Error("Attach(0) should fail")
+	}
+	if _, err := Attach(-1); err == nil {
+		t.Error("Attach(-1) should fail")
+	}
+}
+
+func TestAttachPauseResumeTerminate(t *testing.T) {
+	cmd := startExternal(t, "sleep", "10")
+
+	proc, err := Attach(cmd.Process.Pid)
+	if err != nil {
+		t.Fatalf("Attach() failed: %v", err)
+	}
+
+	if !proc.IsRunning() {
+		t.Fatal("Attached process should be running")
+	}
+	if proc.PID() != cmd.Process.Pid {
+		t.Errorf("Expected PID %d, got %d", cmd.Process.Pid, proc.PID())
+	}
+
+	if err := proc.Pause(); err != nil {
+		t.Fatalf("Pause() failed: %v", err)
+	}
+	if !proc.IsPaused() {
+		t.Error("Process should be paused after Pause()")
+	}
+	if err := proc.Pause(); err == nil {
+		t.Error("Pause() should fail on paused process")
+	}
+
+	// Terminating a paused process must not wait for the full timeout
+	start := time.Now()
+	if err := proc.Terminate(); err != nil {
+		t.Fatalf("Terminate() failed: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed >= defaultKillTimeout {
+		t.Errorf("Terminate() of paused process took %v", elapsed)
+	}
+
+	select {
+	case <-proc.Done():
+	case <-time.After(testTimeout * time.Second):
+		t.Fatal("Done() was not closed after termination")
+	}
+
+	if proc.IsRunning() {
+		t.Error("Process should not be running after Terminate()")
+	}
+	if err := proc.Resume(); err == nil {
+		t.Error("Resume() should fail on exited process")
+	}
+}
+
+func TestAttachDetach(t *testing.T) {
+	cmd := startExternal(t, "sleep", "10")
+
+	proc, err := Attach(cmd.Process.Pid)
+	if err != nil {
+		t.Fatalf("Attach() failed: %v", err)
+	}
+
+	if err := proc.Detach(); err != nil {
+		t.Fatalf("Detach() failed: %v", err)
+	}
+	if err := proc.Detach(); err == nil {
+		t.Error("Detach() should fail on detached handle")
+	}
+	if err := proc.Pause(); err == nil {
+		t.Error("Pause() should fail on detached handle")
+	}
+	if proc.IsRunning() {
+		t.Error("Detached handle should not report running")
+	}
+}
+
+func TestControllerKillSemantics(t *testing.T) {
+	// The shell exits with code 3 on SIGTERM, so a graceful kill can be
+	// told apart from SIGKILL
+	const script = `trap 'exit 3' TERM; while :; do sleep 0.1; done`
+
+	controllers := map[string]func(t *testing.T) (Controller, func() *os.ProcessState){
+		"Process": func(t *testing.T) (Controller, func() *os.ProcessState) {
+			proc := New("sh", "-c", script)
+			if _, _, err := proc.Run(); err != nil {
+				t.Fatalf("Run() failed: %v", err)
+			}
+			return proc, func() *os.ProcessState {
+				result, err := proc.Result()
+				if err != nil {
+					t.Fatalf("Result() failed: %v", err)
+				}
+				return result.State
+			}
+		},
+		"AttachedProcess": func(t *testing.T) (Controller, func() *os.ProcessState) {
+			cmd := exec.Command("sh", "-c", script)
+			if err := cmd.Start(); err != nil {
+				t.Fatalf("failed to start sh: %v", err)
+			}
+			waited := make(chan struct{})
+			go func() {
+				_ = cmd.Wait()
+				close(waited)
+			}()
+			t.Cleanup(func() { _ = cmd.Process.Kill() })
+
+			proc, err := Attach(cmd.Process.Pid)
+			if err != nil {
+				t.Fatalf("Attach() failed: %v", err)
+			}
+			return proc, func() *os.ProcessState {
+				<-waited
+				return cmd.ProcessState
+			}
+		},
+	}
+
+	for name, start := range controllers {
+		t.Run(name+"/Kill", func(t *testing.T) {
+			ctrl, state := start(t)
+			time.Sleep(200 * time.Millisecond) // Let the shell install its trap
+
+			if err := ctrl.Kill(); err != nil {
+				t.Fatalf("Kill() failed: %v", err)
+			}
+			if code := state().ExitCode(); code != 3 {
+				t.Errorf("Kill() should send SIGTERM first, exit code %d", code)
+			}
+		})
+
+		t.Run(name+"/KillWithTimeout", func(t *testing.T) {
+			ctrl, state := start(t)
+			time.Sleep(200 * time.Millisecond) // Let the shell install its trap
+
+			if err := ctrl.KillWithTimeout(time.Minute); err != nil {
+				t.Fatalf("KillWithTimeout() failed: %v", err)
+			}
+			ws, ok := state().Sys().(syscall.WaitStatus)
+			if !ok || !ws.Signaled() || ws.Signal() != syscall.SIGKILL {
+				t.Errorf("KillWithTimeout() should send SIGKILL at once, got %v", state())
+			}
+		})
+	}
+}
@@ -0,0 +1,119 @@
+//go:build windows
+
+// Package processctrl Windows attach implementation
+//
+// This file contains the Windows-specific handle for attached processes.
+// A process handle keeps the process object alive, so the process ID
+// cannot be reused while the handle is open.
+
+package processctrl
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"golang.org/x/sys/windows"
+)
+
+// attachAccess is the set of access rights requested for attached processes.
+const attachAccess = windows.SYNCHRONIZE | windows.PROCESS_SUSPEND_RESUME |
+	windows.PROCESS_TERMINATE | windows.PROCESS_QUERY_LIMITED_INFORMATION
+
+// attachWaitInterval is how long the exit watcher blocks in each wait so
+// that it can notice Detach.
+const attachWaitInterval = 250 * time.Millisecond
+
+// attachHandle holds the Windows-specific state of an attached process.
+type attachHandle struct {
+	h         windows.Handle
+	watchDone chan struct{} // closed when the exit watcher returns
+}
+
+// openImpl opens a handle to the process.
+func (a *AttachedProcess) openImpl() error {
+	h, err := windows.OpenProcess(attachAccess, false, uint32(a.pid))
+	if err != nil {
+		return err
+	}
+	a.handle.h = h
+	return nil
+}
+
+// watchImpl starts a goroutine that closes the exited channel when the
+// process handle becomes signaled.
+func (a *AttachedProcess) watchImpl() {
+	a.handle.watchDone = make(chan struct{})
+	h := a.handle.h
+
+	go func() {
+		defer close(a.handle.watchDone)
+
+		for {
+			ev, err := windows.WaitForSingleObject(h, uint32(attachWaitInterval.Milliseconds()))
+			if err != nil {
+				return
+			}
+			if ev == windows.WAIT_OBJECT_0 {
+				a.markExited()
+				return
+			}
+
+			select {
+			case <-a.stop:
+				return
+			default:
+			}
+		}
+	}()
+}
+
+// closeImpl stops the exit watcher and closes the process handle.
+// It must be called with a.mu held.
+func (a *AttachedProcess) closeImpl() {
+	if a.handle.watchDone != nil {
+		<-a.handle.watchDone
+	}
+	_ = windows.CloseHandle(a.handle.h) // Ignore error on cleanup
+}
+
+// pauseImpl suspends the attached process using NtSuspendProcess.
+func (a *AttachedProcess) pauseImpl() error {
+	return suspendProcess(uint32(a.pid))
+}
+
+// resumeImpl resumes the attached process using NtResumeProcess.
+func (a *AttachedProcess) resumeImpl() error {
+	return resumeProcess(uint32(a.pid))
+}
+
+// signalImpl supports only os.Kill, which terminates the process.
+func (a *AttachedProcess) signalImpl(sig os.Signal) error {
+	if sig != os.Kill {
+		return fmt.Errorf("unsupported signal on Windows: %v", sig)
+	}
+	return windows.TerminateProcess(a.handle.h, 1)
+}
+
+// killWithSignalImpl terminates the attached process. Windows has no
+// termination request that arbitrary processes can handle, so the process
+// is terminated immediately and the timeout only bounds the wait for exit.
+// It must be called with a.mu held.
+func (a *AttachedProcess) killWithSignalImpl(timeout time.Duration, graceful bool) error {
+	if err := windows.TerminateProcess(a.handle.h, 1); err != nil {
+		return err
+	}
+
+	if graceful {
+		select {
+		case <-a.exited:
+		case <-time.After(timeout):
+		}
+	}
+	return nil
+}
+
+// infoImpl is not supported on Windows.
+func (a *AttachedProcess) infoImpl() (*ProcessInfo, error) {
+	return nil, ErrNotSupported
+}
@@ -0,0 +1,166 @@
+package processctrl
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Capability is a Linux capability, see capabilities(7). The values are
+// the kernel's capability numbers.
+type Capability int
+
+const (
+	CapChown Capability = iota
+	CapDACOverride
+	CapDACReadSearch
+	CapFowner
+	CapFsetid
+	CapKill
+	CapSetgid
+	CapSetuid
+	CapSetpcap
+	CapLinuxImmutable
+	CapNetBindService
+	CapNetBroadcast
+	CapNetAdmin
+	CapNetRaw
+	CapIPCLock
+	CapIPCOwner
+	CapSysModule
+	CapSysRawio
+	CapSysChroot
+	CapSysPtrace
+	CapSysPacct
+	CapSysAdmin
+	CapSysBoot
+	CapSysNice
+	CapSysResource
+	CapSysTime
+	CapSysTTYConfig
+	CapMknod
+	CapLease
+	CapAuditWrite
+	CapAuditControl
+	CapSetfcap
+	CapMACOverride
+	CapMACAdmin
+	CapSyslog
+	CapWakeAlarm
+	CapBlockSuspend
+	CapAuditRead
+	CapPerfmon
+	CapBPF
+	CapCheckpointRestore
+)
+
+// capabilityNames are the names of the capabilities without the CAP_
+// prefix, indexed by capability number.
+var capabilityNames = [...]string{
+	"CHOWN", "DAC_OVERRIDE", "DAC_READ_SEARCH", "FOWNER", "FSETID", "KILL",
+	"SETGID", "SETUID", "SETPCAP", "LINUX_IMMUTABLE", "NET_BIND_SERVICE",
+	"NET_BROADCAST", "NET_ADMIN", "NET_RAW", "IPC_LOCK", "IPC_OWNER",
+	"SYS_MODULE", "SYS_RAWIO", "SYS_CHROOT", "SYS_PTRACE", "SYS_PACCT",
+	"SYS_ADMIN", "SYS_BOOT", "SYS_NICE", "SYS_RESOURCE", "SYS_TIME",
+	"SYS_TTY_CONFIG", "MKNOD", "LEASE", "AUDIT_WRITE", "AUDIT_CONTROL",
+	"SETFCAP", "MAC_OVERRIDE", "MAC_ADMIN", "SYSLOG", "WAKE_ALARM",
+	"BLOCK_SUSPEND", "AUDIT_READ", "PERFMON", "BPF", "CHECKPOINT_RESTORE",
+}
+
+// String returns the kernel name of the capability, e.g. CAP_NET_RAW.
+func (c Capability) String() string {
+	if c < 0 || int(c) >= len(capabilityNames) {
+		return fmt.Sprintf("Capability(%d)", int(c))
+	}
+	return "CAP_" + capabilityNames[c]
+}
+
+// ParseCapability returns the capability with the given name. The name
+// is case-insensitive and the CAP_ prefix is optional, so "CAP_NET_RAW"
+// and "net_raw" are equivalent.
+func ParseCapability(name string) (Capability, error) {
+	upper := strings.TrimPrefix(strings.ToUpper(name), "CAP_")
+	for i, n := range capabilityNames {
+		if n == upper {
+			return Capability(i), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown capability %q", name)
+}
+
+// Capabilities restricts the capabilities of a process.
+//
+// The bounding set limits the capabilities the process and its
+// descendants can ever gain, even as root or through file capabilities.
+// The inheritable set is preserved across exec. Ambient capabilities are
+// kept by a program that is not root and has no file capabilities, which
+// makes it possible to run a program as an unprivileged user (see
+// SetCredentials) that keeps, for example, CAP_NET_BIND_SERVICE.
+type Capabilities struct {
+	Bounding    []Capability // Capabilities kept in the bounding set, all others are dropped; nil keeps the set unchanged
+	Inheritable []Capability // The inheritable set, replacing the controller's
+	Ambient     []Capability // Capabilities raised in the ambient set; they are added to the inheritable set
+	NoNewPrivs  bool         // Set PR_SET_NO_NEW_PRIVS, so exec can never grant privileges
+}
+
+// SetCapabilities restricts the capabilities of the process, or removes
+// the restriction if c is nil. It must be called before the process is
+// started.
+//
+// The sets are changed on a dedicated thread of the controller that
+// starts the process and is discarded afterwards, so the controller
+// itself keeps its capabilities. Changing the sets requires the
+// corresponding privileges, usually root: dropping from the bounding set
+// requires CAP_SETPCAP, and inheritable and ambient capabilities must be
+// permitted to the controller. Otherwise Run fails.
+//
+// Only Linux is supported; other platforms return ErrNotSupported.
+//
+// Returns an error if a capability is unknown, an inheritable or ambient
+// capability is not in the bounding set, capabilities are not supported
+// or the process is running.
+func (p *Process) SetCapabilities(c *Capabilities) error {
+	if !capabilitiesSupported {
+		return ErrNotSupported
+	}
+
+	var caps *Capabilities
+	if c != nil {
+		copied := Capabilities{
+			Bounding:    append([]Capability(nil), c.Bounding...),
+			Inheritable: append([]Capability(nil), c.Inheritable...),
+			Ambient:     append([]Capability(nil), c.Ambient...),
+			NoNewPrivs:  c.NoNewPrivs,
+		}
+		if c.Bounding != nil && copied.Bounding == nil {
+			// An empty bounding set drops all capabilities
+			copied.Bounding = []Capability{}
+		}
+		for _, set := range [][]Capability{copied.Bounding, copied.Inheritable, copied.Ambient} {
+			for _, capability := range set {
+				if capability < 0 || int(capability) >= len(capabilityNames) {
+					return fmt.Errorf("invalid capability: %v", capability)
+				}
+			}
+		}
+		if copied.Bounding != nil {
+			for _, set := range [][]Capability{copied.Inheritable, copied.Ambient} {
+				for _, capability := range set {
+					if !slices.Contains(copied.Bounding, capability) {
+						return fmt.Errorf("%v is not in the bounding set", capability)
+					}
+				}
+			}
+		}
+		caps = &copied
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.running {
+		return fmt.Errorf("cannot change the capabilities of a running process")
+	}
+	p.caps = caps
+	return nil
+}
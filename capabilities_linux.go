@@ -0,0 +1,153 @@
+//go:build linux
+
+// Package processctrl Linux capabilities
+//
+// This file restricts the capabilities of child processes. Capabilities
+// and no_new_privs are attributes of threads that a child inherits from
+// the thread that forks it, so the controller changes them on a locked
+// thread that starts the child and is then terminated instead of being
+// returned to the Go scheduler. The main thread is never used for this,
+// since the runtime cannot terminate it. Ambient capabilities are raised by the
+// standard library in the child, after the switch to another user.
+
+package processctrl
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+// capabilitiesSupported reports whether SetCapabilities is available.
+const capabilitiesSupported = true
+
+// prepareCapabilities configures the ambient capabilities of the child.
+// It must be called with p.mu held before the command is started.
+func (p *Process) prepareCapabilities() {
+	if p.caps == nil {
+		return
+	}
+	p.cmd.SysProcAttr.AmbientCaps = ambientCaps(p.caps)
+}
+
+// ambientCaps returns the ambient capabilities of c for SysProcAttr.
+func ambientCaps(c *Capabilities) []uintptr {
+	ambient := make([]uintptr, len(c.Ambient))
+	for i, capability := range c.Ambient {
+		ambient[i] = uintptr(capability)
+	}
+	return ambient
+}
+
+// startWithCapabilities calls start, which must start the child and
+// finish everything that has to happen on the thread that started it. If
+// capabilities are restricted, start runs on a dedicated thread with the
+// restricted capabilities, which is terminated afterwards.
+// In a sandbox, the init process applies the capabilities instead.
+// It must be called with p.mu held.
+func (p *Process) startWithCapabilities(start func() error) error {
+	if p.sandbox != nil {
+		return start()
+	}
+	return withRestrictedThread(p.caps, start)
+}
+
+// withRestrictedThread calls start on a dedicated thread with the
+// capabilities restricted to c, which is terminated afterwards, or on the
+// current thread if c is nil.
+func withRestrictedThread(c *Capabilities, start func() error) error {
+	if c == nil {
+		return start()
+	}
+
+	errc := make(chan error, 1)
+	var run func()
+	run = func() {
+		// The thread is never unlocked, so the runtime terminates it when
+		// the goroutine returns rather than reusing its credentials
+		runtime.LockOSThread()
+
+		if unix.Gettid() == unix.Getpid() {
+			// The main thread is never terminated, only parked. While it
+			// is locked here, a new goroutine runs on another thread.
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				run()
+			}()
+			<-done
+			runtime.UnlockOSThread()
+			return
+		}
+
+		if err := restrictThreadCapabilities(c); err != nil {
+			errc <- fmt.Errorf("failed to restrict capabilities: %w", err)
+			return
+		}
+		errc <- start()
+	}
+	go run()
+	return <-errc
+}
+
+// restrictThreadCapabilities applies the capability restrictions to the
+// current thread.
+func restrictThreadCapabilities(c *Capabilities) error {
+	if c.NoNewPrivs {
+		if err := unix.Prctl(unix.PR_SET_NO_NEW_PRIVS, 1, 0, 0, 0); err != nil {
+			return fmt.Errorf("failed to set no_new_privs: %w", err)
+		}
+	}
+
+	// The inheritable set must be changed before the bounding set, which
+	// limits which capabilities can be added to it
+	hdr := unix.CapUserHeader{Version: unix.LINUX_CAPABILITY_VERSION_3}
+	var data [2]unix.CapUserData
+	if err := unix.Capget(&hdr, &data[0]); err != nil {
+		return fmt.Errorf("failed to read capabilities: %w", err)
+	}
+	data[0].Inheritable, data[1].Inheritable = 0, 0
+	for _, set := range [][]Capability{c.Inheritable, c.Ambient} {
+		for _, capability := range set {
+			data[capability/32].Inheritable |= 1 << (uint(capability) % 32)
+		}
+	}
+	if err := unix.Capset(&hdr, &data[0]); err != nil {
+		return fmt.Errorf("failed to set inheritable capabilities: %w", err)
+	}
+
+	if c.Bounding == nil {
+		return nil
+	}
+	last, err := lastCapability()
+	if err != nil {
+		return err
+	}
+	for capability := Capability(0); capability <= last; capability++ {
+		if slices.Contains(c.Bounding, capability) {
+			continue
+		}
+		if err := unix.Prctl(unix.PR_CAPBSET_DROP, uintptr(capability), 0, 0, 0); err != nil {
+			return fmt.Errorf("failed to drop %v from the bounding set: %w", capability, err)
+		}
+	}
+	return nil
+}
+
+// lastCapability returns the highest capability supported by the kernel.
+func lastCapability() (Capability, error) {
+	data, err := os.ReadFile("/proc/sys/kernel/cap_last_cap")
+	if err != nil {
+		return 0, err
+	}
+	last, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil {
+		return 0, fmt.Errorf("invalid cap_last_cap: %w", err)
+	}
+	return Capability(last), nil
+}
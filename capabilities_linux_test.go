@@ -0,0 +1,169 @@
+//go:build linux
+
+package processctrl
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// readStatus returns the fields of a /proc/<pid>/status file.
+func readStatus(t *testing.T, path string) map[string]string {
+	t.Helper()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read %s: %v", path, err)
+	}
+	fields := make(map[string]string)
+	for _, line := range strings.Split(string(data), "\n") {
+		if key, value, ok := strings.Cut(line, ":"); ok {
+			fields[key] = strings.TrimSpace(value)
+		}
+	}
+	return fields
+}
+
+// capMask formats capabilities as in /proc/<pid>/status.
+func capMask(caps ...Capability) string {
+	var mask uint64
+	for _, c := range caps {
+		mask |= 1 << uint(c)
+	}
+	return fmt.Sprintf("%016x", mask)
+}
+
+// startWithCaps starts sleep with the given capabilities and returns its
+// status.
+func startWithCaps(t *testing.T, c *Capabilities, creds *Credentials) map[string]string {
+	t.Helper()
+
+	if os.Geteuid() != 0 {
+		t.Skip("Changing capabilities requires root")
+	}
+
+	proc := New("sleep", "10")
+	if err := proc.SetCapabilities(c); err != nil {
+		t.Fatalf("SetCapabilities() failed: %v", err)
+	}
+	if creds != nil {
+		if err := proc.SetCredentials(creds); err != nil {
+			t.Fatalf("SetCredentials() failed: %v", err)
+		}
+	}
+	if _, _, err := proc.Run(); err != nil {
+		t.Fatalf("Run() failed: %v", err)
+	}
+	t.Cleanup(func() { _ = proc.Kill() })
+
+	return readStatus(t, fmt.Sprintf("/proc/%d/status", proc.PID()))
+}
+
+func TestCapabilitiesBoundingAndNoNewPrivs(t *testing.T) {
+	own := readStatus(t, "/proc/self/status")
+
+	status := startWithCaps(t, &Capabilities{
+		Bounding:    []Capability{CapChown, CapNetBindService},
+		Inheritable: []Capability{CapChown},
+		NoNewPrivs:  true,
+	}, nil)
+
+	want := map[string]string{
+		"CapBnd":     capMask(CapChown, CapNetBindService),
+		"CapInh":     capMask(CapChown),
+		"CapEff":     capMask(CapChown, CapNetBindService),
+		"CapAmb":     capMask(),
+		"NoNewPrivs": "1",
+	}
+	for key, value := range want {
+		if status[key] != value {
+			t.Errorf("%s = %s, want %s", key, status[key], value)
+		}
+	}
+
+	// No thread of the controller may keep the restrictions. The thread
+	// that started the child exits asynchronously.
+	deadline := time.Now().Add(testTimeout * time.Second)
+	for {
+		restricted := restrictedThreads(t, own)
+		if len(restricted) == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Threads with restricted capabilities: %v", restricted)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// restrictedThreads returns the threads of the controller whose
+// capabilities differ from own.
+func restrictedThreads(t *testing.T, own map[string]string) []string {
+	t.Helper()
+
+	tasks, err := filepath.Glob("/proc/self/task/*/status")
+	if err != nil {
+		t.Fatalf("Failed to list threads: %v", err)
+	}
+	var restricted []string
+	for _, task := range tasks {
+		s, err := os.ReadFile(task)
+		if err != nil {
+			continue // Thread exited
+		}
+		for _, key := range []string{"CapBnd", "CapInh", "NoNewPrivs"} {
+			if !strings.Contains(string(s), key+":\t"+own[key]+"\n") {
+				restricted = append(restricted, task)
+				break
+			}
+		}
+	}
+	return restricted
+}
+
+func TestCapabilitiesAmbientAsUnprivilegedUser(t *testing.T) {
+	status := startWithCaps(t, &Capabilities{
+		Bounding: []Capability{CapNetBindService},
+		Ambient:  []Capability{CapNetBindService},
+	}, &Credentials{User: "65534", Group: "65534"})
+
+	if uid := strings.Fields(status["Uid"]); len(uid) == 0 || uid[0] != "65534" {
+		t.Errorf("Uid = %s, want 65534", status["Uid"])
+	}
+	for _, key := range []string{"CapInh", "CapPrm", "CapEff", "CapAmb", "CapBnd"} {
+		if status[key] != capMask(CapNetBindService) {
+			t.Errorf("%s = %s, want %s", key, status[key], capMask(CapNetBindService))
+		}
+	}
+}
+
+func TestSetCapabilitiesInvalid(t *testing.T) {
+	proc := New("true")
+	for _, c := range []*Capabilities{
+		{Bounding: []Capability{CapChown}, Ambient: []Capability{CapNetRaw}},
+		{Bounding: []Capability{}, Inheritable: []Capability{CapChown}},
+		{Inheritable: []Capability{Capability(64)}},
+	} {
+		if err := proc.SetCapabilities(c); err == nil {
+			t.Errorf("SetCapabilities(%+v) succeeded, want error", c)
+		}
+	}
+}
+
+func TestParseCapability(t *testing.T) {
+	for _, name := range []string{"CAP_NET_RAW", "net_raw", "Cap_Net_Raw"} {
+		if c, err := ParseCapability(name); err != nil || c != CapNetRaw {
+			t.Errorf("ParseCapability(%q) = %v, %v, want %v", name, c, err, CapNetRaw)
+		}
+	}
+	if _, err := ParseCapability("CAP_FLY"); err == nil {
+		t.Error("ParseCapability(\"CAP_FLY\") succeeded, want error")
+	}
+	if CapCheckpointRestore.String() != "CAP_CHECKPOINT_RESTORE" {
+		t.Errorf("String() = %s, want CAP_CHECKPOINT_RESTORE", CapCheckpointRestore)
+	}
+}
@@ -0,0 +1,19 @@
+//go:build !linux
+
+// Package processctrl capabilities stub
+//
+// Capabilities are a Linux feature. On other platforms SetCapabilities
+// returns ErrNotSupported.
+
+package processctrl
+
+// capabilitiesSupported reports whether SetCapabilities is available.
+const capabilitiesSupported = false
+
+// prepareCapabilities is a no-op on platforms without capabilities.
+func (p *Process) prepareCapabilities() {}
+
+// startWithCapabilities calls start on platforms without capabilities.
+func (p *Process) startWithCapabilities(start func() error) error {
+	return start()
+}
@@ -0,0 +1,394 @@
+//go:build linux
+
+// Package processctrl Linux cgroup v2 support
+//
+// This file places processes in their own cgroup v2. The child is cloned
+// directly into the cgroup with CLONE_INTO_CGROUP on Linux 5.7 and later,
+// so no descendant can escape it; on older kernels it is moved into the
+// cgroup right after it has been started. The cgroup freezer is used for
+// Pause and Resume, so that the whole process tree stops atomically.
+
+package processctrl
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// cgroupSupported reports whether SetCgroup is available.
+const cgroupSupported = true
+
+// cgroupRemoveTimeout is how long removal of a cgroup is retried while
+// killed processes are still leaving it.
+const cgroupRemoveTimeout = 2 * time.Second
+
+// cgroupSeq makes the names of cgroups created by this process unique.
+var cgroupSeq atomic.Uint64
+
+// cgroup2Mount returns the mount point of the cgroup v2 hierarchy. On
+// systems in hybrid mode it is usually /sys/fs/cgroup/unified.
+func cgroup2Mount() (string, error) {
+	f, err := os.Open("/proc/self/mountinfo")
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = f.Close() }() // Ignore error on cleanup
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		// The fields after the " - " separator are the filesystem type,
+		// mount source and super block options.
+		pre, post, ok := strings.Cut(scanner.Text(), " - ")
+		if !ok {
+			continue
+		}
+		fields := strings.Fields(pre)
+		if len(fields) >= 5 && strings.HasPrefix(post, "cgroup2 ") {
+			return fields[4], nil
+		}
+	}
+	return "", fmt.Errorf("cgroup v2 is not mounted")
+}
+
+// ownCgroup returns the directory of the cgroup v2 the controller runs in.
+func ownCgroup() (string, error) {
+	mount, err := cgroup2Mount()
+	if err != nil {
+		return "", err
+	}
+	data, err := os.ReadFile("/proc/self/cgroup")
+	if err != nil {
+		return "", err
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if path, ok := strings.CutPrefix(line, "0::"); ok {
+			return filepath.Join(mount, path), nil
+		}
+	}
+	return "", fmt.Errorf("process is not in a cgroup v2")
+}
+
+// cloneIntoCgroupSupported reports whether the kernel supports
+// CLONE_INTO_CGROUP, which was added in Linux 5.7.
+func cloneIntoCgroupSupported() bool {
+	var uts unix.Utsname
+	if err := unix.Uname(&uts); err != nil {
+		return false
+	}
+	var major, minor int
+	if _, err := fmt.Sscanf(unix.ByteSliceToString(uts.Release[:]), "%d.%d", &major, &minor); err != nil {
+		return false
+	}
+	return major > 5 || (major == 5 && minor >= 7)
+}
+
+// writeCgroupFile writes a value to a cgroup interface file.
+func writeCgroupFile(dir, name, value string) error {
+	return os.WriteFile(filepath.Join(dir, name), []byte(value), 0)
+}
+
+// setupCgroup creates a cgroup for a process and applies the configured
+// limits. It returns the cgroup, which is nil if it could not be created,
+// and all problems encountered.
+func setupCgroup(cfg *CgroupConfig) (*cgroupHandle, []error) {
+	var errs []error
+
+	parent := cfg.Parent
+	if parent == "" {
+		var err error
+		if parent, err = ownCgroup(); err != nil {
+			return nil, []error{err}
+		}
+	}
+
+	// Enable the controllers needed for the configured limits
+	var controllers []string
+	if cfg.CPUQuota > 0 {
+		controllers = append(controllers, "cpu")
+	}
+	if cfg.MemoryMax > 0 || cfg.MemoryHigh > 0 {
+		controllers = append(controllers, "memory")
+	}
+	if cfg.PidsMax > 0 {
+		controllers = append(controllers, "pids")
+	}
+	if len(cfg.IOMax) > 0 {
+		controllers = append(controllers, "io")
+	}
+	for _, c := range controllers {
+		if err := writeCgroupFile(parent, "cgroup.subtree_control", "+"+c); err != nil {
+			errs = append(errs, fmt.Errorf("failed to enable %s controller in %s: %w", c, parent, err))
+		}
+	}
+
+	name := fmt.Sprintf("processctrl-%d-%d", os.Getpid(), cgroupSeq.Add(1))
+	cg := &cgroupHandle{path: filepath.Join(parent, name), fd: -1}
+	if err := os.Mkdir(cg.path, 0o755); err != nil {
+		return nil, append(errs, fmt.Errorf("failed to create cgroup: %w", err))
+	}
+
+	var limits [][2]string
+	if cfg.CPUQuota > 0 {
+		period := cfg.CPUPeriod.Microseconds()
+		quota := int64(cfg.CPUQuota / 100 * float64(period))
+		limits = append(limits, [2]string{"cpu.max", fmt.Sprintf("%d %d", max(quota, 1000), period)})
+	}
+	if cfg.MemoryMax > 0 {
+		limits = append(limits, [2]string{"memory.max", strconv.FormatUint(cfg.MemoryMax, 10)})
+	}
+	if cfg.MemoryHigh > 0 {
+		limits = append(limits, [2]string{"memory.high", strconv.FormatUint(cfg.MemoryHigh, 10)})
+	}
+	if cfg.PidsMax > 0 {
+		limits = append(limits, [2]string{"pids.max", strconv.FormatUint(cfg.PidsMax, 10)})
+	}
+	for _, io := range cfg.IOMax {
+		limits = append(limits, [2]string{"io.max", formatIOMax(io)})
+	}
+	for _, l := range limits {
+		if err := writeCgroupFile(cg.path, l[0], l[1]); err != nil {
+			errs = append(errs, fmt.Errorf("failed to set %s: %w", l[0], err))
+		}
+	}
+
+	if _, err := os.Stat(filepath.Join(cg.path, "cgroup.freeze")); err == nil {
+		cg.canFreeze = true
+	} else {
+		errs = append(errs, fmt.Errorf("cgroup freezer not available (Linux 5.2+ required)"))
+	}
+
+	if cloneIntoCgroupSupported() {
+		fd, err := unix.Open(cg.path, unix.O_RDONLY|unix.O_DIRECTORY|unix.O_CLOEXEC, 0)
+		if err == nil {
+			cg.fd = fd
+		}
+	}
+	cg.placeAfter = cg.fd < 0
+	return cg, errs
+}
+
+// formatIOMax formats an io.max line. Rates that are zero are set to max.
+func formatIOMax(io IOMax) string {
+	rate := func(v uint64) string {
+		if v == 0 {
+			return "max"
+		}
+		return strconv.FormatUint(v, 10)
+	}
+	return fmt.Sprintf("%s rbps=%s wbps=%s riops=%s wiops=%s",
+		io.Device, rate(io.ReadBPS), rate(io.WriteBPS), rate(io.ReadIOPS), rate(io.WriteIOPS))
+}
+
+// prepareCgroup creates the cgroup configured with SetCgroup and arranges
+// for the child to be cloned into it.
+// It must be called with p.mu held before the command is started.
+func (p *Process) prepareCgroup() error {
+	p.cgroup = nil
+	p.cgroupErr = nil
+	if p.cgroupCfg == nil {
+		return nil
+	}
+
+	cg, errs := setupCgroup(p.cgroupCfg)
+	err := errors.Join(errs...)
+	if err != nil && p.cgroupCfg.Required {
+		if cg != nil {
+			removeCgroup(cg)
+		}
+		return fmt.Errorf("failed to set up cgroup: %w", err)
+	}
+
+	p.cgroupErr = err
+	p.cgroup = cg
+	if cg != nil && cg.fd >= 0 {
+		p.cmd.SysProcAttr.UseCgroupFD = true
+		p.cmd.SysProcAttr.CgroupFD = cg.fd
+	}
+	return nil
+}
+
+// placeCgroup completes the cgroup setup after the child has been
+// started, moving it into its cgroup on kernels without
+// CLONE_INTO_CGROUP.
+// It must be called with p.mu held.
+func (p *Process) placeCgroup() {
+	cg := p.cgroup
+	if cg == nil {
+		return
+	}
+	if cg.fd >= 0 {
+		_ = unix.Close(cg.fd) // Ignore error during cleanup
+		cg.fd = -1
+	}
+	if !cg.placeAfter {
+		return
+	}
+
+	pid := strconv.Itoa(p.cmd.Process.Pid)
+	if err := writeCgroupFile(cg.path, "cgroup.procs", pid); err != nil {
+		p.cgroupErr = errors.Join(p.cgroupErr, fmt.Errorf("failed to move process into cgroup: %w", err))
+		removeCgroup(cg)
+		p.cgroup = nil
+	}
+}
+
+// releaseCgroup removes the cgroup if the process could not be started.
+// It must be called with p.mu held.
+func (p *Process) releaseCgroup() {
+	if p.cgroup == nil {
+		return
+	}
+	if p.cgroup.fd >= 0 {
+		_ = unix.Close(p.cgroup.fd) // Ignore error during cleanup
+	}
+	removeCgroup(p.cgroup)
+	p.cgroup = nil
+}
+
+// finishCgroup reads the OOM kills of a cgroup after its process has
+// exited, kills processes left in it and removes it.
+func finishCgroup(cg *cgroupHandle) (oomKills uint64) {
+	if cg == nil {
+		return 0
+	}
+	if events, err := readKeyValues(filepath.Join(cg.path, "memory.events")); err == nil {
+		oomKills = events["oom_kill"]
+	}
+	removeCgroup(cg)
+	return oomKills
+}
+
+// removeCgroup kills all processes in the cgroup and removes it.
+func removeCgroup(cg *cgroupHandle) {
+	if err := writeCgroupFile(cg.path, "cgroup.kill", "1"); err != nil {
+		// cgroup.kill was added in Linux 5.14; a frozen process cannot act
+		// on SIGKILL on older kernels until it is thawed
+		_ = writeCgroupFile(cg.path, "cgroup.freeze", "0") // Ignore error - the freezer might be unavailable
+		if data, err := os.ReadFile(filepath.Join(cg.path, "cgroup.procs")); err == nil {
+			for _, field := range strings.Fields(string(data)) {
+				if pid, err := strconv.Atoi(field); err == nil {
+					_ = syscall.Kill(pid, syscall.SIGKILL) // Ignore error - process might already be dead
+				}
+			}
+		}
+	}
+
+	deadline := time.Now().Add(cgroupRemoveTimeout)
+	for os.Remove(cg.path) != nil && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// setCgroupFrozen freezes or thaws the cgroup and waits until the kernel
+// reports the new state.
+// It must be called with p.mu held, which is released during the wait.
+func (p *Process) setCgroupFrozen(frozen bool) error {
+	value := "0"
+	if frozen {
+		value = "1"
+	}
+	path := p.cgroup.path
+	if err := writeCgroupFile(path, "cgroup.freeze", value); err != nil {
+		return err
+	}
+
+	return p.waitUnlocked(func() error {
+		deadline := time.Now().Add(stateConfirmTimeout)
+		for {
+			state, err := cgroupFrozen(path)
+			if err != nil {
+				return err
+			}
+			if state == frozen {
+				return nil
+			}
+			if time.Now().After(deadline) {
+				if frozen {
+					return fmt.Errorf("cgroup did not freeze within %v", stateConfirmTimeout)
+				}
+				return fmt.Errorf("cgroup did not thaw within %v", stateConfirmTimeout)
+			}
+			time.Sleep(stateConfirmPoll)
+		}
+	})
+}
+
+// thawCgroup thaws the cgroup without waiting for the kernel to report
+// the new state.
+func (p *Process) thawCgroup() error {
+	return writeCgroupFile(p.cgroup.path, "cgroup.freeze", "0")
+}
+
+// cgroupFrozen reports whether the cgroup is frozen according to
+// cgroup.events.
+func cgroupFrozen(path string) (bool, error) {
+	events, err := readKeyValues(filepath.Join(path, "cgroup.events"))
+	if err != nil {
+		return false, err
+	}
+	return events["frozen"] == 1, nil
+}
+
+// readKeyValues parses a cgroup file of "key value" lines, such as
+// cpu.stat or memory.events.
+func readKeyValues(path string) (map[string]uint64, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	values := make(map[string]uint64)
+	for _, line := range strings.Split(string(data), "\n") {
+		key, value, ok := strings.Cut(line, " ")
+		if !ok {
+			continue
+		}
+		if v, err := strconv.ParseUint(value, 10, 64); err == nil {
+			values[key] = v
+		}
+	}
+	return values, nil
+}
+
+// readCgroupValue reads a cgroup file containing a single number.
+func readCgroupValue(path string) uint64 {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0
+	}
+	return parseUintField(string(data))
+}
+
+// readCgroupStats reads the accounting of the cgroup at path.
+func readCgroupStats(path string) (*CgroupStats, error) {
+	cpu, err := readKeyValues(filepath.Join(path, "cpu.stat"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cgroup statistics: %w", err)
+	}
+
+	s := &CgroupStats{
+		CPUUsage:         time.Duration(cpu["usage_usec"]) * time.Microsecond,
+		CPUUser:          time.Duration(cpu["user_usec"]) * time.Microsecond,
+		CPUSystem:        time.Duration(cpu["system_usec"]) * time.Microsecond,
+		CPUThrottled:     time.Duration(cpu["throttled_usec"]) * time.Microsecond,
+		CPUThrottleCount: cpu["nr_throttled"],
+		MemoryCurrent:    readCgroupValue(filepath.Join(path, "memory.current")),
+		MemoryPeak:       readCgroupValue(filepath.Join(path, "memory.peak")),
+		Pids:             readCgroupValue(filepath.Join(path, "pids.current")),
+	}
+	if events, err := readKeyValues(filepath.Join(path, "memory.events")); err == nil {
+		s.MemoryHighEvents = events["high"]
+		s.OOMEvents = events["oom"]
+		s.OOMKills = events["oom_kill"]
+	}
+	return s, nil
+}
@@ -0,0 +1,153 @@
+//go:build linux
+
+package processctrl
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// requireCgroup skips the test if the started process could not be
+// placed in a cgroup.
+func requireCgroup(t *testing.T, proc *Process) {
+	t.Helper()
+
+	if proc.CgroupPath() == "" {
+		t.Skipf("cgroup not available: %v", proc.CgroupError())
+	}
+}
+
+// readCgroupFile reads a cgroup interface file of the process.
+func readCgroupFile(t *testing.T, proc *Process, name string) string {
+	t.Helper()
+
+	data, err := os.ReadFile(filepath.Join(proc.CgroupPath(), name))
+	if err != nil {
+		t.Fatalf("Failed to read %s: %v", name, err)
+	}
+	return string(data)
+}
+
+func TestCgroupFreezesTree(t *testing.T) {
+	proc := New("sh", "-c", "sleep 10 & sleep 10 & wait")
+	if err := proc.SetCgroup(&CgroupConfig{}); err != nil {
+		t.Fatalf("SetCgroup() failed: %v", err)
+	}
+	startTest(t, proc)
+	requireCgroup(t, proc)
+
+	// Wait for the children to be forked
+	time.Sleep(100 * time.Millisecond)
+	if procs := strings.Fields(readCgroupFile(t, proc, "cgroup.procs")); len(procs) != 3 {
+		t.Fatalf("cgroup.procs = %v, want 3 processes", procs)
+	}
+
+	if err := proc.Pause(); err != nil {
+		t.Fatalf("Pause() failed: %v", err)
+	}
+	if events := readCgroupFile(t, proc, "cgroup.events"); !strings.Contains(events, "frozen 1") {
+		t.Fatalf("cgroup not frozen after Pause(): %q", events)
+	}
+
+	// The state watcher must not mistake frozen processes for continued ones
+	time.Sleep(3 * stateCheckInterval)
+	if !proc.IsPaused() {
+		t.Fatal("Process not paused while its cgroup is frozen")
+	}
+
+	if err := proc.Resume(); err != nil {
+		t.Fatalf("Resume() failed: %v", err)
+	}
+	if events := readCgroupFile(t, proc, "cgroup.events"); !strings.Contains(events, "frozen 0") {
+		t.Fatalf("cgroup still frozen after Resume(): %q", events)
+	}
+}
+
+func TestCgroupStats(t *testing.T) {
+	proc := New("sh", "-c", "while :; do :; done")
+	if err := proc.SetCgroup(&CgroupConfig{}); err != nil {
+		t.Fatalf("SetCgroup() failed: %v", err)
+	}
+	startTest(t, proc)
+	requireCgroup(t, proc)
+
+	time.Sleep(300 * time.Millisecond)
+	stats, err := proc.CgroupStats()
+	if err != nil {
+		t.Fatalf("CgroupStats() failed: %v", err)
+	}
+	if stats.CPUUsage <= 0 || stats.CPUUsage < stats.CPUUser {
+		t.Errorf("Unexpected CPU accounting: %+v", stats)
+	}
+}
+
+func TestCgroupRemovedAfterExit(t *testing.T) {
+	proc := New("sh", "-c", "sleep 10 >/dev/null 2>&1 & sleep 0.5")
+	if err := proc.SetCgroup(&CgroupConfig{}); err != nil {
+		t.Fatalf("SetCgroup() failed: %v", err)
+	}
+	startTest(t, proc)
+	requireCgroup(t, proc)
+	path := proc.CgroupPath()
+
+	result := waitResult(t, proc)
+	if result.ExitCode() != 0 {
+		t.Errorf("ExitCode() = %d, want 0", result.ExitCode())
+	}
+	// The cgroup can only be removed once the detached sleep was killed
+	if _, err := os.Stat(path); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("cgroup %s not removed: %v", path, err)
+	}
+	if proc.CgroupPath() != "" {
+		t.Errorf("CgroupPath() = %q after exit, want empty", proc.CgroupPath())
+	}
+}
+
+func TestCgroupMemoryLimit(t *testing.T) {
+	const limit = 64 << 20
+	proc := New("sh", "-c", "exec sleep 10")
+	if err := proc.SetCgroup(&CgroupConfig{MemoryMax: limit}); err != nil {
+		t.Fatalf("SetCgroup() failed: %v", err)
+	}
+	startTest(t, proc)
+	requireCgroup(t, proc)
+
+	controllers := readCgroupFile(t, proc, "../cgroup.subtree_control")
+	if !strings.Contains(controllers, "memory") {
+		// Without the controller the process still runs, and the
+		// problem is reported
+		if proc.CgroupError() == nil {
+			t.Error("CgroupError() = nil without memory controller")
+		}
+
+		required := New("true")
+		if err := required.SetCgroup(&CgroupConfig{MemoryMax: limit, Required: true}); err != nil {
+			t.Fatalf("SetCgroup() failed: %v", err)
+		}
+		if _, _, err := required.Run(); err == nil {
+			t.Error("Run() succeeded with Required and missing memory controller")
+		}
+		return
+	}
+
+	if got := strings.TrimSpace(readCgroupFile(t, proc, "memory.max")); got != "67108864" {
+		t.Errorf("memory.max = %s, want 67108864", got)
+	}
+}
+
+func TestSetCgroupInvalid(t *testing.T) {
+	proc := New("true")
+	for _, cfg := range []*CgroupConfig{
+		{CPUQuota: -1},
+		{CPUPeriod: -time.Second},
+		{IOMax: []IOMax{{ReadBPS: 1}}},
+	} {
+		if err := proc.SetCgroup(cfg); err == nil {
+			t.Errorf("SetCgroup(%+v) succeeded, want error", cfg)
+		}
+	}
+}
@@ -0,0 +1,42 @@
+//go:build !linux
+
+// Package processctrl cgroup stub
+//
+// cgroups are a Linux feature. On other platforms SetCgroup returns
+// ErrNotSupported and processes are never placed in a cgroup.
+
+package processctrl
+
+// cgroupSupported reports whether SetCgroup is available.
+const cgroupSupported = false
+
+// prepareCgroup is a no-op on platforms without cgroups.
+func (p *Process) prepareCgroup() error {
+	return nil
+}
+
+// placeCgroup is a no-op on platforms without cgroups.
+func (p *Process) placeCgroup() {}
+
+// releaseCgroup is a no-op on platforms without cgroups.
+func (p *Process) releaseCgroup() {}
+
+// finishCgroup is a no-op on platforms without cgroups.
+func finishCgroup(*cgroupHandle) uint64 {
+	return 0
+}
+
+// setCgroupFrozen is not supported on this platform.
+func (p *Process) setCgroupFrozen(bool) error {
+	return ErrNotSupported
+}
+
+// thawCgroup is not supported on this platform.
+func (p *Process) thawCgroup() error {
+	return ErrNotSupported
+}
+
+// readCgroupStats is not supported on this platform.
+func readCgroupStats(string) (*CgroupStats, error) {
+	return nil, ErrNotSupported
+}
@@ -0,0 +1,57 @@
+package processctrl
+
+import (
+	"testing"
+	"time"
+)
+
+// startTest runs a process configured by the test, consumes its output
+// and kills it when the test ends. The returned channel receives the
+// first line of standard output, or is closed without one, so that the
+// test can wait until the process is ready.
+func startTest(t *testing.T, proc *Process) <-chan string {
+	t.Helper()
+
+	stdout, stderr, err := proc.Run()
+	if err != nil {
+		t.Fatalf("Run() failed: %v", err)
+	}
+	go drainLines(stderr)
+
+	first := make(chan string, 1)
+	go func() {
+		defer close(first)
+		if line, ok := <-stdout; ok {
+			first <- line
+		}
+		drainLines(stdout)
+	}()
+	t.Cleanup(func() { _ = proc.Kill() })
+
+	return first
+}
+
+// waitResult waits for the exit result of the process.
+func waitResult(t *testing.T, proc *Process) *ExitResult {
+	t.Helper()
+
+	done := make(chan *ExitResult, 1)
+	go func() {
+		result, err := proc.Result()
+		if err != nil {
+			t.Errorf("Result() failed: %v", err)
+		}
+		done <- result
+	}()
+
+	select {
+	case result := <-done:
+		if result == nil {
+			t.FailNow()
+		}
+		return result
+	case <-time.After(testTimeout * time.Second):
+		t.Fatal("Timed out waiting for the process to exit")
+		return nil
+	}
+}
@@ -0,0 +1,23 @@
+//go:build linux || darwin
+
+package processctrl
+
+import "testing"
+
+// runCollect runs a process to completion and returns its stdout lines
+// and exit result.
+func runCollect(t *testing.T, proc *Process) ([]string, *ExitResult) {
+	t.Helper()
+
+	stdout, stderr, err := proc.Run()
+	if err != nil {
+		t.Fatalf("Run() failed: %v", err)
+	}
+	go drainLines(stderr)
+
+	var lines []string
+	for line := range stdout {
+		lines = append(lines, line)
+	}
+	return lines, waitResult(t, proc)
+}
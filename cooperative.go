@@ -0,0 +1,127 @@
+package processctrl
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultCooperativeTimeout is how long a cooperative pause waits for the
+// process to acknowledge the pause request before stopping it forcibly.
+const defaultCooperativeTimeout = 5 * time.Second
+
+// CooperativePause configures a pause mode that gives the process a chance
+// to prepare before it is stopped. SIGSTOP cannot be caught, so programs
+// holding network connections or hardware locks get no warning when they
+// are paused with the default mode.
+//
+// In cooperative mode Pause first sends Signal and waits until the process
+// acknowledges it, either by stopping itself or by printing AckMarker on
+// stdout. If neither happens within Timeout, or the process acknowledged
+// with the marker but is still running, SIGSTOP is sent as before.
+//
+// Resume sends SIGCONT, followed by ResumeSignal if set, and then waits up
+// to Timeout for ResumeMarker if set. A missing resume acknowledgement is
+// not an error, since the process is running again either way.
+//
+// Detection of processes stopping themselves is only available on Linux;
+// on other platforms use AckMarker or rely on the timeout.
+type CooperativePause struct {
+	Signal       os.Signal     // Catchable pause request; defaults to SIGTSTP
+	AckMarker    string        // Stdout text acknowledging the pause request
+	ResumeSignal os.Signal     // Optional signal sent after SIGCONT on resume
+	ResumeMarker string        // Stdout text acknowledging the resume
+	Timeout      time.Duration // Acknowledgement timeout; defaults to 5s
+}
+
+// SetCooperativePause enables cooperative pausing with the given
+// configuration, or restores the default SIGSTOP-only mode if cfg is nil.
+// It can be called before or while the process is running.
+//
+// Returns ErrNotSupported on Windows, where processes are suspended with
+// NtSuspendProcess and there is no pause request they could handle.
+func (p *Process) SetCooperativePause(cfg *CooperativePause) error {
+	if cfg == nil {
+		p.mu.Lock()
+		p.coop = nil
+		p.mu.Unlock()
+		return nil
+	}
+
+	c := *cfg
+	if c.Timeout < 0 {
+		return fmt.Errorf("invalid cooperative pause timeout: %v", c.Timeout)
+	}
+	if c.Timeout == 0 {
+		c.Timeout = defaultCooperativeTimeout
+	}
+	if err := c.applyPlatformDefaults(); err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	p.coop = &c
+	p.mu.Unlock()
+	return nil
+}
+
+// lineWaiter is a pending wait for a line of output containing text.
+type lineWaiter struct {
+	text string
+	ch   chan struct{}
+}
+
+// lineMatcher notifies waiters when a line of output contains their text.
+// It is safe for concurrent use.
+type lineMatcher struct {
+	mu      sync.Mutex
+	waiters []*lineWaiter
+}
+
+// expect registers a wait for a line containing text. The returned
+// channel is closed when such a line is seen. The cancel function must be
+// called when the wait is no longer needed.
+func (m *lineMatcher) expect(text string) (<-chan struct{}, func()) {
+	w := &lineWaiter{text: text, ch: make(chan struct{})}
+
+	m.mu.Lock()
+	m.waiters = append(m.waiters, w)
+	m.mu.Unlock()
+
+	return w.ch, func() { m.remove(w) }
+}
+
+// remove unregisters a waiter.
+func (m *lineMatcher) remove(w *lineWaiter) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i, other := range m.waiters {
+		if other == w {
+			m.waiters = append(m.waiters[:i], m.waiters[i+1:]...)
+			return
+		}
+	}
+}
+
+// match notifies and unregisters all waiters whose text occurs in line.
+func (m *lineMatcher) match(line string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if len(m.waiters) == 0 {
+		return
+	}
+
+	remaining := m.waiters[:0]
+	for _, w := range m.waiters {
+		if strings.Contains(line, w.text) {
+			close(w.ch)
+		} else {
+			remaining = append(remaining, w)
+		}
+	}
+	m.waiters = remaining
+}
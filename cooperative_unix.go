@@ -0,0 +1,111 @@
+//go:build linux || darwin
+
+// Package processctrl Unix cooperative pause implementation
+//
+// This file contains the cooperative pause and resume sequences using
+// catchable signals, with SIGSTOP as the fallback.
+
+package processctrl
+
+import (
+	"fmt"
+	"syscall"
+	"time"
+)
+
+// cooperativePollInterval is how often the stopped state is checked while
+// waiting for a cooperative pause to be acknowledged.
+const cooperativePollInterval = 10 * time.Millisecond
+
+// applyPlatformDefaults fills in the default pause signal.
+func (c *CooperativePause) applyPlatformDefaults() error {
+	if c.Signal == nil {
+		c.Signal = syscall.SIGTSTP
+	}
+	if _, ok := c.Signal.(syscall.Signal); !ok {
+		return fmt.Errorf("unsupported signal type %T", c.Signal)
+	}
+	if c.ResumeSignal != nil {
+		if _, ok := c.ResumeSignal.(syscall.Signal); !ok {
+			return fmt.Errorf("unsupported signal type %T", c.ResumeSignal)
+		}
+	}
+	return nil
+}
+
+// pauseCooperative sends the catchable pause signal and waits for the
+// process to acknowledge it. It reports whether the process has already
+// stopped itself, in which case no SIGSTOP is needed.
+// It must be called with p.mu held, which is released during the wait so
+// that output consumers are not blocked while the acknowledgement is
+// printed.
+func (p *Process) pauseCooperative(c *CooperativePause) (bool, error) {
+	var ack <-chan struct{}
+	if c.AckMarker != "" {
+		var cancel func()
+		ack, cancel = p.lines.expect(c.AckMarker)
+		defer cancel()
+	}
+
+	if err := p.signal(c.Signal.(syscall.Signal)); err != nil {
+		return false, fmt.Errorf("failed to send pause request: %w", err)
+	}
+
+	var stopped bool
+	err := p.waitUnlocked(func() error {
+		ticker := time.NewTicker(cooperativePollInterval)
+		defer ticker.Stop()
+		timeout := time.After(c.Timeout)
+
+		for {
+			if s, err := p.isStopped(); err == nil && s {
+				stopped = true
+				return nil
+			}
+
+			select {
+			case <-ack:
+				// Ready to be stopped; it may also have stopped itself
+				s, err := p.isStopped()
+				stopped = err == nil && s
+				return nil
+			case <-timeout:
+				return nil
+			case <-p.exited:
+				return fmt.Errorf("process exited during pause")
+			case <-ticker.C:
+			}
+		}
+	})
+	if err != nil {
+		return false, err
+	}
+	return stopped, nil
+}
+
+// resumeCooperative sends the optional resume signal after SIGCONT and
+// waits for the resume acknowledgement if a marker is configured.
+// The ack channel must have been registered before SIGCONT was sent.
+// It must be called with p.mu held, which is released during the wait.
+func (p *Process) resumeCooperative(c *CooperativePause, ack <-chan struct{}) error {
+	if c.ResumeSignal != nil {
+		if err := p.signal(c.ResumeSignal.(syscall.Signal)); err != nil {
+			return fmt.Errorf("failed to send resume notification: %w", err)
+		}
+	}
+
+	if ack == nil {
+		return nil
+	}
+
+	wait := func() error {
+		select {
+		case <-ack:
+		case <-time.After(c.Timeout):
+		case <-p.exited:
+		}
+		return nil
+	}
+	_ = p.waitUnlocked(wait) // Ignore error - the process was continued either way
+	return nil
+}
@@ -0,0 +1,176 @@
+//go:build linux || darwin
+
+package processctrl
+
+import (
+	"runtime"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestCooperativePauseSelfStop(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("self-stop detection requires Linux")
+	}
+
+	// sleep does not handle SIGTSTP, so the default action stops it
+	proc := New("sleep", "10")
+	if err := proc.SetCooperativePause(&CooperativePause{Timeout: testTimeout * time.Second}); err != nil {
+		t.Fatalf("SetCooperativePause() failed: %v", err)
+	}
+	startTest(t, proc)
+
+	start := time.Now()
+	if err := proc.Pause(); err != nil {
+		t.Fatalf("Pause() failed: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed >= testTimeout*time.Second {
+		t.Errorf("Pause() waited for the timeout although the process stopped itself")
+	}
+	if !proc.IsPaused() {
+		t.Error("Process should be paused")
+	}
+
+	if err := proc.Resume(); err != nil {
+		t.Fatalf("Resume() failed: %v", err)
+	}
+}
+
+func TestCooperativePauseAckMarker(t *testing.T) {
+	cfg := &CooperativePause{
+		AckMarker:    "ready-to-pause",
+		ResumeMarker: "resumed",
+		Timeout:      testTimeout * time.Second,
+	}
+	script := `trap 'echo ready-to-pause' TSTP; trap 'echo resumed' CONT; echo started; while :; do sleep 0.05; done`
+	proc := NewWithBuffer(testBufferSize, "sh", "-c", script)
+	if err := proc.SetCooperativePause(cfg); err != nil {
+		t.Fatalf("SetCooperativePause() failed: %v", err)
+	}
+
+	if line := <-startTest(t, proc); line != "started" {
+		t.Fatalf("Unexpected first line: %q", line)
+	}
+
+	start := time.Now()
+	if err := proc.Pause(); err != nil {
+		t.Fatalf("Pause() failed: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed >= cfg.Timeout {
+		t.Errorf("Pause() did not react to the acknowledgement marker (took %v)", elapsed)
+	}
+	if !proc.IsPaused() {
+		t.Error("Process should be paused")
+	}
+
+	start = time.Now()
+	if err := proc.Resume(); err != nil {
+		t.Fatalf("Resume() failed: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed >= cfg.Timeout {
+		t.Errorf("Resume() did not react to the acknowledgement marker (took %v)", elapsed)
+	}
+}
+
+func TestCooperativePauseConsumerQueriesState(t *testing.T) {
+	cfg := &CooperativePause{
+		AckMarker: "ready-to-pause",
+		Timeout:   testTimeout * time.Second,
+	}
+	proc := New("sh", "-c", `trap 'echo saving; echo saved; echo ready-to-pause' TSTP; echo started; while :; do sleep 0.05; done`)
+	if err := proc.SetCooperativePause(cfg); err != nil {
+		t.Fatalf("SetCooperativePause() failed: %v", err)
+	}
+	stdout, stderr, err := proc.Run()
+	if err != nil {
+		t.Fatalf("Run() failed: %v", err)
+	}
+	go func() {
+		for range stderr {
+		}
+	}()
+	defer func() { _ = proc.Kill() }()
+
+	if line := <-stdout; line != "started" {
+		t.Fatalf("Unexpected first line: %q", line)
+	}
+	// The unbuffered consumer queries the state for every line, so the
+	// acknowledgement is only read if Pause does not block it
+	go func() {
+		for range stdout {
+			_ = proc.IsPaused()
+		}
+	}()
+
+	start := time.Now()
+	if err := proc.Pause(); err != nil {
+		t.Fatalf("Pause() failed: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed >= cfg.Timeout {
+		t.Errorf("Pause() blocked the output consumer until the timeout (took %v)", elapsed)
+	}
+}
+
+func TestCooperativePauseTimeoutFallback(t *testing.T) {
+	cfg := &CooperativePause{
+		Signal:  syscall.SIGUSR1,
+		Timeout: 200 * time.Millisecond,
+	}
+	// The pause request is ignored, so SIGSTOP must be used after the timeout
+	proc := NewWithBuffer(testBufferSize, "sh", "-c", `trap '' USR1; echo started; while :; do sleep 0.05; done`)
+	if err := proc.SetCooperativePause(cfg); err != nil {
+		t.Fatalf("SetCooperativePause() failed: %v", err)
+	}
+
+	if line := <-startTest(t, proc); line != "started" {
+		t.Fatalf("Unexpected first line: %q", line)
+	}
+
+	start := time.Now()
+	if err := proc.Pause(); err != nil {
+		t.Fatalf("Pause() failed: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < cfg.Timeout {
+		t.Errorf("Pause() returned before the acknowledgement timeout (took %v)", elapsed)
+	}
+	if !proc.IsPaused() {
+		t.Error("Process should be paused after the SIGSTOP fallback")
+	}
+}
+
+func TestSetCooperativePauseInvalid(t *testing.T) {
+	proc := New("sleep", "1")
+	if err := proc.SetCooperativePause(&CooperativePause{Timeout: -time.Second}); err == nil {
+		t.Error("SetCooperativePause() should reject a negative timeout")
+	}
+	if err := proc.SetCooperativePause(nil); err != nil {
+		t.Errorf("SetCooperativePause(nil) failed: %v", err)
+	}
+}
+
+func TestPauseUntilAfterSlowPause(t *testing.T) {
+	cfg := &CooperativePause{
+		Signal:  syscall.SIGUSR1,
+		Timeout: 500 * time.Millisecond,
+	}
+	// The pause request is ignored, so pausing takes the whole timeout
+	proc := NewWithBuffer(testBufferSize, "sh", "-c", `trap '' USR1; echo started; while :; do sleep 0.05; done`)
+	if err := proc.SetCooperativePause(cfg); err != nil {
+		t.Fatalf("SetCooperativePause() failed: %v", err)
+	}
+
+	if line := <-startTest(t, proc); line != "started" {
+		t.Fatalf("Unexpected first line: %q", line)
+	}
+
+	resumeAt := time.Now().Add(600 * time.Millisecond)
+	if err := proc.PauseUntil(resumeAt); err != nil {
+		t.Fatalf("PauseUntil() failed: %v", err)
+	}
+	events := expectEvents(t, proc.Events(), EventResumed)
+	resumed := events[len(events)-1].Time
+	if late := resumed.Sub(resumeAt); late > 300*time.Millisecond {
+		t.Errorf("Scheduled resume fired %v late", late)
+	}
+}
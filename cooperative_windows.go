@@ -0,0 +1,14 @@
+//go:build windows
+
+// Package processctrl Windows cooperative pause stub
+//
+// Windows suspends processes with NtSuspendProcess and has no pause
+// request that processes could handle, so cooperative pausing is not
+// supported.
+
+package processctrl
+
+// applyPlatformDefaults rejects cooperative pausing on Windows.
+func (c *CooperativePause) applyPlatformDefaults() error {
+	return ErrNotSupported
+}
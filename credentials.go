@@ -0,0 +1,200 @@
+package processctrl
+
+import (
+	"fmt"
+	"os"
+	"os/user"
+	"strconv"
+	"strings"
+)
+
+// Credentials specifies the user and groups a process runs as. Users and
+// groups are given by name or as a number; numbers do not have to exist
+// in the user database.
+type Credentials struct {
+	User       string   // User name or numeric user ID
+	Group      string   // Group name or numeric group ID; defaults to the user's primary group
+	Groups     []string // Supplementary groups by name or numeric group ID
+	InitGroups bool     // Also add the groups the user is a member of in the group database
+	SetEnv     bool     // Set HOME, USER and LOGNAME for the user; requires the user in the user database
+}
+
+// credentials are resolved Credentials.
+type credentials struct {
+	uid, gid uint32
+	groups   []uint32
+	env      map[string]string // Environment variables to set, nil if SetEnv is not used
+}
+
+// SetCredentials makes the process run as the given user and groups, or
+// as the controller's own user if c is nil. It must be called before the
+// process is started. Users and groups are resolved immediately, so
+// mistakes are reported before the process is started.
+//
+// The supplementary groups of the process are exactly Groups, plus the
+// user's groups if InitGroups is set; the controller's supplementary
+// groups are not passed on, unless the controller runs in a user
+// namespace that denies setgroups(2) and no groups are given. Changing
+// the user requires CAP_SETUID and CAP_SETGID, e.g. running as root.
+//
+// Only Linux and macOS are supported; other platforms return
+// ErrNotSupported.
+//
+// Returns an error if a user or group does not exist, SetEnv is used for
+// a user that is not in the user database, supplementary groups cannot be
+// set in the current user namespace, or the process is running.
+func (p *Process) SetCredentials(c *Credentials) error {
+	if !credentialsSupported {
+		return ErrNotSupported
+	}
+
+	var resolved *credentials
+	if c != nil {
+		var err error
+		if resolved, err = resolveCredentials(c); err != nil {
+			return err
+		}
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.running {
+		return fmt.Errorf("cannot change the credentials of a running process")
+	}
+	p.creds = resolved
+	return nil
+}
+
+// resolveCredentials looks up the users and groups of c.
+func resolveCredentials(c *Credentials) (*credentials, error) {
+	if c.User == "" {
+		return nil, fmt.Errorf("no user specified")
+	}
+
+	u, err := lookupUser(c.User)
+	if err != nil {
+		return nil, err
+	}
+	uid, err := parseID(c.User)
+	if u != nil {
+		uid, err = parseID(u.Uid)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid user ID %q: %w", c.User, err)
+	}
+
+	var gid uint32
+	switch {
+	case c.Group != "":
+		if gid, err = lookupGroup(c.Group); err != nil {
+			return nil, err
+		}
+	case u != nil:
+		if gid, err = parseID(u.Gid); err != nil {
+			return nil, fmt.Errorf("invalid primary group of user %q: %w", c.User, err)
+		}
+	default:
+		return nil, fmt.Errorf("user %q is not in the user database, a group must be specified", c.User)
+	}
+
+	names := append([]string(nil), c.Groups...)
+	if c.InitGroups {
+		if u == nil {
+			return nil, fmt.Errorf("user %q is not in the user database, cannot look up its groups", c.User)
+		}
+		ids, err := u.GroupIds()
+		if err != nil {
+			return nil, fmt.Errorf("failed to look up groups of user %q: %w", c.User, err)
+		}
+		names = append(names, ids...)
+	}
+	groups := []uint32{}
+	seen := make(map[uint32]bool)
+	for _, name := range names {
+		g, err := lookupGroup(name)
+		if err != nil {
+			return nil, err
+		}
+		if !seen[g] {
+			seen[g] = true
+			groups = append(groups, g)
+		}
+	}
+	if len(groups) > 0 && !setgroupsAllowed() {
+		return nil, fmt.Errorf("supplementary groups cannot be set in this user namespace")
+	}
+
+	creds := &credentials{uid: uid, gid: gid, groups: groups}
+	if c.SetEnv {
+		if u == nil {
+			return nil, fmt.Errorf("user %q is not in the user database, cannot set HOME", c.User)
+		}
+		creds.env = map[string]string{"HOME": u.HomeDir, "USER": u.Username, "LOGNAME": u.Username}
+	}
+	return creds, nil
+}
+
+// lookupUser looks up a user by name or ID. It returns nil without an
+// error for a numeric ID that is not in the user database.
+func lookupUser(name string) (*user.User, error) {
+	if _, err := parseID(name); err == nil {
+		u, err := user.LookupId(name)
+		if _, ok := err.(user.UnknownUserIdError); ok {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to look up user %q: %w", name, err)
+		}
+		return u, nil
+	}
+
+	u, err := user.Lookup(name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up user %q: %w", name, err)
+	}
+	return u, nil
+}
+
+// lookupGroup returns the ID of a group given by name or ID.
+func lookupGroup(name string) (uint32, error) {
+	if gid, err := parseID(name); err == nil {
+		return gid, nil
+	}
+	g, err := user.LookupGroup(name)
+	if err != nil {
+		return 0, fmt.Errorf("failed to look up group %q: %w", name, err)
+	}
+	gid, err := parseID(g.Gid)
+	if err != nil {
+		return 0, fmt.Errorf("invalid ID of group %q: %w", name, err)
+	}
+	return gid, nil
+}
+
+// parseID parses a numeric user or group ID.
+func parseID(s string) (uint32, error) {
+	id, err := strconv.ParseUint(s, 10, 32)
+	return uint32(id), err
+}
+
+// credentialEnv returns the environment of the child with the variables
+// of the credentials replaced.
+func credentialEnv(env []string, set map[string]string) []string {
+	if env == nil {
+		env = os.Environ()
+	}
+	result := make([]string, 0, len(env)+len(set))
+	for _, kv := range env {
+		name, _, _ := strings.Cut(kv, "=")
+		if _, ok := set[name]; !ok {
+			result = append(result, kv)
+		}
+	}
+	for _, name := range []string{"HOME", "USER", "LOGNAME"} {
+		if value, ok := set[name]; ok {
+			result = append(result, name+"="+value)
+		}
+	}
+	return result
+}
@@ -0,0 +1,155 @@
+//go:build linux
+
+package processctrl
+
+import (
+	"os"
+	"os/exec"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+// userNSEnv marks a test binary re-executed in a user namespace.
+const userNSEnv = "PROCESSCTRL_TEST_USERNS"
+
+// runInUserNamespace re-executes the current test in a new user namespace
+// and reports its result. In the namespace the test runs as root, mapped
+// to the user running the tests, so it works without privileges. If the
+// tests run as root, the IDs 1000-1999 are mapped as well.
+// It reports false if the caller is already running in the namespace.
+func runInUserNamespace(t *testing.T) bool {
+	t.Helper()
+	return reexecInUserNamespace(t, os.Getuid() == 0)
+}
+
+// reexecInUserNamespace is runInUserNamespace; unless setgroups is set,
+// only root is mapped and setgroups(2) is denied in the namespace, as in
+// one created without privileges.
+func reexecInUserNamespace(t *testing.T, setgroups bool) bool {
+	t.Helper()
+
+	if os.Getenv(userNSEnv) != "" {
+		return false
+	}
+
+	cmd := exec.Command(os.Args[0], "-test.run=^"+t.Name()+"$", "-test.v")
+	cmd.Env = append(os.Environ(), userNSEnv+"=1")
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		Cloneflags:  syscall.CLONE_NEWUSER,
+		UidMappings: []syscall.SysProcIDMap{{ContainerID: 0, HostID: os.Getuid(), Size: 1}},
+		GidMappings: []syscall.SysProcIDMap{{ContainerID: 0, HostID: os.Getgid(), Size: 1}},
+	}
+	if setgroups {
+		extra := syscall.SysProcIDMap{ContainerID: 1000, HostID: 100000, Size: 1000}
+		cmd.SysProcAttr.UidMappings = append(cmd.SysProcAttr.UidMappings, extra)
+		cmd.SysProcAttr.GidMappings = append(cmd.SysProcAttr.GidMappings, extra)
+		cmd.SysProcAttr.GidMappingsEnableSetgroups = true
+	}
+
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		if _, ok := err.(*exec.ExitError); !ok {
+			t.Skipf("User namespaces not available: %v", err)
+		}
+		t.Fatalf("Test failed in user namespace: %v\n%s", err, out)
+	}
+	if strings.Contains(string(out), "--- SKIP") {
+		t.Skipf("Test skipped in user namespace:\n%s", out)
+	}
+	return true
+}
+
+// runAs runs a shell script with the given credentials and returns its
+// output lines.
+func runAs(t *testing.T, c *Credentials, args ...string) []string {
+	t.Helper()
+
+	proc := New("sh", append([]string{"-c"}, args...)...)
+	if err := proc.SetCredentials(c); err != nil {
+		t.Fatalf("SetCredentials() failed: %v", err)
+	}
+	lines, result := runCollect(t, proc)
+	if result.ExitCode() != 0 {
+		t.Fatalf("ExitCode() = %d, want 0", result.ExitCode())
+	}
+	return lines
+}
+
+func TestCredentialsInUserNamespace(t *testing.T) {
+	if runInUserNamespace(t) {
+		return
+	}
+
+	// Root of the namespace exists in every user database
+	lines := runAs(t, &Credentials{User: "root", SetEnv: true}, `id -u; id -g; echo "$HOME $USER $LOGNAME"`)
+	want := []string{"0", "0", "/root root root"}
+	if strings.Join(lines, "\n") != strings.Join(want, "\n") {
+		t.Errorf("Output = %q, want %q", lines, want)
+	}
+
+	if !setgroupsAllowed() {
+		// Unprivileged namespace, only root is mapped
+		if err := New("true").SetCredentials(&Credentials{User: "0", Groups: []string{"0"}}); err == nil {
+			t.Error("SetCredentials() with groups succeeded although setgroups is denied")
+		}
+		return
+	}
+
+	lines = runAs(t, &Credentials{User: "1000", Group: "1001", Groups: []string{"1002", "1003"}}, "id -u; id -g; id -G")
+	want = []string{"1000", "1001", "1001 1002 1003"}
+	if strings.Join(lines, "\n") != strings.Join(want, "\n") {
+		t.Errorf("Output = %q, want %q", lines, want)
+	}
+}
+
+func TestCredentialsSetgroupsDenied(t *testing.T) {
+	if reexecInUserNamespace(t, false) {
+		return
+	}
+	if setgroupsAllowed() {
+		t.Fatal("setgroups is allowed in the namespace")
+	}
+
+	// Changing credentials calls setgroups(2) unless told not to, which
+	// fails here
+	cmd := exec.Command("true")
+	cmd.SysProcAttr = &syscall.SysProcAttr{Credential: &syscall.Credential{}}
+	if err := cmd.Run(); err == nil {
+		t.Fatal("Run() with setgroups succeeded although setgroups is denied")
+	}
+
+	lines := runAs(t, &Credentials{User: "0"}, "id -u")
+	if strings.Join(lines, "\n") != "0" {
+		t.Errorf("Output = %q, want 0", lines)
+	}
+}
+
+func TestSetCredentialsInvalid(t *testing.T) {
+	proc := New("true")
+	for _, c := range []*Credentials{
+		{},
+		{User: "no-such-user-processctrl"},
+		{User: "root", Group: "no-such-group-processctrl"},
+		{User: "root", Groups: []string{"no-such-group-processctrl"}},
+		{User: "4000000000"},                           // Unknown ID without group
+		{User: "4000000000", Group: "0", SetEnv: true}, // No home directory
+		{User: "4000000000", Group: "0", InitGroups: true},
+	} {
+		if err := proc.SetCredentials(c); err == nil {
+			t.Errorf("SetCredentials(%+v) succeeded, want error", c)
+		}
+	}
+	if err := proc.SetCredentials(&Credentials{User: "4000000000", Group: "0"}); err != nil {
+		t.Errorf("SetCredentials() with unknown numeric user failed: %v", err)
+	}
+}
+
+func TestCredentialEnv(t *testing.T) {
+	env := credentialEnv([]string{"PATH=/bin", "HOME=/root", "USER=root"},
+		map[string]string{"HOME": "/home/u", "USER": "u", "LOGNAME": "u"})
+	want := []string{"PATH=/bin", "HOME=/home/u", "USER=u", "LOGNAME=u"}
+	if strings.Join(env, " ") != strings.Join(want, " ") {
+		t.Errorf("credentialEnv() = %v, want %v", env, want)
+	}
+}
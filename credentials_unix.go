@@ -0,0 +1,52 @@
+//go:build linux || darwin
+
+// Package processctrl Unix credentials
+//
+// This file makes child processes run as a different user and groups.
+// The IDs are changed by the standard library between fork and exec, so
+// the program never runs with the controller's credentials.
+
+package processctrl
+
+import (
+	"os"
+	"strings"
+	"syscall"
+)
+
+// credentialsSupported reports whether SetCredentials is available.
+const credentialsSupported = true
+
+// setgroupsAllowed reports whether setgroups(2) is permitted, which is
+// not the case in user namespaces created by unprivileged users.
+func setgroupsAllowed() bool {
+	data, err := os.ReadFile("/proc/self/setgroups")
+	if err != nil {
+		// No user namespaces on this system
+		return true
+	}
+	return strings.TrimSpace(string(data)) != "deny"
+}
+
+// applyCredentials configures the command to run with the credentials
+// set with SetCredentials.
+// It must be called with p.mu held before the command is started.
+func (p *Process) applyCredentials() {
+	if p.creds == nil {
+		return
+	}
+	if p.cmd.SysProcAttr == nil {
+		p.cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	p.cmd.SysProcAttr.Credential = &syscall.Credential{
+		Uid:    p.creds.uid,
+		Gid:    p.creds.gid,
+		Groups: p.creds.groups,
+		// setgroups(2) is not permitted in some user namespaces; without
+		// supplementary groups there is nothing to set then
+		NoSetGroups: len(p.creds.groups) == 0 && !setgroupsAllowed(),
+	}
+	if p.creds.env != nil {
+		p.cmd.Env = credentialEnv(p.cmd.Env, p.creds.env)
+	}
+}
@@ -0,0 +1,19 @@
+//go:build windows
+
+// Package processctrl Windows credentials stub
+//
+// Running child processes as a different user requires a logon token on
+// Windows, which is not supported.
+
+package processctrl
+
+// credentialsSupported reports whether SetCredentials is available.
+const credentialsSupported = false
+
+// setgroupsAllowed is only meaningful on Unix.
+func setgroupsAllowed() bool {
+	return true
+}
+
+// applyCredentials is a no-op on Windows.
+func (p *Process) applyCredentials() {}
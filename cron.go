@@ -0,0 +1,202 @@
+package processctrl
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CronWindow is a window that opens at the times matched by a cron
+// expression and stays open for a fixed duration.
+type CronWindow struct {
+	spec     string
+	duration time.Duration
+	minutes  uint64 // bit set of minutes 0-59
+	hours    uint64 // bit set of hours 0-23
+	dom      uint64 // bit set of days of the month 1-31
+	months   uint64 // bit set of months 1-12
+	dow      uint64 // bit set of weekdays 0-6 (Sunday is 0)
+	domStar  bool   // day of month field starts with "*"
+	dowStar  bool   // day of week field starts with "*"
+}
+
+// cronField describes the valid range and names of a cron field.
+type cronField struct {
+	name     string
+	min, max int
+	names    map[string]int
+}
+
+var (
+	cronMinutes = cronField{name: "minute", min: 0, max: 59}
+	cronHours   = cronField{name: "hour", min: 0, max: 23}
+	cronDom     = cronField{name: "day of month", min: 1, max: 31}
+	cronMonths  = cronField{name: "month", min: 1, max: 12, names: map[string]int{
+		"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+		"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+	}}
+	cronDow = cronField{name: "day of week", min: 0, max: 7, names: map[string]int{
+		"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+	}}
+)
+
+// ParseCronWindow parses a standard five-field cron expression
+// ("minute hour day-of-month month day-of-week") and returns a window that
+// opens at every matching time and stays open for the given duration.
+// Fields support "*", lists, ranges, steps and three-letter month and
+// weekday names. As in cron, if both day fields are restricted a day
+// matches if either of them matches. Sunday is 0 or 7.
+//
+// For example, ParseCronWindow("0 22 * * mon-fri", 8*time.Hour) allows the
+// process to run from 22:00 to 06:00 in the nights following weekdays.
+func ParseCronWindow(spec string, duration time.Duration) (*CronWindow, error) {
+	if duration <= 0 || duration > scheduleLookback {
+		return nil, fmt.Errorf("invalid cron window duration: %v", duration)
+	}
+
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("invalid cron expression %q: expected 5 fields", spec)
+	}
+
+	w := &CronWindow{
+		spec:     spec,
+		duration: duration,
+		domStar:  strings.HasPrefix(fields[2], "*"),
+		dowStar:  strings.HasPrefix(fields[4], "*"),
+	}
+
+	targets := []struct {
+		bits  *uint64
+		field cronField
+	}{
+		{&w.minutes, cronMinutes},
+		{&w.hours, cronHours},
+		{&w.dom, cronDom},
+		{&w.months, cronMonths},
+		{&w.dow, cronDow},
+	}
+	for i, t := range targets {
+		bits, err := parseCronField(fields[i], t.field)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cron expression %q: %w", spec, err)
+		}
+		*t.bits = bits
+	}
+
+	// Sunday may be given as 7
+	if w.dow&(1<<7) != 0 {
+		w.dow |= 1
+	}
+	return w, nil
+}
+
+// String returns the cron expression and duration of the window.
+func (w *CronWindow) String() string {
+	return fmt.Sprintf("%s for %v", w.spec, w.duration)
+}
+
+// parseCronField parses a single cron field into a bit set.
+func parseCronField(spec string, f cronField) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(spec, ",") {
+		rangeSpec, stepSpec, hasStep := strings.Cut(part, "/")
+
+		step := 1
+		if hasStep {
+			var err error
+			if step, err = strconv.Atoi(stepSpec); err != nil || step <= 0 {
+				return 0, fmt.Errorf("invalid step %q in %s field", stepSpec, f.name)
+			}
+		}
+
+		lo, hi := f.min, f.max
+		if rangeSpec != "*" {
+			first, last, isRange := strings.Cut(rangeSpec, "-")
+			var err error
+			if lo, err = f.value(first); err != nil {
+				return 0, err
+			}
+			hi = lo
+			if isRange {
+				if hi, err = f.value(last); err != nil {
+					return 0, err
+				}
+			} else if hasStep {
+				hi = f.max
+			}
+			if hi < lo {
+				return 0, fmt.Errorf("invalid range %q in %s field", rangeSpec, f.name)
+			}
+		}
+
+		for v := lo; v <= hi; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, nil
+}
+
+// value parses a single number or name of the field.
+func (f cronField) value(s string) (int, error) {
+	if v, ok := f.names[strings.ToLower(s)]; ok {
+		return v, nil
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil || v < f.min || v > f.max {
+		return 0, fmt.Errorf("invalid value %q in %s field", s, f.name)
+	}
+	return v, nil
+}
+
+// matchesDay reports whether the cron expression matches the given date.
+func (w *CronWindow) matchesDay(date time.Time) bool {
+	if w.months&(1<<uint(date.Month())) == 0 {
+		return false
+	}
+	domMatch := w.dom&(1<<uint(date.Day())) != 0
+	dowMatch := w.dow&(1<<uint(date.Weekday())) != 0
+	switch {
+	case w.domStar && w.dowStar:
+		return true
+	case w.domStar:
+		return dowMatch
+	case w.dowStar:
+		return domMatch
+	default:
+		return domMatch || dowMatch
+	}
+}
+
+// occurrences implements Window.
+func (w *CronWindow) occurrences(from, to time.Time, loc *time.Location) []interval {
+	var out []interval
+
+	// Start early enough to include windows still open at from
+	day := from.Add(-w.duration).In(loc)
+	y, m, d := day.Date()
+	for i := 0; ; i++ {
+		date := time.Date(y, m, d+i, 0, 0, 0, 0, loc)
+		if !date.Before(to) {
+			break
+		}
+		if !w.matchesDay(date) {
+			continue
+		}
+
+		for h := 0; h < 24; h++ {
+			if w.hours&(1<<uint(h)) == 0 {
+				continue
+			}
+			for minute := 0; minute < 60; minute++ {
+				if w.minutes&(1<<uint(minute)) == 0 {
+					continue
+				}
+				start := time.Date(y, m, d+i, h, minute, 0, 0, loc)
+				out = append(out, interval{start: start, end: start.Add(w.duration)})
+			}
+		}
+	}
+	return out
+}
@@ -0,0 +1,121 @@
+package processctrl
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// defaultEventBuffer is the capacity of event channels. Events are dropped
+// rather than blocking the process when the buffer is full.
+const defaultEventBuffer = 64
+
+// EventType identifies the kind of state change reported by an Event.
+type EventType int
+
+const (
+	// EventPaused is emitted when the process was paused with Pause.
+	EventPaused EventType = iota
+	// EventResumed is emitted when the process was resumed with Resume.
+	EventResumed
+	// EventStopped is emitted when the process was stopped outside of
+	// processctrl, e.g. by kill -STOP or SIGTSTP from a terminal.
+	EventStopped
+	// EventContinued is emitted when the process was continued outside
+	// of processctrl, e.g. by kill -CONT.
+	EventContinued
+	// EventExited is emitted when the process has exited.
+	EventExited
+	// EventResumeScheduled is emitted when an automatic resume has been
+	// scheduled by PauseFor or PauseUntil. The message holds the resume time.
+	EventResumeScheduled
+	// EventResumeCanceled is emitted when a scheduled resume was canceled
+	// before it fired. The message holds the reason.
+	EventResumeCanceled
+	// EventAutoPaused is emitted by a PressureGuard when it paused a
+	// process because of host pressure. The message holds the reason.
+	EventAutoPaused
+	// EventAutoResumed is emitted by a PressureGuard when it resumed a
+	// process it had paused. The message holds the current pressure.
+	EventAutoResumed
+	// EventWatchdog is emitted when a watchdog rule fired. The message
+	// holds the rule, the measured value and the action taken.
+	EventWatchdog
+)
+
+// String returns a human-readable name for the event type.
+func (t EventType) String() string {
+	switch t {
+	case EventPaused:
+		return "paused"
+	case EventResumed:
+		return "resumed"
+	case EventStopped:
+		return "stopped"
+	case EventContinued:
+		return "continued"
+	case EventExited:
+		return "exited"
+	case EventResumeScheduled:
+		return "resume-scheduled"
+	case EventResumeCanceled:
+		return "resume-canceled"
+	case EventAutoPaused:
+		return "auto-paused"
+	case EventAutoResumed:
+		return "auto-resumed"
+	case EventWatchdog:
+		return "watchdog"
+	default:
+		return fmt.Sprintf("EventType(%d)", int(t))
+	}
+}
+
+// Event describes a state change of a process.
+type Event struct {
+	Type    EventType // Kind of state change
+	Time    time.Time // Time the change was observed
+	PID     int       // Process ID
+	Message string    // Optional human-readable details
+}
+
+// eventEmitter delivers events on a buffered channel without ever blocking
+// the sender. It is safe for concurrent use.
+type eventEmitter struct {
+	mu     sync.Mutex
+	ch     chan Event
+	closed bool
+}
+
+// newEventEmitter creates an emitter with the default buffer size.
+func newEventEmitter() *eventEmitter {
+	return &eventEmitter{ch: make(chan Event, defaultEventBuffer)}
+}
+
+// emit sends an event of the given type. The event is dropped if the
+// buffer is full or the emitter has been closed.
+func (e *eventEmitter) emit(typ EventType, pid int, message string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.closed {
+		return
+	}
+
+	select {
+	case e.ch <- Event{Type: typ, Time: time.Now(), PID: pid, Message: message}:
+	default:
+		// Buffer full, drop the event
+	}
+}
+
+// close closes the event channel. Subsequent calls are no-ops.
+func (e *eventEmitter) close() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if !e.closed {
+		close(e.ch)
+		e.closed = true
+	}
+}
@@ -18,6 +18,9 @@ import (
 )
 
 func main() {
+	// Let processctrl run its helpers (sandboxes, seccomp, Landlock, images)
+	processctrl.Init()
+
 	// Create a process with buffered channels (buffer size: 10)
 	// Buffered channels help with high-output processes
 	// Use appropriate ping flags for continuous pinging on different platforms
@@ -0,0 +1,152 @@
+package processctrl
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// ExitReason describes why a process ended.
+type ExitReason int
+
+const (
+	// ExitNormal means the process exited on its own or was stopped by a
+	// caller, e.g. with Terminate or by canceling its context.
+	ExitNormal ExitReason = iota
+	// ExitWatchdog means the process was stopped by a watchdog rule.
+	ExitWatchdog
+	// ExitMaxRuntime means the process was stopped because its active
+	// runtime exceeded Timeouts.MaxActive.
+	ExitMaxRuntime
+	// ExitIdleTimeout means the process was stopped because it produced
+	// no output for Timeouts.Idle.
+	ExitIdleTimeout
+	// ExitCPUBudget means the process was stopped because its CPU time
+	// exceeded Timeouts.CPU.
+	ExitCPUBudget
+	// ExitOOMKilled means the process was killed by the kernel's OOM
+	// killer.
+	ExitOOMKilled
+	// ExitSeccompKilled means the process was killed with SIGSYS for
+	// violating its seccomp profile.
+	ExitSeccompKilled
+)
+
+// String returns a human-readable name for the exit reason.
+func (r ExitReason) String() string {
+	switch r {
+	case ExitNormal:
+		return "normal"
+	case ExitWatchdog:
+		return "watchdog"
+	case ExitMaxRuntime:
+		return "max-runtime"
+	case ExitIdleTimeout:
+		return "idle-timeout"
+	case ExitCPUBudget:
+		return "cpu-budget"
+	case ExitOOMKilled:
+		return "oom-killed"
+	case ExitSeccompKilled:
+		return "seccomp-killed"
+	default:
+		return fmt.Sprintf("ExitReason(%d)", int(r))
+	}
+}
+
+// ExitResult describes how a process ended.
+//
+// OOMKilled is exact for a process with a cgroup (see SetCgroup). Without
+// one it is a heuristic based on the system-wide OOM kill counter, so a
+// SIGKILL sent from outside processctrl while any other process on the
+// host is OOM-killed is reported as an OOM kill as well. OOMKilled does
+// not depend on Reason, which holds only the first reason, so it is also
+// set for a process that was OOM-killed while being stopped for another
+// reason. The same holds for SeccompKilled.
+//
+// For a process in a sandbox (see SetSandbox), State is that of the init
+// process of the sandbox, while Signal and ExitCode describe the program.
+type ExitResult struct {
+	State         *os.ProcessState // Exit status as returned by Wait
+	Signal        os.Signal        // Signal that terminated the process, nil if it exited
+	Err           error            // Error returned by Wait, e.g. *exec.ExitError
+	Reason        ExitReason       // Why the process ended
+	Rule          string           // Watchdog rule that stopped the process, if Reason is ExitWatchdog
+	Firings       []RuleFiring     // All watchdog rules that fired during the run
+	OOMKills      uint64           // Processes killed by the OOM killer in the process's cgroup, see SetCgroup
+	OOMKilled     bool             // The process itself was killed by the OOM killer (Linux), see above
+	SeccompKilled bool             // The process was killed for violating its seccomp profile, see SetSeccomp (Linux)
+	ScriptErrors  []ScriptError    // Interpreter messages that refer to lines of the script, see NewScript
+}
+
+// ExitCode returns the exit code of the process, or -1 if it was killed
+// by a signal or its state is unknown.
+func (r *ExitResult) ExitCode() int {
+	if r.State == nil || r.Signal != nil {
+		return -1
+	}
+	return r.State.ExitCode()
+}
+
+// Result blocks until the process has exited and returns the details of
+// how it ended. Unlike Wait, it can be called any number of times, also
+// after the process has exited.
+//
+// Returns an error if the process has not been started.
+func (p *Process) Result() (*ExitResult, error) {
+	p.mu.RLock()
+	started := p.cmd != nil && p.cmd.Process != nil
+	p.mu.RUnlock()
+
+	if !started {
+		return nil, fmt.Errorf("process has not been started")
+	}
+
+	<-p.reaped
+
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	result := *p.result
+	result.Firings = append([]RuleFiring(nil), p.result.Firings...)
+	return &result, nil
+}
+
+// setExitReason records why the process is being stopped. Only the first
+// reason is kept, since later ones are consequences of the first.
+// It must be called with p.mu held.
+func (p *Process) setExitReason(reason ExitReason, rule string) {
+	if p.exitReason != ExitNormal {
+		return
+	}
+	p.exitReason = reason
+	p.exitRule = rule
+}
+
+// recordResult stores the exit result after the process has been reaped.
+// It must be called with p.mu held.
+func (p *Process) recordResult() {
+	p.result = &ExitResult{
+		State:         p.cmd.ProcessState,
+		Signal:        p.exitSignal,
+		Err:           p.waitErr,
+		Reason:        p.exitReason,
+		Rule:          p.exitRule,
+		Firings:       p.firings,
+		OOMKills:      p.oomKills,
+		OOMKilled:     p.oomKilled,
+		SeccompKilled: p.seccompKilled,
+		ScriptErrors:  p.scriptErrors(),
+	}
+}
+
+// signalOf returns the signal that terminated a process with the given
+// exit state, or nil if it exited or the state is unknown.
+func signalOf(state *os.ProcessState) os.Signal {
+	if state == nil {
+		return nil
+	}
+	if ws, ok := state.Sys().(syscall.WaitStatus); ok && ws.Signaled() {
+		return ws.Signal()
+	}
+	return nil
+}
@@ -0,0 +1,27 @@
+package processctrl
+
+import "sync/atomic"
+
+// initCalled is set by Init. Helpers run the program's own executable, so
+// they are only started if its main function calls Init to act as one.
+var initCalled atomic.Bool
+
+// Init runs the helper processes that processctrl starts from the
+// program's own executable on Linux: the init process of a sandbox
+// (SetSandbox) and the helper that applies Landlock rules (SetLandlock)
+// and seccomp filters (SetSeccomp) or executes a program from memory
+// (NewFromBytes, NewFromReader). It must be the first call in main,
+// before flags are parsed or anything else is done:
+//
+//	func main() {
+//		processctrl.Init()
+//		...
+//	}
+//
+// In a helper, Init does not return. Otherwise it returns immediately,
+// and only then are these features enabled; without Init, Run fails for
+// processes that need a helper.
+func Init() {
+	initCalled.Store(true)
+	runHelper()
+}
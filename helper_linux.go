@@ -0,0 +1,407 @@
+//go:build linux
+
+// Package processctrl Linux exec helpers
+//
+// Some settings have to be applied inside the child before the program
+// runs, which the standard library cannot do between fork and exec. For
+// these, the controller starts its own executable as a helper instead of
+// the program. Init, called at the start of main, takes over in the
+// helper, receives the configuration over a socket, applies it
+// and starts or executes the program. A helper that executes the program
+// closes the socket with the exec; one that stays running replies with
+// the PID of the program and later its exit status. Errors are reported
+// over the socket as well, so they are returned by Run like other start
+// errors.
+
+package processctrl
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"runtime"
+	"syscall"
+	"time"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// helperEnv selects the helper the controller's executable runs as.
+const helperEnv = "PROCESSCTRL_HELPER"
+
+// Helpers selected by helperEnv.
+const (
+	helperSandbox = "sandbox" // Init process of a sandbox, see sandbox_init_linux.go
+	helperExec    = "exec"    // Applies Landlock rules, resource limits and a seccomp filter and executes the program or its image
+)
+
+// Descriptors passed to helpers and programs, see setExtraFile.
+const (
+	helperFD      = 3 // Helper's end of the socket to the controller
+	helperImageFD = 4 // Program image, if the program is executed from memory
+	scriptFD      = 5 // Script source, read by the interpreter through /proc/self/fd
+)
+
+// helperStartTimeout limits how long Run waits for a helper to start the
+// program.
+const helperStartTimeout = 10 * time.Second
+
+// execConfig is sent to the exec helper.
+type execConfig struct {
+	Path     string
+	Args     []string
+	Image    bool // Execute helperImageFD rather than Path
+	Landlock *landlockRules
+	Seccomp  *seccompRules
+	Rlimits  map[RlimitResource]Rlimit
+}
+
+// helperReply is sent by a helper to the controller.
+type helperReply struct {
+	Error  string           // The helper failed
+	PID    int              // PID of the program in the helper's PID namespace
+	Status *unix.WaitStatus // Exit status of the program
+	Killed bool             // The program was killed for an exec its seccomp profile denies
+}
+
+// helperStart is a helper that is being started.
+type helperStart struct {
+	conn   *os.File // Controller's end of the socket
+	child  *os.File // Helper's end of the socket
+	dec    *json.Decoder
+	config any
+
+	// onListener is set if the helper sends the listener of a seccomp
+	// filter for its exec (see sendExecListener) and must start to
+	// supervise it, since the helper cannot execute the program before
+	onListener func(listener *os.File)
+}
+
+// newHelper replaces the program of cmd with the helper kind, which
+// receives config once it has been started.
+func newHelper(cmd *exec.Cmd, kind string, config any) (*helperStart, error) {
+	if !initCalled.Load() {
+		return nil, fmt.Errorf("processctrl.Init must be called at the start of main to start a %s helper", kind)
+	}
+	fds, err := unix.Socketpair(unix.AF_UNIX, unix.SOCK_STREAM|unix.SOCK_CLOEXEC, 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create helper socket: %w", err)
+	}
+	// A non-blocking descriptor supports read deadlines
+	if err := unix.SetNonblock(fds[0], true); err != nil {
+		_ = unix.Close(fds[0]) // Ignore error during cleanup
+		_ = unix.Close(fds[1]) // Ignore error during cleanup
+		return nil, fmt.Errorf("failed to create helper socket: %w", err)
+	}
+	h := &helperStart{
+		conn:   os.NewFile(uintptr(fds[0]), "helper"),
+		child:  os.NewFile(uintptr(fds[1]), "helper-child"),
+		config: config,
+	}
+
+	env := cmd.Env
+	if env == nil {
+		env = os.Environ()
+	}
+	cmd.Env = append(env[:len(env):len(env)], helperEnv+"="+kind)
+	cmd.Path = "/proc/self/exe"
+	cmd.Args = []string{"processctrl-" + kind}
+	setExtraFile(cmd, helperFD, h.child)
+	return h, nil
+}
+
+// setExtraFile makes f the descriptor fd of the command's process. The
+// ExtraFiles of a command start at descriptor 3; unset ones are closed.
+func setExtraFile(cmd *exec.Cmd, fd int, f *os.File) {
+	for len(cmd.ExtraFiles) <= fd-3 {
+		cmd.ExtraFiles = append(cmd.ExtraFiles, nil)
+	}
+	cmd.ExtraFiles[fd-3] = f
+}
+
+// run sends the configuration to the started helper and waits until it
+// has started the program. A helper that stays running, like the init
+// process of a sandbox, replies with the PID of the program and the
+// socket is kept open for its exit status; a helper that executes the
+// program closes the socket and nil is returned. On error the caller must
+// kill and reap the helper.
+func (h *helperStart) run() (*helperReply, error) {
+	_ = h.child.Close() // Ignore error - the helper has its own copy
+
+	_ = h.conn.SetDeadline(time.Now().Add(helperStartTimeout))
+	if err := json.NewEncoder(h.conn).Encode(h.config); err != nil {
+		_ = h.conn.Close() // Ignore error during cleanup
+		return nil, fmt.Errorf("failed to send configuration to helper: %w", err)
+	}
+	h.dec = json.NewDecoder(h.conn)
+	var err error
+	if h.onListener != nil {
+		err = h.readListener()
+	}
+	var reply *helperReply
+	if err == nil {
+		reply, err = h.read()
+	}
+	if err != nil || reply == nil || reply.Error != "" {
+		_ = h.conn.Close() // Ignore error during cleanup
+	}
+	switch {
+	case err != nil:
+		return nil, fmt.Errorf("failed to wait for helper: %w", err)
+	case reply != nil && reply.Error != "":
+		return nil, errors.New(reply.Error)
+	}
+	return reply, nil
+}
+
+// readListener receives the seccomp listener sent by the helper and passes
+// it to onListener. A helper that fails before sends a reply instead,
+// which is left to read.
+func (h *helperStart) readListener() error {
+	rc, err := h.conn.SyscallConn()
+	if err != nil {
+		return err
+	}
+	buf := make([]byte, 1)
+	oob := make([]byte, unix.CmsgSpace(4))
+	var n, oobn int
+	var recvErr error
+	err = rc.Read(func(fd uintptr) bool {
+		n, oobn, _, _, recvErr = unix.Recvmsg(int(fd), buf, oob, unix.MSG_CMSG_CLOEXEC)
+		return recvErr != unix.EAGAIN
+	})
+	if err == nil {
+		err = recvErr
+	}
+	if err != nil {
+		return err
+	}
+	h.dec = json.NewDecoder(io.MultiReader(bytes.NewReader(buf[:n]), h.conn))
+
+	msgs, err := unix.ParseSocketControlMessage(oob[:oobn])
+	if err != nil {
+		return err
+	}
+	for _, msg := range msgs {
+		fds, err := unix.ParseUnixRights(&msg)
+		if err != nil {
+			continue
+		}
+		for _, fd := range fds {
+			if h.onListener == nil {
+				_ = unix.Close(fd) // Ignore error - only one listener is expected
+				continue
+			}
+			// A non-blocking descriptor is polled by the runtime
+			if err := unix.SetNonblock(fd, true); err != nil {
+				_ = unix.Close(fd) // Ignore error during cleanup
+				return err
+			}
+			h.onListener(os.NewFile(uintptr(fd), "seccomp-listener"))
+			h.onListener = nil
+		}
+	}
+	return nil
+}
+
+// read returns the next reply of a started helper, or nil once the
+// helper has closed the socket.
+func (h *helperStart) read() (*helperReply, error) {
+	var reply helperReply
+	if err := h.dec.Decode(&reply); err != nil {
+		if err == io.EOF {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &reply, nil
+}
+
+// close releases the socket of a helper that was not started.
+func (h *helperStart) close() {
+	_ = h.conn.Close()  // Ignore error during cleanup
+	_ = h.child.Close() // Ignore error during cleanup
+}
+
+// prepareExecHelper replaces the command with the exec helper if the
+// program is executed from memory or needs Landlock rules or a seccomp
+// filter. The helper then sets the resource limits as well. In a
+// sandbox, the init process does this instead.
+// It must be called with p.mu held before the command is started.
+func (p *Process) prepareExecHelper() error {
+	if p.image == nil && p.landlockRules == nil && p.seccomp == nil {
+		return nil
+	}
+	if p.sandbox != nil || p.cmd.Err != nil {
+		return nil
+	}
+	h, err := newHelper(p.cmd, helperExec, execConfig{
+		Path:     p.cmd.Path,
+		Args:     p.cmd.Args,
+		Image:    p.image != nil,
+		Landlock: p.landlockRules,
+		Seccomp:  p.seccomp,
+		Rlimits:  p.rlimits,
+	})
+	if err != nil {
+		return err
+	}
+	if p.seccomp != nil && p.seccomp.deniesExec() {
+		h.onListener = p.watchExec
+	}
+	p.helper = h
+	p.passImage()
+	return nil
+}
+
+// passImage passes the program image to the helper as helperImageFD.
+func (p *Process) passImage() {
+	if p.image != nil {
+		setExtraFile(p.cmd, helperImageFD, p.image)
+	}
+}
+
+// startHelper completes the start of the helper of the process. If the
+// helper fails, it is killed and reaped.
+// It must be called with p.mu held after the command has been started.
+func (p *Process) startHelper() error {
+	h := p.helper
+	if h == nil {
+		return nil
+	}
+	p.helper = nil
+	reply, err := h.run()
+	if err != nil {
+		_ = p.signal(syscall.SIGKILL) // Ignore error - the helper might already be dead
+		_ = p.cmd.Wait()              // Ignore error - the helper failed
+		p.releaseExecListener()
+		return err
+	}
+	if reply != nil {
+		p.watchSandbox(h, reply.PID)
+	}
+	return nil
+}
+
+// releaseHelper closes the socket of a helper that was not started.
+func (p *Process) releaseHelper() {
+	if p.helper != nil {
+		p.helper.close()
+		p.helper = nil
+	}
+}
+
+// runHelper runs the helper if the process was started as one, and
+// returns otherwise.
+func runHelper() {
+	kind := os.Getenv(helperEnv)
+	if kind == "" {
+		return
+	}
+	_ = os.Unsetenv(helperEnv) // Ignore error - the variable is set
+	syscall.CloseOnExec(helperFD)
+	conn := os.NewFile(helperFD, "helper")
+
+	switch kind {
+	case helperSandbox:
+		os.Exit(runSandboxInit(conn))
+	case helperExec:
+		os.Exit(runExecHelper(conn))
+	}
+	os.Exit(helperFailed(conn, fmt.Errorf("unknown helper %q", kind)))
+}
+
+// readHelperConfig reads the configuration of a helper.
+func readHelperConfig(conn *os.File, config any) error {
+	if err := json.NewDecoder(conn).Decode(config); err != nil {
+		return fmt.Errorf("failed to read helper configuration: %w", err)
+	}
+	return nil
+}
+
+// helperFailed reports err to the controller and returns the exit code of
+// the helper.
+func helperFailed(conn *os.File, err error) int {
+	sendHelperReply(conn, helperReply{Error: err.Error()})
+	return 1
+}
+
+// sendHelperReply sends a reply to the controller.
+func sendHelperReply(conn *os.File, reply helperReply) {
+	_ = json.NewEncoder(conn).Encode(reply) // Ignore error - the controller might be gone
+}
+
+// runExecHelper runs the exec helper, which applies the Landlock rules,
+// the resource limits and the seccomp filter and executes the program or
+// its image. It only returns if that fails.
+func runExecHelper(conn *os.File) int {
+	var cfg execConfig
+	if err := readHelperConfig(conn, &cfg); err != nil {
+		return helperFailed(conn, err)
+	}
+
+	argv, err := syscall.SlicePtrFromStrings(cfg.Args)
+	if err != nil {
+		return helperFailed(conn, err)
+	}
+	envv, err := syscall.SlicePtrFromStrings(os.Environ())
+	if err != nil {
+		return helperFailed(conn, err)
+	}
+
+	// An image is executed through its descriptor with an empty path
+	path := cfg.Path
+	if cfg.Image {
+		syscall.CloseOnExec(helperImageFD)
+		path = ""
+	}
+	target, err := unix.BytePtrFromString(path)
+	if err != nil {
+		return helperFailed(conn, err)
+	}
+
+	// The restrictions apply to the thread that executes the program
+	runtime.LockOSThread()
+	if cfg.Landlock != nil {
+		if err := restrictLandlock(cfg.Landlock); err != nil {
+			return helperFailed(conn, fmt.Errorf("failed to apply Landlock rules: %w", err))
+		}
+	}
+	// The resource limits are set as late as possible, since they could
+	// keep the helper itself from working, e.g. with a low RLIMIT_NOFILE
+	if err := setOwnRlimits(cfg.Rlimits); err != nil {
+		return helperFailed(conn, err)
+	}
+	// The seccomp filter comes last, as it might deny the system calls
+	// needed for the other restrictions. From then on, a signal must not
+	// run the helper's Go code, which the program's profile might deny
+	if cfg.Seccomp != nil {
+		if err := resetSignalHandlers(); err != nil {
+			return helperFailed(conn, fmt.Errorf("failed to reset signal handlers: %w", err))
+		}
+		// The exec of the program waits for the supervisor's approval
+		if cfg.Seccomp.deniesExec() {
+			if err := sendExecListener(conn); err != nil {
+				return helperFailed(conn, fmt.Errorf("failed to install seccomp filter: %w", err))
+			}
+		}
+		if _, err := installSeccomp(cfg.Seccomp.program(), 0); err != nil {
+			return helperFailed(conn, fmt.Errorf("failed to install seccomp filter: %w", err))
+		}
+	}
+	// The socket and the image are closed on exec
+	var errno syscall.Errno
+	if cfg.Image {
+		_, _, errno = unix.RawSyscall6(unix.SYS_EXECVEAT, helperImageFD, uintptr(unsafe.Pointer(target)),
+			uintptr(unsafe.Pointer(&argv[0])), uintptr(unsafe.Pointer(&envv[0])), unix.AT_EMPTY_PATH, 0)
+	} else {
+		_, _, errno = unix.RawSyscall(unix.SYS_EXECVE, uintptr(unsafe.Pointer(target)),
+			uintptr(unsafe.Pointer(&argv[0])), uintptr(unsafe.Pointer(&envv[0])))
+	}
+	return helperFailed(conn, fmt.Errorf("failed to execute %s: %w", cfg.Path, errno))
+}
@@ -0,0 +1,27 @@
+//go:build !linux
+
+// Package processctrl exec helper stub
+//
+// Exec helpers are only needed for Linux features. On other platforms no
+// helper is ever started.
+
+package processctrl
+
+// helperStart is not used on platforms without exec helpers.
+type helperStart struct{}
+
+// runHelper returns immediately, since no helper is ever started.
+func runHelper() {}
+
+// prepareExecHelper is a no-op on platforms without exec helpers.
+func (p *Process) prepareExecHelper() error {
+	return nil
+}
+
+// startHelper is a no-op on platforms without exec helpers.
+func (p *Process) startHelper() error {
+	return nil
+}
+
+// releaseHelper is a no-op on platforms without exec helpers.
+func (p *Process) releaseHelper() {}
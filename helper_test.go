@@ -0,0 +1,35 @@
+package processctrl
+
+import (
+	"os"
+	"testing"
+)
+
+// testProgramArg makes the test binary run one of testPrograms instead of
+// the tests, given as the next argument.
+const testProgramArg = "processctrl-test-program"
+
+// testPrograms are programs that tests run from the test binary, keyed by
+// name. They return the exit code.
+var testPrograms = map[string]func() int{}
+
+// TestMain lets the test binary act as the helpers and test programs it
+// starts.
+func TestMain(m *testing.M) {
+	Init()
+	if len(os.Args) == 3 && os.Args[1] == testProgramArg {
+		os.Exit(testPrograms[os.Args[2]]())
+	}
+	os.Exit(m.Run())
+}
+
+// newTestProgram returns a process that runs the test program name.
+func newTestProgram(t *testing.T, name string) *Process {
+	t.Helper()
+
+	exe, err := os.Executable()
+	if err != nil {
+		t.Fatalf("Executable() failed: %v", err)
+	}
+	return New(exe, testProgramArg, name)
+}
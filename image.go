@@ -0,0 +1,63 @@
+package processctrl
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+)
+
+// NewFromBytes creates a new Process that executes the program image,
+// for example a binary embedded with the embed package, without writing
+// it to a visible file. name is the program name passed as argv[0] and
+// used in errors.
+//
+// Returns an error if the image cannot be stored or in-memory programs
+// are not supported.
+func NewFromBytes(name string, image []byte, args ...string) (*Process, error) {
+	return NewFromReader(name, bytes.NewReader(image), args...)
+}
+
+// NewFromReader creates a new Process that executes the program image
+// read from r. name is the program name passed as argv[0] and used in
+// errors.
+//
+// The image is read completely and stored in a sealed memfd, which cannot
+// be modified afterwards, or in a private temporary file that is unlinked
+// right away if the kernel lacks memfd_create. The program is executed
+// from that descriptor with execveat by a helper, started from the
+// controller's own executable (see Init); the descriptor is closed on
+// exec. The image must be a binary, as interpreters cannot open scripts
+// through a closed descriptor. It is released when the process exits, so
+// the Process can only be run once. All other controls work as with New.
+//
+// Only Linux is supported; other platforms return ErrNotSupported.
+//
+// Returns an error if name is empty, r fails, the image cannot be stored
+// or in-memory programs are not supported.
+func NewFromReader(name string, r io.Reader, args ...string) (*Process, error) {
+	if !imageSupported {
+		return nil, ErrNotSupported
+	}
+	if name == "" {
+		return nil, fmt.Errorf("program name must not be empty")
+	}
+
+	image, err := openImage(name, r)
+	if err != nil {
+		return nil, err
+	}
+	p := New(name, args...)
+	p.image = image
+	return p, nil
+}
+
+// releaseImage closes the program image once the process has exited, so
+// that the memfd or temporary file is not kept until garbage collection.
+// It must be called with p.mu held.
+func (p *Process) releaseImage() {
+	if p.image != nil {
+		_ = p.image.Close() // Ignore error - the image is not needed anymore
+		p.image = nil
+		p.imageReleased = true
+	}
+}
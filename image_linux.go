@@ -0,0 +1,92 @@
+//go:build linux
+
+// Package processctrl Linux in-memory programs
+//
+// This file stores program images in memfds, or in unlinked temporary
+// files on kernels without memfd_create. Either way the image is only
+// reachable through a descriptor, which the exec helper (see
+// helper_linux.go) inherits and executes with execveat.
+
+package processctrl
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// imageSupported reports whether NewFromReader is available.
+const imageSupported = true
+
+// imageSeals make a memfd immutable.
+const imageSeals = unix.F_SEAL_SEAL | unix.F_SEAL_SHRINK | unix.F_SEAL_GROW | unix.F_SEAL_WRITE
+
+// openImage stores the program image read from r and returns a read-only
+// descriptor of it.
+func openImage(name string, r io.Reader) (*os.File, error) {
+	fd, err := unix.MemfdCreate(name, unix.MFD_CLOEXEC|unix.MFD_ALLOW_SEALING|unix.MFD_EXEC)
+	if errors.Is(err, unix.EINVAL) {
+		// Kernels before 6.3 do not know MFD_EXEC
+		fd, err = unix.MemfdCreate(name, unix.MFD_CLOEXEC|unix.MFD_ALLOW_SEALING)
+	}
+	if err != nil {
+		return tempImage(r)
+	}
+
+	f := os.NewFile(uintptr(fd), "memfd:"+name)
+	if _, err := io.Copy(f, r); err != nil {
+		_ = f.Close() // Ignore error during cleanup
+		return nil, fmt.Errorf("failed to read program image: %w", err)
+	}
+	if _, err := unix.FcntlInt(uintptr(fd), unix.F_ADD_SEALS, imageSeals); err != nil {
+		_ = f.Close() // Ignore error during cleanup
+		return nil, fmt.Errorf("failed to seal program image: %w", err)
+	}
+	return f, nil
+}
+
+// tempImage stores the program image in a temporary file that only the
+// returned descriptor refers to.
+func tempImage(r io.Reader) (*os.File, error) {
+	f, err := os.CreateTemp("", "processctrl-image-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create program image: %w", err)
+	}
+	defer os.Remove(f.Name())
+
+	if _, err := io.Copy(f, r); err != nil {
+		_ = f.Close() // Ignore error during cleanup
+		return nil, fmt.Errorf("failed to read program image: %w", err)
+	}
+	if err := f.Chmod(0o500); err != nil {
+		_ = f.Close() // Ignore error during cleanup
+		return nil, fmt.Errorf("failed to store program image: %w", err)
+	}
+	// A program cannot be executed while it is open for writing
+	if err := f.Close(); err != nil {
+		return nil, fmt.Errorf("failed to store program image: %w", err)
+	}
+	image, err := os.Open(f.Name())
+	if err != nil {
+		return nil, fmt.Errorf("failed to open program image: %w", err)
+	}
+	return image, nil
+}
+
+// prepareImage makes the command execute the program image, which the
+// exec helper or the init process of a sandbox will do. The image is
+// released when the process exits, so it cannot be run again.
+// It must be called with p.mu held before the command is started.
+func (p *Process) prepareImage() error {
+	if p.imageReleased {
+		return fmt.Errorf("program image of %s was released when the process exited", p.program)
+	}
+	if p.image != nil {
+		p.cmd.Path = p.program
+		p.cmd.Err = nil
+	}
+	return nil
+}
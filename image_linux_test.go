@@ -0,0 +1,153 @@
+//go:build linux
+
+package processctrl
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+// echoImage returns the image of the echo binary.
+func echoImage(t *testing.T) []byte {
+	t.Helper()
+
+	path, err := exec.LookPath("echo")
+	if err != nil {
+		t.Skip("echo not available")
+	}
+	image, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read %s: %v", path, err)
+	}
+	return image
+}
+
+func TestNewFromBytes(t *testing.T) {
+	proc, err := NewFromBytes("echo", echoImage(t), "from", "memory")
+	if err != nil {
+		t.Fatalf("NewFromBytes() failed: %v", err)
+	}
+	lines, result := runCollect(t, proc)
+	if strings.Join(lines, "\n") != "from memory" || result.ExitCode() != 0 {
+		t.Errorf("Output = %q, ExitCode() = %d, want from memory, 0", lines, result.ExitCode())
+	}
+}
+
+func TestImageIsSealed(t *testing.T) {
+	image, err := openImage("echo", bytes.NewReader(echoImage(t)))
+	if err != nil {
+		t.Fatalf("openImage() failed: %v", err)
+	}
+	defer image.Close()
+
+	if !strings.HasPrefix(image.Name(), "memfd:") {
+		t.Skip("memfd_create not available")
+	}
+	if _, err := image.WriteAt([]byte{0}, 0); err == nil {
+		t.Error("Writing to the sealed image succeeded")
+	}
+	if err := image.Truncate(0); err == nil {
+		t.Error("Truncating the sealed image succeeded")
+	}
+}
+
+func TestTempImage(t *testing.T) {
+	image, err := tempImage(bytes.NewReader(echoImage(t)))
+	if err != nil {
+		t.Fatalf("tempImage() failed: %v", err)
+	}
+	if _, err := os.Stat(image.Name()); !os.IsNotExist(err) {
+		t.Errorf("Temporary image %s is still linked: %v", image.Name(), err)
+	}
+
+	proc := New("echo", "from", "file")
+	proc.image = image
+	lines, _ := runCollect(t, proc)
+	if strings.Join(lines, "\n") != "from file" {
+		t.Errorf("Output = %q, want from file", lines)
+	}
+}
+
+func TestImageWithSeccompNoExec(t *testing.T) {
+	if !seccompSupported {
+		t.Skip("seccomp profiles are not supported on this architecture")
+	}
+	proc, err := NewFromBytes("echo", echoImage(t), "filtered")
+	if err != nil {
+		t.Fatalf("NewFromBytes() failed: %v", err)
+	}
+	if err := proc.SetSeccomp(&SeccompProfile{Presets: []string{SeccompNoExec}}); err != nil {
+		t.Fatalf("SetSeccomp() failed: %v", err)
+	}
+	lines, _ := runCollect(t, proc)
+	if strings.Join(lines, "\n") != "filtered" {
+		t.Errorf("Output = %q, want filtered", lines)
+	}
+}
+
+func TestImageInSandbox(t *testing.T) {
+	requireSandbox(t)
+	proc, err := NewFromBytes("echo", echoImage(t), "sandboxed")
+	if err != nil {
+		t.Fatalf("NewFromBytes() failed: %v", err)
+	}
+	if err := proc.SetSandbox(testSandbox(&Sandbox{})); err != nil {
+		t.Fatalf("SetSandbox() failed: %v", err)
+	}
+	lines, _ := runCollect(t, proc)
+	if strings.Join(lines, "\n") != "sandboxed" {
+		t.Errorf("Output = %q, want sandboxed", lines)
+	}
+}
+
+func TestNewFromReaderInvalidImage(t *testing.T) {
+	if _, err := NewFromReader("", strings.NewReader("x")); err == nil {
+		t.Error("NewFromReader() with empty name succeeded")
+	}
+	proc, err := NewFromReader("garbage", strings.NewReader("not a program"))
+	if err != nil {
+		t.Fatalf("NewFromReader() failed: %v", err)
+	}
+	if _, _, err := proc.Run(); err == nil || !strings.Contains(err.Error(), "failed to execute garbage") {
+		t.Errorf("Run() error = %v, want exec format error", err)
+	}
+}
+
+func TestImageRequiresInit(t *testing.T) {
+	initCalled.Store(false)
+	defer initCalled.Store(true)
+
+	proc, err := NewFromBytes("echo", echoImage(t))
+	if err != nil {
+		t.Fatalf("NewFromBytes() failed: %v", err)
+	}
+	if _, _, err := proc.Run(); err == nil || !strings.Contains(err.Error(), "processctrl.Init") {
+		t.Errorf("Run() error = %v, want a hint to call processctrl.Init", err)
+	}
+}
+
+func TestImageReleasedOnExit(t *testing.T) {
+	proc, err := NewFromBytes("processctrl-release-test", echoImage(t))
+	if err != nil {
+		t.Fatalf("NewFromBytes() failed: %v", err)
+	}
+	runCollect(t, proc)
+
+	// The memfd of the image must not wait for garbage collection
+	entries, err := os.ReadDir("/proc/self/fd")
+	if err != nil {
+		t.Fatalf("ReadDir() failed: %v", err)
+	}
+	for _, entry := range entries {
+		if link, err := os.Readlink("/proc/self/fd/" + entry.Name()); err == nil && strings.Contains(link, "processctrl-release-test") {
+			t.Errorf("Image still open as descriptor %s: %s", entry.Name(), link)
+		}
+	}
+
+	if _, _, err := proc.Run(); err == nil {
+		t.Error("Run() of a released image should fail")
+	}
+}
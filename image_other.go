@@ -0,0 +1,27 @@
+//go:build !linux
+
+// Package processctrl in-memory program stub
+//
+// In-memory programs are executed with execveat(2), which only Linux
+// provides. On other platforms NewFromBytes and NewFromReader return
+// ErrNotSupported.
+
+package processctrl
+
+import (
+	"io"
+	"os"
+)
+
+// imageSupported reports whether NewFromReader is available.
+const imageSupported = false
+
+// openImage is not supported on this platform.
+func openImage(name string, r io.Reader) (*os.File, error) {
+	return nil, ErrNotSupported
+}
+
+// prepareImage is a no-op on platforms without in-memory programs.
+func (p *Process) prepareImage() error {
+	return nil
+}
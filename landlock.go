@@ -0,0 +1,89 @@
+package processctrl
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// LandlockConfig confines the file system access of a process with
+// Landlock. Access to files outside the listed hierarchies is denied,
+// including reading and executing them, so the hierarchies must contain
+// the program and everything it loads.
+type LandlockConfig struct {
+	ReadOnly  []string // Hierarchies that may be read and executed
+	ReadWrite []string // Hierarchies that may also be written, created and removed
+	Strict    bool     // Fail Run if the kernel cannot enforce all restrictions
+}
+
+// landlockRules are the Landlock rules of a run.
+type landlockRules struct {
+	Handled   uint64 // Access rights the kernel restricts
+	ReadOnly  []string
+	ReadWrite []string
+}
+
+// SetLandlock confines the file system access of the process, or removes
+// the restrictions if cfg is nil. It must be called before the process is
+// started.
+//
+// The rules are applied by a helper, started from the controller's own
+// executable (see Init), which then executes the program; they stay in effect for
+// the program and all its descendants. Without CAP_SYS_ADMIN,
+// no_new_privs is set as the kernel requires. Paths are resolved when the
+// process is started, and the rules apply to the files they refer to at
+// that time; in a sandbox, they are resolved inside the sandbox.
+//
+// Landlock is detected when the process is started. Older kernels enforce
+// fewer access rights, for example they do not restrict truncation; if
+// Landlock is unavailable, the process is started without restrictions.
+// Either problem is reported by LandlockError. With Strict set, Run fails
+// instead.
+//
+// Only Linux is supported; other platforms return ErrNotSupported.
+//
+// Returns an error if a path is not absolute or does not exist, Landlock
+// is not supported or the process is running.
+func (p *Process) SetLandlock(cfg *LandlockConfig) error {
+	if !landlockSupported {
+		return ErrNotSupported
+	}
+
+	var c *LandlockConfig
+	if cfg != nil {
+		copied := LandlockConfig{Strict: cfg.Strict}
+		for _, paths := range []struct {
+			src []string
+			dst *[]string
+		}{{cfg.ReadOnly, &copied.ReadOnly}, {cfg.ReadWrite, &copied.ReadWrite}} {
+			for _, path := range paths.src {
+				if !filepath.IsAbs(path) {
+					return fmt.Errorf("Landlock paths must be absolute: %s", path)
+				}
+				if _, err := os.Stat(path); err != nil {
+					return fmt.Errorf("invalid Landlock path: %w", err)
+				}
+				*paths.dst = append(*paths.dst, filepath.Clean(path))
+			}
+		}
+		c = &copied
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.running {
+		return fmt.Errorf("cannot change the Landlock rules of a running process")
+	}
+	p.landlock = c
+	return nil
+}
+
+// LandlockError returns the problems encountered while applying the
+// Landlock rules configured with SetLandlock, or nil if they are fully
+// enforced.
+func (p *Process) LandlockError() error {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.landlockErr
+}
@@ -0,0 +1,163 @@
+//go:build linux
+
+// Package processctrl Linux Landlock rules
+//
+// Landlock restricts the file system access of the thread that applies a
+// ruleset and everything it executes, so the rules are applied by the
+// exec helper (see helper_linux.go) right before it executes the program.
+// Each kernel ABI version restricts more access rights; the controller
+// detects the version and only handles the rights the kernel knows, as
+// rulesets with unknown rights are rejected.
+
+package processctrl
+
+import (
+	"errors"
+	"fmt"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// landlockSupported reports whether SetLandlock is available.
+const landlockSupported = true
+
+// Access rights granted by the Landlock rules.
+const (
+	landlockReadAccess = unix.LANDLOCK_ACCESS_FS_EXECUTE | unix.LANDLOCK_ACCESS_FS_READ_FILE |
+		unix.LANDLOCK_ACCESS_FS_READ_DIR
+	// landlockFileAccess are the rights that apply to files rather than
+	// directories.
+	landlockFileAccess = unix.LANDLOCK_ACCESS_FS_EXECUTE | unix.LANDLOCK_ACCESS_FS_WRITE_FILE |
+		unix.LANDLOCK_ACCESS_FS_READ_FILE | unix.LANDLOCK_ACCESS_FS_TRUNCATE |
+		unix.LANDLOCK_ACCESS_FS_IOCTL_DEV
+)
+
+// landlockABIAccess are the file system access rights of each Landlock
+// ABI version, starting with version 1. Versions that add no file system
+// rights repeat the previous entry.
+var landlockABIAccess = []uint64{
+	unix.LANDLOCK_ACCESS_FS_MAKE_SYM<<1 - 1, // EXECUTE to MAKE_SYM
+	unix.LANDLOCK_ACCESS_FS_REFER<<1 - 1,
+	unix.LANDLOCK_ACCESS_FS_TRUNCATE<<1 - 1,
+	unix.LANDLOCK_ACCESS_FS_TRUNCATE<<1 - 1,
+	unix.LANDLOCK_ACCESS_FS_IOCTL_DEV<<1 - 1,
+}
+
+// landlockABI returns the Landlock ABI version of the kernel.
+func landlockABI() (int, error) {
+	abi, _, errno := unix.Syscall(unix.SYS_LANDLOCK_CREATE_RULESET, 0, 0, unix.LANDLOCK_CREATE_RULESET_VERSION)
+	if errno != 0 {
+		return 0, fmt.Errorf("Landlock is not available: %w", errno)
+	}
+	return int(abi), nil
+}
+
+// prepareLandlock resolves the Landlock rules of the run for the kernel's
+// ABI version.
+// It must be called with p.mu held before the command is started.
+func (p *Process) prepareLandlock() error {
+	p.landlockRules = nil
+	p.landlockErr = nil
+	if p.landlock == nil {
+		return nil
+	}
+
+	abi, err := landlockABI()
+	rules, err := resolveLandlock(p.landlock, abi, err)
+	if err != nil && p.landlock.Strict {
+		return err
+	}
+	p.landlockRules = rules
+	p.landlockErr = err
+	return nil
+}
+
+// resolveLandlock returns the rules of cfg for the Landlock ABI version
+// abi, or the error abiErr if it could not be detected. The error
+// describes the restrictions the kernel cannot enforce; the rules are nil
+// if Landlock is unavailable.
+func resolveLandlock(cfg *LandlockConfig, abi int, abiErr error) (*landlockRules, error) {
+	if abiErr != nil {
+		return nil, abiErr
+	}
+	if abi < 1 {
+		return nil, fmt.Errorf("invalid Landlock ABI version %d", abi)
+	}
+
+	rules := &landlockRules{
+		Handled:   landlockABIAccess[min(abi, len(landlockABIAccess))-1],
+		ReadOnly:  cfg.ReadOnly,
+		ReadWrite: cfg.ReadWrite,
+	}
+	if abi < len(landlockABIAccess) {
+		return rules, fmt.Errorf("Landlock ABI version %d does not restrict all access rights, version %d is required",
+			abi, len(landlockABIAccess))
+	}
+	return rules, nil
+}
+
+// restrictLandlock applies the rules to the current thread.
+func restrictLandlock(r *landlockRules) error {
+	attr := unix.LandlockRulesetAttr{Access_fs: r.Handled}
+	fd, _, errno := unix.Syscall(unix.SYS_LANDLOCK_CREATE_RULESET,
+		uintptr(unsafe.Pointer(&attr)), unsafe.Sizeof(attr), 0)
+	if errno != 0 {
+		return fmt.Errorf("failed to create ruleset: %w", errno)
+	}
+	defer unix.Close(int(fd))
+
+	for _, paths := range []struct {
+		paths  []string
+		access uint64
+	}{{r.ReadOnly, landlockReadAccess}, {r.ReadWrite, r.Handled}} {
+		for _, path := range paths.paths {
+			if err := addLandlockRule(int(fd), path, paths.access&r.Handled); err != nil {
+				return err
+			}
+		}
+	}
+
+	restrict := func() error {
+		_, _, errno := unix.Syscall(unix.SYS_LANDLOCK_RESTRICT_SELF, fd, 0, 0)
+		if errno != 0 {
+			return errno
+		}
+		return nil
+	}
+	err := restrict()
+	if errors.Is(err, unix.EPERM) {
+		// Without CAP_SYS_ADMIN, rulesets require no_new_privs
+		if err := unix.Prctl(unix.PR_SET_NO_NEW_PRIVS, 1, 0, 0, 0); err != nil {
+			return fmt.Errorf("failed to set no_new_privs: %w", err)
+		}
+		err = restrict()
+	}
+	return err
+}
+
+// addLandlockRule grants access to the hierarchy at path. Only the rights
+// that apply to files are granted if path is not a directory.
+func addLandlockRule(ruleset int, path string, access uint64) error {
+	fd, err := unix.Open(path, unix.O_PATH|unix.O_CLOEXEC, 0)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	defer unix.Close(fd)
+
+	var st unix.Stat_t
+	if err := unix.Fstat(fd, &st); err != nil {
+		return fmt.Errorf("failed to stat %s: %w", path, err)
+	}
+	if st.Mode&unix.S_IFMT != unix.S_IFDIR {
+		access &= landlockFileAccess
+	}
+
+	attr := unix.LandlockPathBeneathAttr{Allowed_access: access, Parent_fd: int32(fd)}
+	_, _, errno := unix.Syscall6(unix.SYS_LANDLOCK_ADD_RULE, uintptr(ruleset),
+		unix.LANDLOCK_RULE_PATH_BENEATH, uintptr(unsafe.Pointer(&attr)), 0, 0, 0)
+	if errno != 0 {
+		return fmt.Errorf("failed to add rule for %s: %w", path, errno)
+	}
+	return nil
+}
@@ -0,0 +1,160 @@
+//go:build linux
+
+package processctrl
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+// landlockSystemDirs returns the existing system directories a shell
+// needs to run.
+func landlockSystemDirs() []string {
+	var dirs []string
+	for _, dir := range []string{"/bin", "/sbin", "/usr", "/lib", "/lib32", "/lib64", "/etc"} {
+		if _, err := os.Stat(dir); err == nil {
+			dirs = append(dirs, dir)
+		}
+	}
+	return dirs
+}
+
+// newLandlocked returns a shell script confined to the system directories
+// and the read-write hierarchies, skipping the test if Landlock is not
+// available.
+func newLandlocked(t *testing.T, script string, readWrite ...string) *Process {
+	t.Helper()
+
+	if _, err := landlockABI(); err != nil {
+		t.Skip(err)
+	}
+	proc := New("sh", "-c", script)
+	err := proc.SetLandlock(&LandlockConfig{ReadOnly: landlockSystemDirs(), ReadWrite: readWrite})
+	if err != nil {
+		t.Fatalf("SetLandlock() failed: %v", err)
+	}
+	return proc
+}
+
+func TestLandlockConfinesWrites(t *testing.T) {
+	allowed := t.TempDir()
+	outside := t.TempDir()
+	proc := newLandlocked(t, `
+		echo ok > `+allowed+`/file && echo written
+		mkdir `+allowed+`/dir && mv `+allowed+`/file `+allowed+`/dir/ && echo moved
+		echo ok > `+outside+`/file 2>/dev/null || echo denied
+		mkdir `+outside+`/dir 2>/dev/null || echo denied
+		ls `+outside+` >/dev/null 2>&1 || echo denied
+		read -r line < /etc/passwd && echo read`, allowed)
+	lines, result := runCollect(t, proc)
+	want := []string{"written", "moved", "denied", "denied", "denied", "read"}
+	if strings.Join(lines, "\n") != strings.Join(want, "\n") {
+		t.Errorf("Output = %q, want %q", lines, want)
+	}
+	if result.ExitCode() != 0 {
+		t.Errorf("ExitCode() = %d, want 0", result.ExitCode())
+	}
+	if _, err := os.Stat(filepath.Join(allowed, "dir", "file")); err != nil {
+		t.Errorf("File in allowed directory: %v", err)
+	}
+	if entries, _ := os.ReadDir(outside); len(entries) != 0 {
+		t.Errorf("Outside directory has %d entries, want 0", len(entries))
+	}
+}
+
+func TestLandlockAppliesToDescendants(t *testing.T) {
+	outside := t.TempDir()
+	proc := newLandlocked(t, "sh -c 'touch "+outside+"/file' 2>/dev/null || echo denied")
+	lines, _ := runCollect(t, proc)
+	if strings.Join(lines, "\n") != "denied" {
+		t.Errorf("Output = %q, want denied", lines)
+	}
+}
+
+func TestLandlockProgramOutsideHierarchies(t *testing.T) {
+	if _, err := landlockABI(); err != nil {
+		t.Skip(err)
+	}
+	proc := New("sh", "-c", "true")
+	if err := proc.SetLandlock(&LandlockConfig{ReadWrite: []string{t.TempDir()}}); err != nil {
+		t.Fatalf("SetLandlock() failed: %v", err)
+	}
+	if _, _, err := proc.Run(); err == nil || !strings.Contains(err.Error(), "permission denied") {
+		t.Errorf("Run() error = %v, want permission denied", err)
+	}
+}
+
+func TestResolveLandlock(t *testing.T) {
+	cfg := &LandlockConfig{ReadOnly: []string{"/usr"}}
+	unavailable := syscall.ENOSYS
+
+	rules, err := resolveLandlock(cfg, 0, unavailable)
+	if rules != nil || !errors.Is(err, unavailable) {
+		t.Errorf("Without Landlock: rules = %+v, error = %v", rules, err)
+	}
+
+	// ABI 1 does not restrict renames across directories or truncation
+	rules, err = resolveLandlock(cfg, 1, nil)
+	if rules == nil || err == nil {
+		t.Fatalf("ABI 1: rules = %+v, error = %v, want rules and error", rules, err)
+	}
+	if rules.Handled != 1<<13-1 {
+		t.Errorf("ABI 1: Handled = %#x, want %#x", rules.Handled, 1<<13-1)
+	}
+
+	for _, abi := range []int{len(landlockABIAccess), len(landlockABIAccess) + 2} {
+		rules, err = resolveLandlock(cfg, abi, nil)
+		if err != nil || rules.Handled != landlockABIAccess[len(landlockABIAccess)-1] {
+			t.Errorf("ABI %d: rules = %+v, error = %v", abi, rules, err)
+		}
+	}
+}
+
+func TestLandlockStrict(t *testing.T) {
+	abi, err := landlockABI()
+	if err == nil && abi >= len(landlockABIAccess) {
+		t.Skip("Landlock is fully supported")
+	}
+	proc := New("true")
+	if err := proc.SetLandlock(&LandlockConfig{ReadOnly: landlockSystemDirs(), Strict: true}); err != nil {
+		t.Fatalf("SetLandlock() failed: %v", err)
+	}
+	if _, _, err := proc.Run(); err == nil {
+		t.Error("Run() succeeded without full Landlock support")
+	}
+}
+
+func TestSetLandlockInvalid(t *testing.T) {
+	proc := New("true")
+	for _, cfg := range []*LandlockConfig{
+		{ReadOnly: []string{"relative"}},
+		{ReadWrite: []string{filepath.Join(t.TempDir(), "missing")}},
+	} {
+		if err := proc.SetLandlock(cfg); err == nil {
+			t.Errorf("SetLandlock(%+v) succeeded, want error", cfg)
+		}
+	}
+}
+
+func TestLandlockInSandbox(t *testing.T) {
+	if _, err := landlockABI(); err != nil {
+		t.Skip(err)
+	}
+	outside := t.TempDir()
+	requireSandbox(t)
+	proc := New("sh", "-c", "touch "+outside+"/file 2>/dev/null || echo denied")
+	if err := proc.SetSandbox(testSandbox(&Sandbox{})); err != nil {
+		t.Fatalf("SetSandbox() failed: %v", err)
+	}
+	if err := proc.SetLandlock(&LandlockConfig{ReadOnly: landlockSystemDirs()}); err != nil {
+		t.Fatalf("SetLandlock() failed: %v", err)
+	}
+	lines, _ := runCollect(t, proc)
+	if strings.Join(lines, "\n") != "denied" {
+		t.Errorf("Output = %q, want denied", lines)
+	}
+}
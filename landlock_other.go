@@ -0,0 +1,16 @@
+//go:build !linux
+
+// Package processctrl Landlock stub
+//
+// Landlock is a Linux security module. On other platforms SetLandlock
+// returns ErrNotSupported.
+
+package processctrl
+
+// landlockSupported reports whether SetLandlock is available.
+const landlockSupported = false
+
+// prepareLandlock is a no-op on platforms without Landlock.
+func (p *Process) prepareLandlock() error {
+	return nil
+}
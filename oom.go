@@ -0,0 +1,91 @@
+package processctrl
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// SetOOMScoreAdj sets the OOM score adjustment of the process, from -1000
+// (never chosen by the OOM killer) to 1000 (chosen first), see proc(5).
+// Giving disposable workers a high value makes the kernel sacrifice them
+// before the controller. A value set before the process is started is
+// applied to the child before it runs its first instruction, in the same
+// way as SetRlimit; a value set while the process is running is applied
+// immediately. Lowering the value below the controller's own requires
+// CAP_SYS_RESOURCE.
+//
+// Only Linux is supported; other platforms return ErrNotSupported.
+//
+// Returns an error if the value is out of range or cannot be applied to
+// the running process.
+func (p *Process) SetOOMScoreAdj(adj int) error {
+	if adj < -1000 || adj > 1000 {
+		return fmt.Errorf("invalid OOM score adjustment: %d", adj)
+	}
+	if !oomSupported {
+		return ErrNotSupported
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.running {
+		if err := setOOMScoreAdj(p.programPID, adj); err != nil {
+			return fmt.Errorf("failed to set OOM score adjustment: %w", err)
+		}
+	}
+	p.oomScoreAdj = &adj
+	return nil
+}
+
+// OOMScoreAdj returns the OOM score adjustment of the process. While the
+// process is running it is read from /proc/<pid>/oom_score_adj; before it
+// is started the value set with SetOOMScoreAdj is returned.
+//
+// Only Linux is supported; other platforms return ErrNotSupported.
+//
+// Returns an error if the process is not running and no value has been
+// set, or the value cannot be read.
+func (p *Process) OOMScoreAdj() (int, error) {
+	if !oomSupported {
+		return 0, ErrNotSupported
+	}
+
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	if p.running {
+		return getOOMScoreAdj(p.programPID)
+	}
+	if p.oomScoreAdj == nil {
+		return 0, fmt.Errorf("OOM score adjustment not set")
+	}
+	return *p.oomScoreAdj, nil
+}
+
+// detectOOMKill decides whether the process was killed by the OOM killer.
+// It must be called with p.mu held after the process has been reaped,
+// before its cgroup is released.
+//
+// The process must have died from SIGKILL, given by sig, the signal that
+// terminated the program. If the process has a cgroup (see SetCgroup),
+// an OOM kill in its memory.events is the only evidence trusted, and it
+// is trusted even if processctrl was stopping the process at the time.
+// Otherwise processctrl must not have sent SIGKILL or canceled the
+// process itself, and the system-wide oom_kill counter of /proc/vmstat
+// must have increased while it ran. The latter is a heuristic that can
+// be misled by a SIGKILL sent from outside processctrl at the same time
+// as an unrelated OOM kill.
+func (p *Process) detectOOMKill(sig os.Signal, oomKillsAfter uint64, canceled bool) bool {
+	if sig != syscall.SIGKILL {
+		return false
+	}
+	if p.cgroup != nil {
+		return p.oomKills > 0
+	}
+	if p.killSent || p.execKilled.Load() || canceled {
+		return false
+	}
+	return p.oomKillsKnown && oomKillsAfter > p.oomKillsBefore
+}
@@ -0,0 +1,109 @@
+//go:build linux
+
+package processctrl
+
+import (
+	"os"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestOOMScoreAdj(t *testing.T) {
+	ownAdj, err := getOOMScoreAdj(os.Getpid())
+	if err != nil {
+		t.Fatalf("getOOMScoreAdj() failed: %v", err)
+	}
+
+	proc := New("sh", "-c", "cat /proc/self/oom_score_adj; exec sleep 10")
+	if err := proc.SetOOMScoreAdj(500); err != nil {
+		t.Fatalf("SetOOMScoreAdj() failed: %v", err)
+	}
+	stdout, _, err := proc.Run()
+	if err != nil {
+		t.Fatalf("Run() failed: %v", err)
+	}
+	defer func() { _ = proc.Kill() }()
+
+	select {
+	case line := <-stdout:
+		if strings.TrimSpace(line) != "500" {
+			t.Errorf("Child saw oom_score_adj %q, want 500", line)
+		}
+	case <-time.After(testTimeout * time.Second):
+		t.Fatal("Timed out waiting for output")
+	}
+
+	if err := proc.SetOOMScoreAdj(900); err != nil {
+		t.Fatalf("SetOOMScoreAdj() on running process failed: %v", err)
+	}
+	if adj, err := proc.OOMScoreAdj(); err != nil || adj != 900 {
+		t.Errorf("OOMScoreAdj() = %d, %v, want 900", adj, err)
+	}
+	if adj, err := getOOMScoreAdj(os.Getpid()); err != nil || adj != ownAdj {
+		t.Errorf("Controller oom_score_adj changed to %d, %v, want %d", adj, err, ownAdj)
+	}
+}
+
+func TestSetOOMScoreAdjInvalid(t *testing.T) {
+	proc := New("true")
+	if err := proc.SetOOMScoreAdj(1001); err == nil {
+		t.Error("SetOOMScoreAdj(1001) succeeded, want error")
+	}
+	if _, err := proc.OOMScoreAdj(); err == nil {
+		t.Error("OOMScoreAdj() succeeded without a value set")
+	}
+}
+
+func TestExternalSIGKILLIsNotOOM(t *testing.T) {
+	proc := New("sh", "-c", "exec sleep 10")
+	startTest(t, proc)
+	if err := syscall.Kill(proc.PID(), syscall.SIGKILL); err != nil {
+		t.Fatalf("Kill failed: %v", err)
+	}
+
+	result := waitResult(t, proc)
+	if exitSignal(result) != syscall.SIGKILL {
+		t.Fatalf("Process not killed by SIGKILL: %v", result.State)
+	}
+	// Without an OOM kill recorded by the kernel, a SIGKILL is not taken
+	// for one
+	after, ok := readOOMKillCount()
+	if !ok || after != proc.oomKillsBefore {
+		t.Skip("OOM kill counter unavailable or changed during the test")
+	}
+	if result.OOMKilled || result.Reason != ExitNormal {
+		t.Errorf("External SIGKILL reported as OOM kill: %+v", result)
+	}
+}
+
+func TestDetectOOMKill(t *testing.T) {
+	tests := []struct {
+		name     string
+		proc     *Process
+		sig      os.Signal
+		after    uint64
+		canceled bool
+		want     bool
+	}{
+		{"vmstat counter increased", &Process{oomKillsBefore: 5, oomKillsKnown: true}, syscall.SIGKILL, 6, false, true},
+		{"vmstat counter unchanged", &Process{oomKillsBefore: 5, oomKillsKnown: true}, syscall.SIGKILL, 5, false, false},
+		{"vmstat unavailable", &Process{}, syscall.SIGKILL, 6, false, false},
+		{"cgroup OOM kill", &Process{cgroup: &cgroupHandle{}, oomKills: 1}, syscall.SIGKILL, 0, false, true},
+		{"cgroup without OOM kill", &Process{cgroup: &cgroupHandle{}, oomKillsBefore: 5, oomKillsKnown: true}, syscall.SIGKILL, 6, false, false},
+		{"cgroup OOM kill while killed by processctrl", &Process{cgroup: &cgroupHandle{}, oomKills: 1, killSent: true}, syscall.SIGKILL, 0, false, true},
+		{"cgroup OOM kill while context canceled", &Process{cgroup: &cgroupHandle{}, oomKills: 1}, syscall.SIGKILL, 0, true, true},
+		{"vmstat counter increased, killed by processctrl", &Process{oomKillsBefore: 5, oomKillsKnown: true, killSent: true}, syscall.SIGKILL, 6, false, false},
+		{"vmstat counter increased, context canceled", &Process{oomKillsBefore: 5, oomKillsKnown: true}, syscall.SIGKILL, 6, true, false},
+		{"other signal", &Process{cgroup: &cgroupHandle{}, oomKills: 1}, syscall.SIGTERM, 0, false, false},
+		{"exited", &Process{cgroup: &cgroupHandle{}, oomKills: 1}, nil, 0, false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.proc.detectOOMKill(tt.sig, tt.after, tt.canceled); got != tt.want {
+				t.Errorf("detectOOMKill() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,26 @@
+//go:build !linux
+
+// Package processctrl OOM killer stub
+//
+// The OOM score adjustment and OOM-kill detection rely on /proc and are
+// only supported on Linux.
+
+package processctrl
+
+// oomSupported reports whether SetOOMScoreAdj is available.
+const oomSupported = false
+
+// setOOMScoreAdj is not supported on this platform.
+func setOOMScoreAdj(int, int) error {
+	return ErrNotSupported
+}
+
+// getOOMScoreAdj is not supported on this platform.
+func getOOMScoreAdj(int) (int, error) {
+	return 0, ErrNotSupported
+}
+
+// readOOMKillCount is not supported on this platform.
+func readOOMKillCount() (uint64, bool) {
+	return 0, false
+}
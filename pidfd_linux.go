@@ -0,0 +1,122 @@
+//go:build linux
+
+// Package processctrl Linux pidfd support
+//
+// This file contains the Linux-specific process handle based on pidfds.
+// A pidfd refers to one specific process rather than to a PID number, so
+// signals sent through it can never reach an unrelated process that has
+// been assigned the same PID after the child exited and was reaped.
+//
+// Support for pidfds was added over several kernel releases (pidfd_send_signal
+// in 5.1, CLONE_PIDFD in 5.2, pidfd_open in 5.3). On kernels without support
+// the process is signaled by PID as before.
+
+package processctrl
+
+import (
+	"errors"
+	"syscall"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// prepareHandle asks the kernel to create a pidfd for the child when it is
+// cloned. It must be called before the command is started.
+func (p *Process) prepareHandle() {
+	p.pidfd = -1
+	if p.cmd.SysProcAttr == nil {
+		p.cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	p.cmd.SysProcAttr.PidFD = &p.pidfd
+}
+
+// openHandle completes the pidfd setup after the child has been started.
+// If the kernel did not return a pidfd from clone, pidfd_open is tried
+// instead. This is race-free because the child cannot be reaped before we
+// call Wait. If neither is available the process falls back to PID-based
+// signaling.
+func (p *Process) openHandle() {
+	if p.pidfd < 0 {
+		fd, err := unix.PidfdOpen(p.cmd.Process.Pid, 0)
+		if err != nil {
+			p.pidfd = -1
+			return
+		}
+		p.pidfd = fd
+	}
+	p.watchHandle()
+}
+
+// watchHandle starts a goroutine that polls the pidfd for readability,
+// which the kernel signals as soon as the process exits.
+func (p *Process) watchHandle() {
+	fd := p.pidfd
+	p.handleDone = make(chan struct{})
+
+	go func() {
+		defer close(p.handleDone)
+
+		for {
+			exited, err := pollPidfd(fd, -1)
+			if err != nil {
+				// Leave exit detection to the reaper
+				return
+			}
+			if exited {
+				break
+			}
+		}
+		p.markExited()
+	}()
+}
+
+// closeHandle releases the pidfd once the process has been reaped.
+// It must be called with p.mu held.
+func (p *Process) closeHandle() {
+	if p.pidfd < 0 {
+		return
+	}
+	if p.handleDone != nil {
+		<-p.handleDone
+	}
+	_ = unix.Close(p.pidfd) // Ignore error during cleanup
+	p.pidfd = -1
+}
+
+// signal delivers sig to the process through its pidfd when available,
+// falling back to PID-based signaling otherwise.
+// It must be called with p.mu held.
+func (p *Process) signal(sig syscall.Signal) error {
+	if p.pidfd < 0 {
+		return p.cmd.Process.Signal(sig)
+	}
+
+	err := unix.PidfdSendSignal(p.pidfd, sig, nil, 0)
+	if errors.Is(err, unix.ENOSYS) {
+		// pidfd_open succeeded but pidfd_send_signal is missing; this
+		// only happens with incomplete emulations of the Linux ABI.
+		return p.cmd.Process.Signal(sig)
+	}
+	return mapESRCH(err)
+}
+
+// pollPidfd waits up to timeout for the process referred to by fd to exit.
+// A negative timeout waits indefinitely. It returns false without an error
+// if the timeout expires or the wait is interrupted.
+func pollPidfd(fd int, timeout time.Duration) (bool, error) {
+	ms := -1
+	if timeout >= 0 {
+		ms = int(timeout.Milliseconds())
+	}
+
+	fds := []unix.PollFd{{Fd: int32(fd), Events: unix.POLLIN}}
+	n, err := unix.Poll(fds, ms)
+	if errors.Is(err, unix.EINTR) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
@@ -0,0 +1,95 @@
+//go:build linux
+
+package processctrl
+
+import (
+	"errors"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestPidfdObtainedAtStart(t *testing.T) {
+	proc := New("sleep", "10")
+
+	stdout, stderr, err := proc.Run()
+	if err != nil {
+		t.Fatalf("Run() failed: %v", err)
+	}
+	go func() {
+		for range stderr {
+		}
+	}()
+	go func() {
+		for range stdout {
+		}
+	}()
+
+	proc.mu.RLock()
+	pidfd := proc.pidfd
+	proc.mu.RUnlock()
+	if pidfd < 0 {
+		_ = proc.Kill()
+		t.Skip("kernel does not support pidfds")
+	}
+
+	if err := proc.Terminate(); err != nil {
+		t.Fatalf("Terminate() failed: %v", err)
+	}
+
+	select {
+	case <-proc.Done():
+	case <-time.After(testTimeout * time.Second):
+		t.Fatal("Done() was not closed after termination")
+	}
+}
+
+func TestPidfdSignalAfterReap(t *testing.T) {
+	proc := New("sleep", "10")
+
+	stdout, stderr, err := proc.Run()
+	if err != nil {
+		t.Fatalf("Run() failed: %v", err)
+	}
+	go func() {
+		for range stderr {
+		}
+	}()
+
+	proc.mu.Lock()
+	hasPidfd := proc.pidfd >= 0
+	proc.mu.Unlock()
+	if !hasPidfd {
+		_ = proc.Kill()
+		t.Skip("kernel does not support pidfds")
+	}
+
+	// Keep a duplicate of the pidfd so it survives the reaper's cleanup
+	proc.mu.Lock()
+	dup, err := syscall.Dup(proc.pidfd)
+	proc.mu.Unlock()
+	if err != nil {
+		t.Fatalf("dup failed: %v", err)
+	}
+
+	if err := proc.Kill(); err != nil {
+		t.Fatalf("Kill() failed: %v", err)
+	}
+	// The output channels are closed after the pidfd has been released
+	for range stdout {
+	}
+
+	// The PID may now be reused, but the pidfd still refers to the old
+	// process and must refuse to deliver signals.
+	proc.mu.Lock()
+	proc.pidfd = dup
+	err = proc.signal(syscall.SIGTERM)
+	proc.pidfd = -1
+	proc.mu.Unlock()
+	_ = syscall.Close(dup)
+
+	if !errors.Is(err, os.ErrProcessDone) {
+		t.Errorf("Expected os.ErrProcessDone, got: %v", err)
+	}
+}
@@ -0,0 +1,27 @@
+//go:build !linux
+
+// Package processctrl fallback process handle
+//
+// This file contains the process handle used on platforms without pidfd
+// support. Processes are signaled by PID and exit is detected when the
+// process is reaped.
+
+package processctrl
+
+import (
+	"syscall"
+)
+
+// prepareHandle is a no-op on platforms without pidfd support.
+func (p *Process) prepareHandle() {}
+
+// openHandle is a no-op on platforms without pidfd support.
+func (p *Process) openHandle() {}
+
+// closeHandle is a no-op on platforms without pidfd support.
+func (p *Process) closeHandle() {}
+
+// signal delivers sig to the process by PID.
+func (p *Process) signal(sig syscall.Signal) error {
+	return p.cmd.Process.Signal(sig)
+}
@@ -0,0 +1,397 @@
+package processctrl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Pipeline runs processes connected like a shell pipeline: the standard
+// output of each stage is the standard input of the next. The stages are
+// connected with OS pipes, so the data does not pass through the
+// controller.
+type Pipeline struct {
+	stages   []*Process
+	mu       sync.Mutex
+	pipefail bool
+	started  bool
+	stderr   chan string
+	done     chan struct{} // closed once all stages have exited
+}
+
+// PipelineResult describes how the stages of a pipeline ended.
+type PipelineResult struct {
+	Stages   []*ExitResult // Results of the stages, in pipeline order
+	Status   int           // Index of the stage that determines the exit code
+	ExitCode int           // Exit code of the pipeline, -1 if that stage was killed by a signal
+}
+
+// Success reports whether the pipeline succeeded.
+func (r *PipelineResult) Success() bool {
+	return r.ExitCode == 0
+}
+
+// NewPipeline creates a pipeline of at least two stages, which must be
+// new processes. The processes are configured as usual before the
+// pipeline is run, but must not be run on their own.
+//
+// Returns an error if there are fewer than two stages, a stage is nil,
+// appears twice or has already been started.
+func NewPipeline(stages ...*Process) (*Pipeline, error) {
+	if len(stages) < 2 {
+		return nil, fmt.Errorf("a pipeline needs at least two stages")
+	}
+	seen := make(map[*Process]bool, len(stages))
+	for i, stage := range stages {
+		if stage == nil {
+			return nil, fmt.Errorf("pipeline stage %d is nil", i)
+		}
+		if seen[stage] {
+			return nil, fmt.Errorf("pipeline stage %d appears twice", i)
+		}
+		seen[stage] = true
+
+		stage.mu.RLock()
+		started := stage.cmd != nil
+		stage.mu.RUnlock()
+		if started {
+			return nil, fmt.Errorf("pipeline stage %d has already been started", i)
+		}
+	}
+	return &Pipeline{
+		stages: append([]*Process(nil), stages...),
+		stderr: make(chan string),
+		done:   make(chan struct{}),
+	}, nil
+}
+
+// Stages returns the processes of the pipeline, in pipeline order.
+func (pl *Pipeline) Stages() []*Process {
+	return append([]*Process(nil), pl.stages...)
+}
+
+// SetPipefail sets whether the pipeline fails if any stage fails, like
+// "set -o pipefail" in bash, rather than only if the last stage fails.
+// It must be called before the pipeline is run.
+//
+// Returns an error if the pipeline has been started.
+func (pl *Pipeline) SetPipefail(enabled bool) error {
+	pl.mu.Lock()
+	defer pl.mu.Unlock()
+
+	if pl.started {
+		return fmt.Errorf("cannot change pipefail of a started pipeline")
+	}
+	pl.pipefail = enabled
+	return nil
+}
+
+// CommandLine returns the pipeline as a POSIX shell command line, for
+// logs and dry runs.
+func (pl *Pipeline) CommandLine() string {
+	lines := make([]string, len(pl.stages))
+	for i, stage := range pl.stages {
+		lines[i] = stage.CommandLine()
+	}
+	return strings.Join(lines, " | ")
+}
+
+// Run starts the pipeline with a background context. This is equivalent
+// to calling RunWithContext(context.Background()).
+func (pl *Pipeline) Run() (<-chan string, <-chan string, error) {
+	return pl.RunWithContext(context.Background())
+}
+
+// RunWithContext connects and starts the stages of the pipeline with the
+// given context, from the first to the last.
+//
+// Returns the standard output of the last stage and the standard error of
+// all stages, merged in the order the lines arrive; both channels must be
+// read. Write sends data to the first stage. If a stage fails to start,
+// the stages started before it are killed.
+//
+// Returns an error if the pipeline has been started, a pipe cannot be
+// created or a stage fails to start.
+func (pl *Pipeline) RunWithContext(ctx context.Context) (<-chan string, <-chan string, error) {
+	pl.mu.Lock()
+	defer pl.mu.Unlock()
+
+	if pl.started {
+		return nil, nil, fmt.Errorf("pipeline already started")
+	}
+
+	// Pipe i connects stage i to stage i+1
+	n := len(pl.stages)
+	readers := make([]*os.File, n-1)
+	writers := make([]*os.File, n-1)
+	for i := range n - 1 {
+		r, w, err := os.Pipe()
+		if err != nil {
+			closeFiles(readers[:i])
+			closeFiles(writers[:i])
+			return nil, nil, fmt.Errorf("failed to create pipe: %w", err)
+		}
+		readers[i], writers[i] = r, w
+	}
+
+	var stdout <-chan string
+	stderrs := make([]<-chan string, 0, n)
+	for i, stage := range pl.stages {
+		stage.mu.Lock()
+		if i > 0 {
+			stage.stdinFile = readers[i-1]
+		}
+		if i < n-1 {
+			stage.stdoutFile = writers[i]
+		}
+		stage.mu.Unlock()
+
+		out, errs, err := stage.RunWithContext(ctx)
+		// The stage has its own copies of its pipe ends
+		if i > 0 {
+			_ = readers[i-1].Close() // Ignore error during cleanup
+		}
+		if i < n-1 {
+			_ = writers[i].Close() // Ignore error during cleanup
+		}
+		if err != nil {
+			closeFiles(readers[i:])
+			if i < n-1 {
+				closeFiles(writers[i+1:])
+			}
+			// The started stages get SIGKILL at once, not one after another
+			_ = pl.Kill() // Ignore error - the stages might already be dead
+			for _, errs := range stderrs {
+				go drainLines(errs)
+			}
+			return nil, nil, fmt.Errorf("failed to start pipeline stage %d: %w", i, err)
+		}
+		stdout = out
+		stderrs = append(stderrs, errs)
+	}
+	pl.started = true
+
+	var wg sync.WaitGroup
+	wg.Add(len(stderrs))
+	for _, errs := range stderrs {
+		go func() {
+			defer wg.Done()
+			for line := range errs {
+				pl.stderr <- line
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(pl.stderr)
+	}()
+	go func() {
+		for _, stage := range pl.stages {
+			<-stage.reaped
+		}
+		close(pl.done)
+	}()
+
+	return stdout, pl.stderr, nil
+}
+
+// Write sends data to the standard input of the first stage.
+//
+// Returns an error if the pipeline is not running or the write fails.
+func (pl *Pipeline) Write(data []byte) error {
+	return pl.stages[0].Write(data)
+}
+
+// WriteString sends a string to the standard input of the first stage.
+func (pl *Pipeline) WriteString(s string) error {
+	return pl.Write([]byte(s))
+}
+
+// Pause suspends all running stages.
+//
+// Returns an error if no stage is running or a stage cannot be paused.
+func (pl *Pipeline) Pause() error {
+	return pl.each((*Process).Pause)
+}
+
+// Resume continues all running stages.
+//
+// Returns an error if no stage is running or a stage cannot be resumed.
+func (pl *Pipeline) Resume() error {
+	return pl.each((*Process).Resume)
+}
+
+// Signal sends a signal to all running stages.
+//
+// Returns an error if no stage is running or the signal cannot be
+// delivered to a stage.
+func (pl *Pipeline) Signal(sig os.Signal) error {
+	return pl.each(func(p *Process) error {
+		return p.Signal(sig)
+	})
+}
+
+// Terminate gracefully terminates all running stages at once, see
+// Process.Terminate.
+//
+// Returns an error if no stage is running or a stage cannot be
+// terminated.
+func (pl *Pipeline) Terminate() error {
+	return pl.each((*Process).Terminate)
+}
+
+// Kill forcefully terminates all running stages at once with SIGKILL,
+// without graceful shutdown.
+//
+// Returns an error if no stage is running or a stage cannot be killed.
+func (pl *Pipeline) Kill() error {
+	return pl.each(func(p *Process) error {
+		return p.killWithSignal(0, false)
+	})
+}
+
+// KillWithTimeout terminates all running stages, see
+// Process.KillWithTimeout.
+//
+// Returns an error if no stage is running or a stage cannot be
+// terminated.
+func (pl *Pipeline) KillWithTimeout(timeout time.Duration) error {
+	return pl.each(func(p *Process) error {
+		return p.KillWithTimeout(timeout)
+	})
+}
+
+// each applies op to all running stages concurrently. Errors of stages
+// that exit in the meantime are ignored.
+func (pl *Pipeline) each(op func(*Process) error) error {
+	var (
+		wg      sync.WaitGroup
+		errs    = make([]error, len(pl.stages))
+		running bool
+	)
+	for i, stage := range pl.stages {
+		if !stage.IsRunning() {
+			continue
+		}
+		running = true
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := op(stage); err != nil && stage.IsRunning() {
+				errs[i] = fmt.Errorf("pipeline stage %d: %w", i, err)
+			}
+		}()
+	}
+	if !running {
+		return fmt.Errorf("pipeline is not running")
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+// IsRunning returns true if any stage is running.
+func (pl *Pipeline) IsRunning() bool {
+	for _, stage := range pl.stages {
+		if stage.IsRunning() {
+			return true
+		}
+	}
+	return false
+}
+
+// Done returns a channel that is closed once all stages have exited and
+// been reaped. The channel is never closed if the pipeline is never
+// started.
+func (pl *Pipeline) Done() <-chan struct{} {
+	return pl.done
+}
+
+// Result blocks until all stages have exited and returns their results.
+// Without pipefail, the exit code is that of the last stage. With
+// pipefail, it is that of the last stage that failed, or 0 if all
+// succeeded; a stage killed by SIGPIPE because a later stage exited
+// counts as failed, as in bash.
+//
+// Returns an error if the pipeline has not been started.
+func (pl *Pipeline) Result() (*PipelineResult, error) {
+	pl.mu.Lock()
+	started, pipefail := pl.started, pl.pipefail
+	pl.mu.Unlock()
+
+	if !started {
+		return nil, fmt.Errorf("pipeline has not been started")
+	}
+
+	result := &PipelineResult{Stages: make([]*ExitResult, len(pl.stages))}
+	for i, stage := range pl.stages {
+		r, err := stage.Result()
+		if err != nil {
+			return nil, fmt.Errorf("pipeline stage %d: %w", i, err)
+		}
+		result.Stages[i] = r
+	}
+
+	result.Status = len(pl.stages) - 1
+	if pipefail {
+		for i := len(result.Stages) - 1; i >= 0; i-- {
+			if result.Stages[i].ExitCode() != 0 {
+				result.Status = i
+				break
+			}
+		}
+	}
+	result.ExitCode = result.Stages[result.Status].ExitCode()
+	return result, nil
+}
+
+// stdoutPipe returns the pipe of the stdout channel. If a pipeline
+// connects standard output to the next stage, the channel gets no lines.
+// It must be called with p.mu held before the command is started.
+func (p *Process) stdoutPipe() (io.ReadCloser, error) {
+	if p.stdoutFile != nil {
+		p.cmd.Stdout = p.stdoutFile
+		return io.NopCloser(strings.NewReader("")), nil
+	}
+	return p.outputPipe(&p.cmd.Stdout)
+}
+
+// stdinPipe returns the pipe Write sends data to. If a pipeline connects
+// standard input to the previous stage, Write fails.
+// It must be called with p.mu held before the command is started.
+func (p *Process) stdinPipe() (io.WriteCloser, error) {
+	if p.stdinFile != nil {
+		p.cmd.Stdin = p.stdinFile
+		return connectedStdin{}, nil
+	}
+	return p.cmd.StdinPipe()
+}
+
+// connectedStdin stands in for the stdin pipe of a pipeline stage that
+// reads the output of the previous stage.
+type connectedStdin struct{}
+
+func (connectedStdin) Write([]byte) (int, error) {
+	return 0, fmt.Errorf("stdin is connected to the previous pipeline stage")
+}
+
+func (connectedStdin) Close() error {
+	return nil
+}
+
+// closeFiles closes files, ignoring errors.
+func closeFiles(files []*os.File) {
+	for _, f := range files {
+		_ = f.Close() // Ignore error during cleanup
+	}
+}
+
+// drainLines discards the lines of a channel until it is closed.
+func drainLines(ch <-chan string) {
+	for range ch {
+	}
+}
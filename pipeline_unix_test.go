@@ -0,0 +1,259 @@
+//go:build linux || darwin
+
+package processctrl
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// newTestPipeline returns a pipeline of shell commands.
+func newTestPipeline(t *testing.T, commands ...string) *Pipeline {
+	t.Helper()
+
+	stages := make([]*Process, len(commands))
+	for i, command := range commands {
+		stages[i] = New("sh", "-c", command)
+	}
+	pl, err := NewPipeline(stages...)
+	if err != nil {
+		t.Fatalf("NewPipeline() failed: %v", err)
+	}
+	return pl
+}
+
+// runPipeline runs a pipeline and returns its output lines, stderr lines
+// and result.
+func runPipeline(t *testing.T, pl *Pipeline) ([]string, []string, *PipelineResult) {
+	t.Helper()
+
+	stdout, stderr, err := pl.Run()
+	if err != nil {
+		t.Fatalf("Run() failed: %v", err)
+	}
+	errLines := make(chan []string, 1)
+	go func() {
+		var lines []string
+		for line := range stderr {
+			lines = append(lines, line)
+		}
+		errLines <- lines
+	}()
+	var lines []string
+	for line := range stdout {
+		lines = append(lines, line)
+	}
+
+	select {
+	case <-pl.Done():
+	case <-time.After(testTimeout * time.Second):
+		t.Fatal("Pipeline did not exit")
+	}
+	result, err := pl.Result()
+	if err != nil {
+		t.Fatalf("Result() failed: %v", err)
+	}
+	return lines, <-errLines, result
+}
+
+func TestPipelineConnectsStages(t *testing.T) {
+	pl := newTestPipeline(t, "printf 'b\\na\\nb\\n'; echo first >&2", "sort", "uniq; echo last >&2")
+	lines, errLines, result := runPipeline(t, pl)
+	if strings.Join(lines, "\n") != "a\nb" {
+		t.Errorf("Output = %q, want a, b", lines)
+	}
+	if len(errLines) != 2 {
+		t.Errorf("Stderr = %q, want lines of the first and last stage", errLines)
+	}
+	if !result.Success() || len(result.Stages) != 3 {
+		t.Errorf("Result = %+v, want success of 3 stages", result)
+	}
+}
+
+func TestPipelineWriteToFirstStage(t *testing.T) {
+	pl := newTestPipeline(t, "head -n 1", "tr a-z A-Z")
+	stdout, stderr, err := pl.Run()
+	if err != nil {
+		t.Fatalf("Run() failed: %v", err)
+	}
+	go drainLines(stderr)
+	if err := pl.WriteString("hello\n"); err != nil {
+		t.Fatalf("WriteString() failed: %v", err)
+	}
+	if err := pl.Stages()[1].WriteString("x\n"); err == nil {
+		t.Error("Writing to a connected stage succeeded")
+	}
+	var lines []string
+	for line := range stdout {
+		lines = append(lines, line)
+	}
+	if strings.Join(lines, "\n") != "HELLO" {
+		t.Errorf("Output = %q, want HELLO", lines)
+	}
+}
+
+func TestPipelinePipefail(t *testing.T) {
+	for _, pipefail := range []bool{false, true} {
+		pl := newTestPipeline(t, "exit 3", "cat", "exit 0")
+		if err := pl.SetPipefail(pipefail); err != nil {
+			t.Fatalf("SetPipefail() failed: %v", err)
+		}
+		_, _, result := runPipeline(t, pl)
+
+		wantStatus, wantCode := 2, 0
+		if pipefail {
+			wantStatus, wantCode = 0, 3
+		}
+		if result.Status != wantStatus || result.ExitCode != wantCode {
+			t.Errorf("pipefail %v: Status = %d, ExitCode = %d, want %d, %d",
+				pipefail, result.Status, result.ExitCode, wantStatus, wantCode)
+		}
+		if code := result.Stages[0].ExitCode(); code != 3 {
+			t.Errorf("pipefail %v: first stage ExitCode() = %d, want 3", pipefail, code)
+		}
+	}
+}
+
+func TestPipelinePauseResumeTerminate(t *testing.T) {
+	pl := newTestPipeline(t, "while :; do echo x; sleep 0.05; done", "cat")
+	stdout, stderr, err := pl.Run()
+	if err != nil {
+		t.Fatalf("Run() failed: %v", err)
+	}
+	go drainLines(stderr)
+	<-stdout
+
+	if err := pl.Pause(); err != nil {
+		t.Fatalf("Pause() failed: %v", err)
+	}
+	for i, stage := range pl.Stages() {
+		if !stage.IsPaused() {
+			t.Errorf("Stage %d is not paused", i)
+		}
+	}
+	if err := pl.Resume(); err != nil {
+		t.Fatalf("Resume() failed: %v", err)
+	}
+	<-stdout
+
+	go drainLines(stdout)
+	if err := pl.Terminate(); err != nil {
+		t.Fatalf("Terminate() failed: %v", err)
+	}
+	select {
+	case <-pl.Done():
+	case <-time.After(testTimeout * time.Second):
+		t.Fatal("Pipeline did not exit")
+	}
+	if pl.IsRunning() {
+		t.Error("IsRunning() = true after Terminate")
+	}
+	if err := pl.Pause(); err == nil {
+		t.Error("Pause() of an exited pipeline succeeded")
+	}
+	result, err := pl.Result()
+	if err != nil {
+		t.Fatalf("Result() failed: %v", err)
+	}
+	for i, r := range result.Stages {
+		if ws, ok := r.State.Sys().(syscall.WaitStatus); !ok || ws.Signal() != syscall.SIGTERM {
+			t.Errorf("Stage %d state = %v, want SIGTERM", i, r.State)
+		}
+	}
+}
+
+func TestPipelineUsesOSPipes(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("descriptors are inspected through /proc")
+	}
+	pl := newTestPipeline(t, "sleep 10", "sleep 10")
+	stdout, stderr, err := pl.Run()
+	if err != nil {
+		t.Fatalf("Run() failed: %v", err)
+	}
+	go drainLines(stdout)
+	go drainLines(stderr)
+	defer func() { _ = pl.Kill() }() // Ignore error - the stages might already be dead
+
+	stages := pl.Stages()
+	out, err1 := os.Readlink(fmt.Sprintf("/proc/%d/fd/1", stages[0].PID()))
+	in, err2 := os.Readlink(fmt.Sprintf("/proc/%d/fd/0", stages[1].PID()))
+	if err1 != nil || err2 != nil || out != in || !strings.HasPrefix(out, "pipe:") {
+		t.Errorf("Stage output %q (%v), next stage input %q (%v), want the same pipe", out, err1, in, err2)
+	}
+}
+
+func TestPipelineStartFailure(t *testing.T) {
+	// The started stages ignore SIGTERM, so they must be killed right away
+	first := New("sh", "-c", `trap "" TERM; sleep 10`)
+	second := New("sh", "-c", `trap "" TERM; sleep 10`)
+	pl, err := NewPipeline(first, second, New("no-such-program-xyz"))
+	if err != nil {
+		t.Fatalf("NewPipeline() failed: %v", err)
+	}
+	start := time.Now()
+	if _, _, err := pl.Run(); err == nil || !strings.Contains(err.Error(), "stage 2") {
+		t.Fatalf("Run() error = %v, want failure of stage 2", err)
+	}
+	if elapsed := time.Since(start); elapsed >= defaultKillTimeout {
+		t.Errorf("Run() took %v, the started stages were terminated gracefully", elapsed)
+	}
+	for i, stage := range []*Process{first, second} {
+		select {
+		case <-stage.Done():
+		case <-time.After(testTimeout * time.Second):
+			t.Fatalf("Stage %d was not killed", i)
+		}
+	}
+}
+
+func TestPipelineKill(t *testing.T) {
+	// The stages ignore SIGTERM, so Kill must not terminate them gracefully
+	pl := newTestPipeline(t, `trap "" TERM; sleep 10`, `trap "" TERM; sleep 10`)
+	stdout, stderr, err := pl.Run()
+	if err != nil {
+		t.Fatalf("Run() failed: %v", err)
+	}
+	go drainLines(stdout)
+	go drainLines(stderr)
+
+	start := time.Now()
+	if err := pl.Kill(); err != nil {
+		t.Fatalf("Kill() failed: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed >= defaultKillTimeout {
+		t.Errorf("Kill() took %v, the stages were terminated gracefully", elapsed)
+	}
+	for i, stage := range pl.Stages() {
+		select {
+		case <-stage.Done():
+		case <-time.After(testTimeout * time.Second):
+			t.Fatalf("Stage %d was not killed", i)
+		}
+	}
+}
+
+func TestNewPipelineInvalid(t *testing.T) {
+	proc := New("true")
+	started := New("true")
+	if _, _, err := started.Run(); err != nil {
+		t.Fatalf("Run() failed: %v", err)
+	}
+	waitResult(t, started)
+
+	for name, stages := range map[string][]*Process{
+		"one stage": {proc},
+		"nil stage": {proc, nil},
+		"duplicate": {proc, proc},
+		"started":   {proc, started},
+	} {
+		if _, err := NewPipeline(stages...); err == nil {
+			t.Errorf("NewPipeline() with %s succeeded", name)
+		}
+	}
+}
@@ -0,0 +1,484 @@
+package processctrl
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// defaultPressureInterval is how often a PressureGuard checks the
+	// host pressure if no interval is configured.
+	defaultPressureInterval = 5 * time.Second
+	// defaultPressureLowRatio is the fraction of a high threshold used as
+	// the low threshold if none is configured.
+	defaultPressureLowRatio = 0.75
+)
+
+// Pressure is a snapshot of the load on the host.
+type Pressure struct {
+	Load         float64 // 1-minute load average divided by the number of CPUs
+	CPU          float64 // CPU pressure stall percentage ("some", 10s average)
+	Memory       float64 // Memory pressure stall percentage ("some", 10s average)
+	IO           float64 // I/O pressure stall percentage ("some", 10s average)
+	PSIAvailable bool    // CPU, Memory and IO are valid; false if the kernel lacks PSI
+}
+
+// PressureThresholds are limits for the values of a Pressure snapshot.
+// A zero value disables the corresponding check.
+type PressureThresholds struct {
+	Load   float64 // Load average per CPU, e.g. 1.5
+	CPU    float64 // CPU stall percentage, e.g. 40
+	Memory float64 // Memory stall percentage
+	IO     float64 // I/O stall percentage
+}
+
+// usesPSI reports whether any pressure stall threshold is set.
+func (t PressureThresholds) usesPSI() bool {
+	return t.CPU > 0 || t.Memory > 0 || t.IO > 0
+}
+
+// exceeded describes the thresholds that p reaches, or returns an empty
+// string if p is below all of them.
+func (t PressureThresholds) exceeded(p Pressure) string {
+	checks := []struct {
+		name         string
+		value, limit float64
+		psi          bool
+	}{
+		{"load", p.Load, t.Load, false},
+		{"cpu pressure", p.CPU, t.CPU, true},
+		{"memory pressure", p.Memory, t.Memory, true},
+		{"io pressure", p.IO, t.IO, true},
+	}
+
+	var reasons []string
+	for _, c := range checks {
+		if c.limit <= 0 || (c.psi && !p.PSIAvailable) {
+			continue
+		}
+		if c.value >= c.limit {
+			reasons = append(reasons, fmt.Sprintf("%s %.2f >= %.2f", c.name, c.value, c.limit))
+		}
+	}
+	return strings.Join(reasons, ", ")
+}
+
+// PressureGuardConfig configures a PressureGuard.
+//
+// Processes are paused while any High threshold is reached and resumed
+// once all values have stayed below the Low thresholds for ResumeAfter.
+// The gap between the two thresholds provides hysteresis, so processes do
+// not flap between paused and running around a single limit.
+type PressureGuardConfig struct {
+	High        PressureThresholds // Pause when any of these is reached
+	Low         PressureThresholds // Resume when all values are below; unset fields default to 75% of High
+	Interval    time.Duration      // Time between checks; defaults to 5s
+	ResumeAfter time.Duration      // Time below Low before each resume; defaults to Interval
+}
+
+// PressureGuard automatically pauses low-priority processes while the
+// host is under pressure, as measured by the load average and, on kernels
+// that support it, pressure stall information (PSI).
+//
+// At most one process is paused or resumed per check, so that load is
+// shed gradually. Processes are paused in order of ascending priority and
+// resumed in the reverse order; processes with equal priority are paused
+// in the order they were added.
+//
+// Like Scheduler, the guard never resumes a process that it did not pause
+// itself. If a process it paused is resumed manually, it is left running
+// until the pressure has dropped below the low thresholds again.
+type PressureGuard struct {
+	cfg       PressureGuardConfig
+	read      func() (Pressure, error)
+	mu        sync.Mutex
+	members   []*guardedProcess
+	seq       int
+	running   bool
+	calmSince time.Time // first check below the low thresholds, zero if not calm
+	stop      chan struct{}
+	done      chan struct{}
+	events    *eventEmitter
+}
+
+// guardedProcess is a process managed by a PressureGuard.
+type guardedProcess struct {
+	ctrl       Controller
+	priority   int
+	seq        int  // insertion order, breaks priority ties
+	paused     bool // paused by the guard
+	overridden bool // resumed manually while paused by the guard
+}
+
+// NewPressureGuard creates a guard with the given configuration. Processes
+// are added with Add; the guard is not active until Start is called.
+//
+// Only Linux is supported, since pressure is read from /proc; other
+// platforms return ErrNotSupported.
+//
+// Returns an error if no threshold is set, a threshold is invalid, or a
+// pressure stall threshold is set on a kernel without PSI support.
+func NewPressureGuard(cfg PressureGuardConfig) (*PressureGuard, error) {
+	return newPressureGuard(cfg, ReadPressure)
+}
+
+// newPressureGuard creates a guard that reads the host pressure with read.
+func newPressureGuard(cfg PressureGuardConfig, read func() (Pressure, error)) (*PressureGuard, error) {
+	if cfg.High == (PressureThresholds{}) {
+		return nil, fmt.Errorf("no pressure thresholds set")
+	}
+	if cfg.Interval < 0 || cfg.ResumeAfter < 0 {
+		return nil, fmt.Errorf("invalid pressure guard interval")
+	}
+	if cfg.Interval == 0 {
+		cfg.Interval = defaultPressureInterval
+	}
+	if cfg.ResumeAfter == 0 {
+		cfg.ResumeAfter = cfg.Interval
+	}
+
+	limits := []struct {
+		name      string
+		high, low *float64
+	}{
+		{"load", &cfg.High.Load, &cfg.Low.Load},
+		{"cpu", &cfg.High.CPU, &cfg.Low.CPU},
+		{"memory", &cfg.High.Memory, &cfg.Low.Memory},
+		{"io", &cfg.High.IO, &cfg.Low.IO},
+	}
+	for _, l := range limits {
+		if *l.high < 0 || *l.low < 0 {
+			return nil, fmt.Errorf("invalid %s threshold", l.name)
+		}
+		if *l.high == 0 {
+			*l.low = 0
+			continue
+		}
+		if *l.low == 0 {
+			*l.low = *l.high * defaultPressureLowRatio
+		}
+		if *l.low > *l.high {
+			return nil, fmt.Errorf("low %s threshold %v is above high threshold %v", l.name, *l.low, *l.high)
+		}
+	}
+
+	p, err := read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read pressure: %w", err)
+	}
+	if cfg.High.usesPSI() && !p.PSIAvailable {
+		return nil, fmt.Errorf("pressure stall information not available: %w", ErrNotSupported)
+	}
+
+	return &PressureGuard{cfg: cfg, read: read, events: newEventEmitter()}, nil
+}
+
+// Add places a process under the guard.
+//
+// Parameters:
+//   - ctrl: The process to guard, e.g. a *Process or *AttachedProcess
+//   - priority: Processes with lower priority are paused first
+//
+// Returns an error if the process is already guarded.
+func (g *PressureGuard) Add(ctrl Controller, priority int) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.find(ctrl) >= 0 {
+		return fmt.Errorf("process already guarded")
+	}
+	g.seq++
+	g.members = append(g.members, &guardedProcess{ctrl: ctrl, priority: priority, seq: g.seq})
+	return nil
+}
+
+// Remove releases a process from the guard, resuming it if the guard
+// paused it.
+//
+// Returns an error if the process is not guarded or resuming fails.
+func (g *PressureGuard) Remove(ctrl Controller) error {
+	g.mu.Lock()
+	i := g.find(ctrl)
+	if i < 0 {
+		g.mu.Unlock()
+		return fmt.Errorf("process not guarded")
+	}
+	m := g.members[i]
+	g.members = append(g.members[:i], g.members[i+1:]...)
+	paused := m.paused
+	m.paused = false
+	g.mu.Unlock()
+
+	if !paused {
+		return nil
+	}
+	return g.release(m)
+}
+
+// find returns the index of ctrl in the member list, or -1.
+// It must be called with g.mu held.
+func (g *PressureGuard) find(ctrl Controller) int {
+	for i, m := range g.members {
+		if m.ctrl == ctrl {
+			return i
+		}
+	}
+	return -1
+}
+
+// Start begins checking the host pressure every Interval.
+//
+// Returns an error if the guard is already running.
+func (g *PressureGuard) Start() error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.running {
+		return fmt.Errorf("pressure guard already running")
+	}
+	g.running = true
+	g.calmSince = time.Time{}
+	g.stop = make(chan struct{})
+	g.done = make(chan struct{})
+
+	go g.run(g.stop, g.done)
+	return nil
+}
+
+// Stop ends checking and resumes all processes the guard paused, so that
+// they are not left paused without anyone to resume them.
+//
+// Returns an error if the guard is not running or resuming a process
+// fails.
+func (g *PressureGuard) Stop() error {
+	g.mu.Lock()
+	if !g.running {
+		g.mu.Unlock()
+		return fmt.Errorf("pressure guard not running")
+	}
+	g.running = false
+	close(g.stop)
+	done := g.done
+	g.mu.Unlock()
+
+	<-done
+
+	g.mu.Lock()
+	var paused []*guardedProcess
+	for _, m := range g.members {
+		if m.paused {
+			m.paused = false
+			paused = append(paused, m)
+		}
+	}
+	g.mu.Unlock()
+
+	var firstErr error
+	for _, m := range paused {
+		if err := g.release(m); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// Events returns a channel that receives an EventAutoPaused event for
+// every process the guard pauses and an EventAutoResumed event for every
+// process it resumes. The message holds the pressure values involved.
+// The channel is buffered; events are dropped when the buffer is full.
+// It is never closed, since a stopped guard can be started again.
+func (g *PressureGuard) Events() <-chan Event {
+	return g.events.ch
+}
+
+// run is the checking loop.
+func (g *PressureGuard) run(stop, done chan struct{}) {
+	defer close(done)
+
+	ticker := time.NewTicker(g.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		g.check(time.Now())
+
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// check reads the host pressure and pauses or resumes one process.
+// A failed read is skipped; the next check tries again. The process is
+// chosen with g.mu held, but Pause and Resume, which can take a while,
+// are called after releasing it.
+func (g *PressureGuard) check(now time.Time) {
+	p, err := g.read()
+	if err != nil {
+		return
+	}
+
+	g.mu.Lock()
+	g.prune()
+
+	if reason := g.cfg.High.exceeded(p); reason != "" {
+		g.calmSince = time.Time{}
+		candidates := g.pauseCandidates()
+		g.mu.Unlock()
+		g.pauseNext(candidates, reason)
+		return
+	}
+	if g.cfg.Low.exceeded(p) != "" {
+		// Between the thresholds, keep the current state
+		g.calmSince = time.Time{}
+		g.mu.Unlock()
+		return
+	}
+
+	for _, m := range g.members {
+		m.overridden = false
+	}
+	if g.calmSince.IsZero() {
+		g.calmSince = now
+	}
+	var candidates []*guardedProcess
+	if now.Sub(g.calmSince) >= g.cfg.ResumeAfter {
+		candidates = g.resumeCandidates()
+	}
+	g.mu.Unlock()
+
+	if g.resumeNext(candidates, pressureSummary(p)) {
+		g.mu.Lock()
+		g.calmSince = now
+		g.mu.Unlock()
+	}
+}
+
+// prune drops exited processes and notices manual resumes.
+// It must be called with g.mu held.
+func (g *PressureGuard) prune() {
+	members := g.members[:0]
+	for _, m := range g.members {
+		if !m.ctrl.IsRunning() {
+			continue
+		}
+		if m.paused && !m.ctrl.IsPaused() {
+			m.paused = false
+			m.overridden = true
+		}
+		members = append(members, m)
+	}
+	g.members = members
+}
+
+// pauseCandidates returns the members the guard may pause, in pause
+// order.
+// It must be called with g.mu held.
+func (g *PressureGuard) pauseCandidates() []*guardedProcess {
+	var candidates []*guardedProcess
+	for _, m := range g.ordered() {
+		if !m.paused && !m.overridden && !m.ctrl.IsPaused() {
+			candidates = append(candidates, m)
+		}
+	}
+	return candidates
+}
+
+// pauseNext pauses the first of the candidates that can be paused. A
+// process removed from the guard while it was being paused is resumed
+// again.
+// It must be called without g.mu held.
+func (g *PressureGuard) pauseNext(candidates []*guardedProcess, reason string) {
+	for _, m := range candidates {
+		if m.ctrl.Pause() != nil {
+			// Exited or paused concurrently, try the next one
+			continue
+		}
+
+		g.mu.Lock()
+		guarded := g.find(m.ctrl) >= 0
+		if guarded {
+			m.paused = true
+		}
+		g.mu.Unlock()
+
+		if !guarded {
+			_ = m.ctrl.Resume() // Ignore error - process might have exited
+			return
+		}
+		g.events.emit(EventAutoPaused, m.ctrl.PID(), reason)
+		return
+	}
+}
+
+// resumeCandidates returns the members the guard paused, in resume
+// order.
+// It must be called with g.mu held.
+func (g *PressureGuard) resumeCandidates() []*guardedProcess {
+	var candidates []*guardedProcess
+	ordered := g.ordered()
+	for i := len(ordered) - 1; i >= 0; i-- {
+		if ordered[i].paused {
+			candidates = append(candidates, ordered[i])
+		}
+	}
+	return candidates
+}
+
+// resumeNext resumes the first of the candidates that the guard still
+// holds paused and reports whether one was resumed.
+// It must be called without g.mu held.
+func (g *PressureGuard) resumeNext(candidates []*guardedProcess, reason string) bool {
+	for _, m := range candidates {
+		g.mu.Lock()
+		paused := m.paused
+		m.paused = false
+		g.mu.Unlock()
+
+		if !paused || !m.ctrl.IsPaused() || m.ctrl.Resume() != nil {
+			continue
+		}
+		g.events.emit(EventAutoResumed, m.ctrl.PID(), reason)
+		return true
+	}
+	return false
+}
+
+// ordered returns the members in pause order.
+// It must be called with g.mu held.
+func (g *PressureGuard) ordered() []*guardedProcess {
+	ordered := append([]*guardedProcess(nil), g.members...)
+	sort.Slice(ordered, func(i, j int) bool {
+		if ordered[i].priority != ordered[j].priority {
+			return ordered[i].priority < ordered[j].priority
+		}
+		return ordered[i].seq < ordered[j].seq
+	})
+	return ordered
+}
+
+// release resumes a process that the guard paused, once m.paused has
+// been cleared.
+// It must be called without g.mu held.
+func (g *PressureGuard) release(m *guardedProcess) error {
+	if m.ctrl.IsRunning() && m.ctrl.IsPaused() {
+		if err := m.ctrl.Resume(); err != nil {
+			return err
+		}
+		g.events.emit(EventAutoResumed, m.ctrl.PID(), "pressure guard released the process")
+	}
+	return nil
+}
+
+// pressureSummary formats the values of a pressure snapshot.
+func pressureSummary(p Pressure) string {
+	if !p.PSIAvailable {
+		return fmt.Sprintf("load %.2f", p.Load)
+	}
+	return fmt.Sprintf("load %.2f, cpu pressure %.2f, memory pressure %.2f, io pressure %.2f",
+		p.Load, p.CPU, p.Memory, p.IO)
+}
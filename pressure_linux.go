@@ -0,0 +1,82 @@
+//go:build linux
+
+// Package processctrl Linux host pressure
+//
+// This file reads the load average from /proc/loadavg and pressure stall
+// information (PSI) from /proc/pressure for PressureGuard.
+
+package processctrl
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// ReadPressure returns the current load average and, if the kernel
+// supports it, pressure stall information of the host.
+//
+// Returns an error if the load average cannot be read. Missing PSI
+// support is not an error; PSIAvailable is false in that case.
+func ReadPressure() (Pressure, error) {
+	data, err := os.ReadFile("/proc/loadavg")
+	if err != nil {
+		return Pressure{}, err
+	}
+	load, err := parseLoadAvg(data)
+	if err != nil {
+		return Pressure{}, err
+	}
+
+	p := Pressure{Load: load / float64(runtime.NumCPU()), PSIAvailable: true}
+	resources := []struct {
+		name string
+		dst  *float64
+	}{
+		{"cpu", &p.CPU},
+		{"memory", &p.Memory},
+		{"io", &p.IO},
+	}
+	for _, r := range resources {
+		// Reading fails with EOPNOTSUPP if PSI is disabled at boot
+		data, err := os.ReadFile("/proc/pressure/" + r.name)
+		if err == nil {
+			*r.dst, err = parsePSI(data)
+		}
+		if err != nil {
+			return Pressure{Load: p.Load}, nil
+		}
+	}
+	return p, nil
+}
+
+// parseLoadAvg returns the 1-minute load average from /proc/loadavg.
+func parseLoadAvg(data []byte) (float64, error) {
+	fields := strings.Fields(string(data))
+	if len(fields) == 0 {
+		return 0, fmt.Errorf("malformed loadavg data")
+	}
+	return strconv.ParseFloat(fields[0], 64)
+}
+
+// parsePSI returns the "some" avg10 value from a /proc/pressure file,
+// which has lines such as:
+//
+//	some avg10=1.53 avg60=0.87 avg300=0.29 total=1234567
+func parsePSI(data []byte) (float64, error) {
+	for _, line := range bytes.Split(data, []byte("\n")) {
+		fields := strings.Fields(string(line))
+		if len(fields) < 2 || fields[0] != "some" {
+			continue
+		}
+		for _, f := range fields[1:] {
+			if value, ok := strings.CutPrefix(f, "avg10="); ok {
+				return strconv.ParseFloat(value, 64)
+			}
+		}
+	}
+	return 0, fmt.Errorf("malformed pressure data")
+}
@@ -0,0 +1,42 @@
+//go:build linux
+
+package processctrl
+
+import "testing"
+
+func TestParsePSI(t *testing.T) {
+	data := []byte("some avg10=12.50 avg60=3.10 avg300=0.80 total=123456\n" +
+		"full avg10=4.00 avg60=1.00 avg300=0.20 total=23456\n")
+
+	got, err := parsePSI(data)
+	if err != nil {
+		t.Fatalf("parsePSI() failed: %v", err)
+	}
+	if got != 12.5 {
+		t.Errorf("Expected avg10 12.5, got %v", got)
+	}
+
+	if _, err := parsePSI([]byte("full avg10=1.00\n")); err == nil {
+		t.Error("parsePSI() should fail without a \"some\" line")
+	}
+}
+
+func TestParseLoadAvg(t *testing.T) {
+	got, err := parseLoadAvg([]byte("0.21 0.12 0.09 2/72 9702\n"))
+	if err != nil {
+		t.Fatalf("parseLoadAvg() failed: %v", err)
+	}
+	if got != 0.21 {
+		t.Errorf("Expected load 0.21, got %v", got)
+	}
+}
+
+func TestReadPressure(t *testing.T) {
+	p, err := ReadPressure()
+	if err != nil {
+		t.Fatalf("ReadPressure() failed: %v", err)
+	}
+	if p.Load < 0 || p.CPU < 0 || p.Memory < 0 || p.IO < 0 {
+		t.Errorf("Pressure values should not be negative: %+v", p)
+	}
+}
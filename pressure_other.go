@@ -0,0 +1,12 @@
+//go:build !linux
+
+// Package processctrl host pressure stub
+//
+// Host pressure is read from /proc and is only available on Linux.
+
+package processctrl
+
+// ReadPressure is not supported on this platform.
+func ReadPressure() (Pressure, error) {
+	return Pressure{}, ErrNotSupported
+}
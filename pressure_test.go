@@ -0,0 +1,208 @@
+package processctrl
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// fakePressure returns a pressure reader reporting *load and full PSI
+// support.
+func fakePressure(load *float64) func() (Pressure, error) {
+	return func() (Pressure, error) {
+		return Pressure{Load: *load, PSIAvailable: true}, nil
+	}
+}
+
+// nextEvent returns the next event or fails if none arrives in time.
+func nextEvent(t *testing.T, events <-chan Event) Event {
+	t.Helper()
+
+	select {
+	case ev := <-events:
+		return ev
+	case <-time.After(testTimeout * time.Second):
+		t.Fatal("timed out waiting for event")
+		return Event{}
+	}
+}
+
+func TestPressureGuardOrderAndHysteresis(t *testing.T) {
+	low := newLongRunning()
+	startTest(t, low)
+	high := newLongRunning()
+	startTest(t, high)
+
+	load := 0.0
+	g, err := newPressureGuard(PressureGuardConfig{
+		High:        PressureThresholds{Load: 1.0},
+		ResumeAfter: time.Minute,
+	}, fakePressure(&load))
+	if err != nil {
+		t.Fatalf("newPressureGuard() failed: %v", err)
+	}
+	if err := g.Add(high, 10); err != nil {
+		t.Fatalf("Add() failed: %v", err)
+	}
+	if err := g.Add(low, 0); err != nil {
+		t.Fatalf("Add() failed: %v", err)
+	}
+	if err := g.Add(low, 5); err == nil {
+		t.Error("Add() should fail for a process that is already guarded")
+	}
+
+	now := time.Now()
+	check := func(l float64, after time.Duration) {
+		load = l
+		now = now.Add(after)
+		g.check(now)
+	}
+
+	// Lowest priority is paused first, one process per check
+	check(2.0, 0)
+	if ev := nextEvent(t, g.Events()); ev.Type != EventAutoPaused || ev.PID != low.PID() {
+		t.Errorf("Expected auto-pause of low priority process %d, got %v for %d", low.PID(), ev.Type, ev.PID)
+	}
+	if !low.IsPaused() || high.IsPaused() {
+		t.Fatal("Only the low priority process should be paused after the first check")
+	}
+	check(2.0, time.Second)
+	if ev := nextEvent(t, g.Events()); ev.Type != EventAutoPaused || ev.PID != high.PID() {
+		t.Errorf("Expected auto-pause of high priority process %d, got %v for %d", high.PID(), ev.Type, ev.PID)
+	}
+
+	// Between the thresholds nothing changes
+	check(0.9, time.Hour)
+	if !low.IsPaused() || !high.IsPaused() {
+		t.Fatal("Processes should stay paused between the thresholds")
+	}
+
+	// Below the low threshold, resume in reverse order after ResumeAfter
+	check(0.5, time.Second)
+	if !high.IsPaused() {
+		t.Fatal("Processes should not be resumed before ResumeAfter has passed")
+	}
+	check(0.5, time.Minute)
+	if ev := nextEvent(t, g.Events()); ev.Type != EventAutoResumed || ev.PID != high.PID() {
+		t.Errorf("Expected auto-resume of high priority process %d, got %v for %d", high.PID(), ev.Type, ev.PID)
+	}
+	if !low.IsPaused() || high.IsPaused() {
+		t.Fatal("Only the high priority process should be resumed first")
+	}
+	check(0.5, time.Minute)
+	if ev := nextEvent(t, g.Events()); ev.Type != EventAutoResumed || ev.PID != low.PID() {
+		t.Errorf("Expected auto-resume of low priority process %d, got %v for %d", low.PID(), ev.Type, ev.PID)
+	}
+	if low.IsPaused() {
+		t.Error("Low priority process should be resumed")
+	}
+}
+
+func TestPressureGuardManualResume(t *testing.T) {
+	proc := newLongRunning()
+	startTest(t, proc)
+
+	load := 2.0
+	g, err := newPressureGuard(PressureGuardConfig{
+		High: PressureThresholds{Load: 1.0},
+	}, fakePressure(&load))
+	if err != nil {
+		t.Fatalf("newPressureGuard() failed: %v", err)
+	}
+	if err := g.Add(proc, 0); err != nil {
+		t.Fatalf("Add() failed: %v", err)
+	}
+
+	now := time.Now()
+	g.check(now)
+	if !proc.IsPaused() {
+		t.Fatal("Process should be paused under pressure")
+	}
+	if err := proc.Resume(); err != nil {
+		t.Fatalf("Resume() failed: %v", err)
+	}
+
+	// A manual resume is respected while the pressure stays high
+	g.check(now.Add(time.Minute))
+	if proc.IsPaused() {
+		t.Error("Manually resumed process should not be paused again while pressure stays high")
+	}
+
+	// Once the pressure has dropped, the process is guarded again
+	load = 0.1
+	g.check(now.Add(2 * time.Minute))
+	load = 2.0
+	g.check(now.Add(3 * time.Minute))
+	if !proc.IsPaused() {
+		t.Error("Process should be paused again after the pressure rose anew")
+	}
+}
+
+func TestPressureGuardStopResumes(t *testing.T) {
+	proc := newLongRunning()
+	startTest(t, proc)
+
+	load := 2.0
+	g, err := newPressureGuard(PressureGuardConfig{
+		High:     PressureThresholds{Load: 1.0},
+		Interval: 10 * time.Millisecond,
+	}, fakePressure(&load))
+	if err != nil {
+		t.Fatalf("newPressureGuard() failed: %v", err)
+	}
+	if err := g.Add(proc, 0); err != nil {
+		t.Fatalf("Add() failed: %v", err)
+	}
+	if err := g.Start(); err != nil {
+		t.Fatalf("Start() failed: %v", err)
+	}
+	if err := g.Start(); err == nil {
+		t.Error("Start() should fail when the guard is already running")
+	}
+
+	if ev := nextEvent(t, g.Events()); ev.Type != EventAutoPaused {
+		t.Errorf("Expected %v event, got %v", EventAutoPaused, ev.Type)
+	}
+	if err := g.Stop(); err != nil {
+		t.Fatalf("Stop() failed: %v", err)
+	}
+	if proc.IsPaused() {
+		t.Error("Stop() should resume processes paused by the guard")
+	}
+}
+
+func TestNewPressureGuardInvalid(t *testing.T) {
+	load := 0.0
+	noPSI := func() (Pressure, error) { return Pressure{}, nil }
+
+	tests := []struct {
+		name string
+		cfg  PressureGuardConfig
+		read func() (Pressure, error)
+	}{
+		{"no thresholds", PressureGuardConfig{}, fakePressure(&load)},
+		{"negative threshold", PressureGuardConfig{High: PressureThresholds{Load: -1}}, fakePressure(&load)},
+		{"low above high", PressureGuardConfig{
+			High: PressureThresholds{CPU: 20},
+			Low:  PressureThresholds{CPU: 30},
+		}, fakePressure(&load)},
+		{"negative interval", PressureGuardConfig{
+			High:     PressureThresholds{Load: 1},
+			Interval: -time.Second,
+		}, fakePressure(&load)},
+		{"no PSI", PressureGuardConfig{High: PressureThresholds{Memory: 10}}, noPSI},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := newPressureGuard(tt.cfg, tt.read); err == nil {
+				t.Error("newPressureGuard() should fail")
+			}
+		})
+	}
+
+	_, err := newPressureGuard(PressureGuardConfig{High: PressureThresholds{IO: 10}}, noPSI)
+	if !errors.Is(err, ErrNotSupported) {
+		t.Errorf("Expected ErrNotSupported without PSI, got %v", err)
+	}
+}
@@ -0,0 +1,286 @@
+package processctrl
+
+import "fmt"
+
+// SchedPolicy is a scheduling policy for processes that are not
+// real-time, see sched(7).
+type SchedPolicy int
+
+const (
+	SchedNormal SchedPolicy = iota // SCHED_OTHER: the default time-sharing policy
+	SchedBatch                     // SCHED_BATCH: CPU-bound work that is preempted less often
+	SchedIdle                      // SCHED_IDLE: runs only when the CPU would otherwise be idle
+)
+
+// String returns a human-readable name for the policy.
+func (s SchedPolicy) String() string {
+	switch s {
+	case SchedNormal:
+		return "normal"
+	case SchedBatch:
+		return "batch"
+	case SchedIdle:
+		return "idle"
+	default:
+		return fmt.Sprintf("SchedPolicy(%d)", int(s))
+	}
+}
+
+// IOPrioClass is an I/O scheduling class, see ioprio_set(2).
+type IOPrioClass int
+
+const (
+	IOPrioClassNone       IOPrioClass = iota // No class set; derived from the nice value
+	IOPrioClassRealtime                      // Served before all others; requires CAP_SYS_ADMIN
+	IOPrioClassBestEffort                    // The default class for most processes
+	IOPrioClassIdle                          // Served only when no other process needs the disk
+)
+
+// String returns a human-readable name for the class.
+func (c IOPrioClass) String() string {
+	switch c {
+	case IOPrioClassNone:
+		return "none"
+	case IOPrioClassRealtime:
+		return "realtime"
+	case IOPrioClassBestEffort:
+		return "best-effort"
+	case IOPrioClassIdle:
+		return "idle"
+	default:
+		return fmt.Sprintf("IOPrioClass(%d)", int(c))
+	}
+}
+
+// IOPriority is the I/O scheduling class and priority level of a process.
+// It only takes effect with I/O schedulers that support priorities, such
+// as BFQ.
+type IOPriority struct {
+	Class IOPrioClass
+	Level int // 0 (highest) to 7 (lowest) within the realtime and best-effort classes; 0 for IOPrioClassNone
+}
+
+// schedAttrs are the scheduling attributes applied to the child when it
+// is started. Unset attributes are inherited from the controller.
+type schedAttrs struct {
+	nice     *int
+	ioprio   *IOPriority
+	affinity []int
+	policy   *SchedPolicy
+}
+
+// isSet reports whether any attribute is set.
+func (a *schedAttrs) isSet() bool {
+	return a.nice != nil || a.ioprio != nil || a.affinity != nil || a.policy != nil
+}
+
+// maxAffinityCPU is the highest CPU number that can be used in an
+// affinity mask.
+const maxAffinityCPU = 1023
+
+// SetNice sets the nice value of the process, from -20 (highest priority)
+// to 19 (lowest). A value set before the process is started is applied to
+// the child before it runs its first instruction, in the same way as
+// SetRlimit; a value set while the process is running is applied to all
+// of its threads immediately. Lowering the nice value requires
+// CAP_SYS_NICE or a sufficient RLIMIT_NICE.
+//
+// Only Linux is supported; other platforms return ErrNotSupported.
+//
+// Returns an error if the value is out of range or cannot be applied to
+// the running process.
+func (p *Process) SetNice(nice int) error {
+	if nice < -20 || nice > 19 {
+		return fmt.Errorf("invalid nice value: %d", nice)
+	}
+	return p.setSched(func(pid int) error {
+		if err := setNice(pid, nice); err != nil {
+			return fmt.Errorf("failed to set nice value: %w", err)
+		}
+		return nil
+	}, func(a *schedAttrs) { a.nice = &nice })
+}
+
+// Nice returns the nice value of the process. While the process is
+// running it is read from the kernel; before it is started the value set
+// with SetNice is returned.
+//
+// Only Linux is supported; other platforms return ErrNotSupported.
+//
+// Returns an error if the process is not running and no value has been
+// set, or the value cannot be read.
+func (p *Process) Nice() (int, error) {
+	if !schedSupported {
+		return 0, ErrNotSupported
+	}
+
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	if p.running {
+		return getNice(p.programPID)
+	}
+	if p.sched.nice == nil {
+		return 0, fmt.Errorf("nice value not set")
+	}
+	return *p.sched.nice, nil
+}
+
+// SetIOPriority sets the I/O scheduling class and level of the process.
+// It is applied like SetNice.
+//
+// Only Linux is supported; other platforms return ErrNotSupported.
+//
+// Returns an error if the priority is invalid or cannot be applied to the
+// running process.
+func (p *Process) SetIOPriority(prio IOPriority) error {
+	if prio.Class < IOPrioClassNone || prio.Class > IOPrioClassIdle {
+		return fmt.Errorf("invalid I/O priority class: %v", prio.Class)
+	}
+	if prio.Level < 0 || prio.Level > 7 || (prio.Class == IOPrioClassNone && prio.Level != 0) {
+		return fmt.Errorf("invalid I/O priority level %d for class %v", prio.Level, prio.Class)
+	}
+	return p.setSched(func(pid int) error {
+		if err := setIOPriority(pid, prio); err != nil {
+			return fmt.Errorf("failed to set I/O priority: %w", err)
+		}
+		return nil
+	}, func(a *schedAttrs) { a.ioprio = &prio })
+}
+
+// IOPriority returns the I/O scheduling class and level of the process,
+// read like Nice.
+//
+// Only Linux is supported; other platforms return ErrNotSupported.
+//
+// Returns an error if the process is not running and no priority has been
+// set, or the priority cannot be read.
+func (p *Process) IOPriority() (IOPriority, error) {
+	if !schedSupported {
+		return IOPriority{}, ErrNotSupported
+	}
+
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	if p.running {
+		return getIOPriority(p.programPID)
+	}
+	if p.sched.ioprio == nil {
+		return IOPriority{}, fmt.Errorf("I/O priority not set")
+	}
+	return *p.sched.ioprio, nil
+}
+
+// SetCPUAffinity restricts the process to the given CPUs, numbered from 0,
+// as with sched_setaffinity(2). It is applied like SetNice.
+//
+// Only Linux is supported; other platforms return ErrNotSupported.
+//
+// Returns an error if no CPU or an invalid CPU number is given, or the
+// mask cannot be applied to the running process.
+func (p *Process) SetCPUAffinity(cpus ...int) error {
+	if len(cpus) == 0 {
+		return fmt.Errorf("empty CPU affinity mask")
+	}
+	for _, cpu := range cpus {
+		if cpu < 0 || cpu > maxAffinityCPU {
+			return fmt.Errorf("invalid CPU number: %d", cpu)
+		}
+	}
+	cpus = append([]int(nil), cpus...)
+	return p.setSched(func(pid int) error {
+		if err := setCPUAffinity(pid, cpus); err != nil {
+			return fmt.Errorf("failed to set CPU affinity: %w", err)
+		}
+		return nil
+	}, func(a *schedAttrs) { a.affinity = cpus })
+}
+
+// CPUAffinity returns the CPUs the process may run on, in ascending
+// order, read like Nice.
+//
+// Only Linux is supported; other platforms return ErrNotSupported.
+//
+// Returns an error if the process is not running and no mask has been
+// set, or the mask cannot be read.
+func (p *Process) CPUAffinity() ([]int, error) {
+	if !schedSupported {
+		return nil, ErrNotSupported
+	}
+
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	if p.running {
+		return getCPUAffinity(p.programPID)
+	}
+	if p.sched.affinity == nil {
+		return nil, fmt.Errorf("CPU affinity not set")
+	}
+	return append([]int(nil), p.sched.affinity...), nil
+}
+
+// SetSchedPolicy sets the scheduling policy of the process. It is applied
+// like SetNice. The nice value is kept, although SchedIdle ignores it.
+// Leaving SchedIdle requires CAP_SYS_NICE or a sufficient RLIMIT_NICE.
+//
+// Only Linux is supported; other platforms return ErrNotSupported.
+//
+// Returns an error if the policy is invalid or cannot be applied to the
+// running process.
+func (p *Process) SetSchedPolicy(policy SchedPolicy) error {
+	if policy < SchedNormal || policy > SchedIdle {
+		return fmt.Errorf("invalid scheduling policy: %v", policy)
+	}
+	return p.setSched(func(pid int) error {
+		if err := setSchedPolicy(pid, policy); err != nil {
+			return fmt.Errorf("failed to set scheduling policy: %w", err)
+		}
+		return nil
+	}, func(a *schedAttrs) { a.policy = &policy })
+}
+
+// SchedPolicy returns the scheduling policy of the process, read like
+// Nice. Real-time policies set outside processctrl are reported as an
+// error.
+//
+// Only Linux is supported; other platforms return ErrNotSupported.
+//
+// Returns an error if the process is not running and no policy has been
+// set, or the policy cannot be read.
+func (p *Process) SchedPolicy() (SchedPolicy, error) {
+	if !schedSupported {
+		return SchedNormal, ErrNotSupported
+	}
+
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	if p.running {
+		return getSchedPolicy(p.programPID)
+	}
+	if p.sched.policy == nil {
+		return SchedNormal, fmt.Errorf("scheduling policy not set")
+	}
+	return *p.sched.policy, nil
+}
+
+// setSched applies a scheduling attribute to the running process, if
+// any, and stores it for the next start.
+func (p *Process) setSched(apply func(pid int) error, store func(*schedAttrs)) error {
+	if !schedSupported {
+		return ErrNotSupported
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.running {
+		if err := apply(p.programPID); err != nil {
+			return err
+		}
+	}
+	store(&p.sched)
+	return nil
+}
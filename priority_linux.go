@@ -0,0 +1,182 @@
+//go:build linux
+
+// Package processctrl Linux scheduling attributes
+//
+// This file sets the nice value, I/O priority, CPU affinity and scheduling
+// policy of child processes. On Linux all of these are attributes of
+// threads rather than processes, so they are applied to every thread of a
+// running process. When the child is started they are applied while it is
+// stopped after exec, together with its resource limits, so the single
+// thread it has at that point passes them on to all later threads.
+
+package processctrl
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// schedSupported reports whether the scheduling attributes can be set.
+const schedSupported = true
+
+// ioprio_set(2) constants
+const (
+	ioprioWhoProcess = 1
+	ioprioClassShift = 13
+)
+
+// schedPolicies maps scheduling policies to the kernel's policy numbers.
+var schedPolicies = [...]int{
+	SchedNormal: unix.SCHED_NORMAL,
+	SchedBatch:  unix.SCHED_BATCH,
+	SchedIdle:   unix.SCHED_IDLE,
+}
+
+// forEachThread calls fn with the ID of every thread of the process.
+// Threads that exit concurrently are skipped.
+func forEachThread(pid int, fn func(tid int) error) error {
+	entries, err := os.ReadDir(fmt.Sprintf("/proc/%d/task", pid))
+	if err != nil {
+		return fn(pid)
+	}
+	for _, entry := range entries {
+		tid, err := strconv.Atoi(entry.Name())
+		if err != nil {
+			continue
+		}
+		if err := fn(tid); err != nil && (tid == pid || !errors.Is(err, unix.ESRCH)) {
+			return err
+		}
+	}
+	return nil
+}
+
+// setNice sets the nice value of all threads of a process.
+func setNice(pid, nice int) error {
+	return forEachThread(pid, func(tid int) error {
+		return unix.Setpriority(unix.PRIO_PROCESS, tid, nice)
+	})
+}
+
+// getNice returns the nice value of the main thread of a process.
+func getNice(pid int) (int, error) {
+	// The system call returns 20 - nice to avoid negative values
+	prio, err := unix.Getpriority(unix.PRIO_PROCESS, pid)
+	if err != nil {
+		return 0, err
+	}
+	return 20 - prio, nil
+}
+
+// setIOPriority sets the I/O priority of all threads of a process.
+func setIOPriority(pid int, prio IOPriority) error {
+	value := int(prio.Class)<<ioprioClassShift | prio.Level
+	return forEachThread(pid, func(tid int) error {
+		_, _, errno := unix.Syscall(unix.SYS_IOPRIO_SET, ioprioWhoProcess, uintptr(tid), uintptr(value))
+		if errno != 0 {
+			return errno
+		}
+		return nil
+	})
+}
+
+// getIOPriority returns the I/O priority of the main thread of a process.
+func getIOPriority(pid int) (IOPriority, error) {
+	value, _, errno := unix.Syscall(unix.SYS_IOPRIO_GET, ioprioWhoProcess, uintptr(pid), 0)
+	if errno != 0 {
+		return IOPriority{}, errno
+	}
+	return IOPriority{
+		Class: IOPrioClass(value >> ioprioClassShift),
+		Level: int(value & (1<<ioprioClassShift - 1)),
+	}, nil
+}
+
+// setCPUAffinity sets the CPU affinity of all threads of a process.
+func setCPUAffinity(pid int, cpus []int) error {
+	var set unix.CPUSet
+	for _, cpu := range cpus {
+		set.Set(cpu)
+	}
+	return forEachThread(pid, func(tid int) error {
+		return unix.SchedSetaffinity(tid, &set)
+	})
+}
+
+// getCPUAffinity returns the CPU affinity of the main thread of a
+// process.
+func getCPUAffinity(pid int) ([]int, error) {
+	var set unix.CPUSet
+	if err := unix.SchedGetaffinity(pid, &set); err != nil {
+		return nil, err
+	}
+	var cpus []int
+	for cpu := 0; cpu <= maxAffinityCPU; cpu++ {
+		if set.IsSet(cpu) {
+			cpus = append(cpus, cpu)
+		}
+	}
+	return cpus, nil
+}
+
+// setSchedPolicy sets the scheduling policy of all threads of a process.
+// sched_setscheduler is used rather than sched_setattr, since it keeps
+// the nice value.
+func setSchedPolicy(pid int, policy SchedPolicy) error {
+	var param struct{ priority int32 } // Must be 0 for these policies
+	return forEachThread(pid, func(tid int) error {
+		_, _, errno := unix.Syscall(unix.SYS_SCHED_SETSCHEDULER,
+			uintptr(tid), uintptr(schedPolicies[policy]), uintptr(unsafe.Pointer(&param)))
+		if errno != 0 {
+			return errno
+		}
+		return nil
+	})
+}
+
+// getSchedPolicy returns the scheduling policy of the main thread of a
+// process.
+func getSchedPolicy(pid int) (SchedPolicy, error) {
+	value, _, errno := unix.Syscall(unix.SYS_SCHED_GETSCHEDULER, uintptr(pid), 0, 0)
+	if errno != 0 {
+		return SchedNormal, errno
+	}
+	for policy, v := range schedPolicies {
+		// Ignore SCHED_RESET_ON_FORK
+		if int(value)&^unix.SCHED_RESET_ON_FORK == v {
+			return SchedPolicy(policy), nil
+		}
+	}
+	return SchedNormal, fmt.Errorf("unsupported scheduling policy %d", value)
+}
+
+// applySched applies the scheduling attributes to the child while it is
+// stopped after exec.
+func applySched(pid int, a *schedAttrs) error {
+	if a.policy != nil {
+		if err := setSchedPolicy(pid, *a.policy); err != nil {
+			return fmt.Errorf("failed to set scheduling policy: %w", err)
+		}
+	}
+	if a.nice != nil {
+		if err := setNice(pid, *a.nice); err != nil {
+			return fmt.Errorf("failed to set nice value: %w", err)
+		}
+	}
+	if a.ioprio != nil {
+		if err := setIOPriority(pid, *a.ioprio); err != nil {
+			return fmt.Errorf("failed to set I/O priority: %w", err)
+		}
+	}
+	if a.affinity != nil {
+		if err := setCPUAffinity(pid, a.affinity); err != nil {
+			return fmt.Errorf("failed to set CPU affinity: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,119 @@
+//go:build linux
+
+package processctrl
+
+import (
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"golang.org/x/sys/unix"
+)
+
+func TestSchedAttrsAppliedAtStart(t *testing.T) {
+	ownNice, err := getNice(os.Getpid())
+	if err != nil {
+		t.Fatalf("getNice() failed: %v", err)
+	}
+
+	// Field 19 of /proc/<pid>/stat is the nice value
+	proc := New("sh", "-c", `cut -d" " -f19 /proc/$$/stat`)
+	if err := proc.SetNice(10); err != nil {
+		t.Fatalf("SetNice() failed: %v", err)
+	}
+	if err := proc.SetSchedPolicy(SchedBatch); err != nil {
+		t.Fatalf("SetSchedPolicy() failed: %v", err)
+	}
+	if nice, err := proc.Nice(); err != nil || nice != 10 {
+		t.Errorf("Nice() before start = %d, %v, want 10", nice, err)
+	}
+
+	lines, result := runCollect(t, proc)
+	if result.ExitCode() != 0 {
+		t.Fatalf("ExitCode() = %d, want 0", result.ExitCode())
+	}
+	if len(lines) != 1 || strings.TrimSpace(lines[0]) != "10" {
+		t.Errorf("Child saw nice value %q, want 10", lines)
+	}
+
+	if nice, err := getNice(os.Getpid()); err != nil || nice != ownNice {
+		t.Errorf("Controller nice value changed to %d, %v, want %d", nice, err, ownNice)
+	}
+}
+
+func TestSchedAttrsAtRuntime(t *testing.T) {
+	proc := New("sleep", "10")
+	if err := proc.SetIOPriority(IOPriority{Class: IOPrioClassIdle}); err != nil {
+		t.Fatalf("SetIOPriority() failed: %v", err)
+	}
+	if err := proc.SetCPUAffinity(0); err != nil {
+		t.Fatalf("SetCPUAffinity() failed: %v", err)
+	}
+	if _, _, err := proc.Run(); err != nil {
+		t.Fatalf("Run() failed: %v", err)
+	}
+	defer func() { _ = proc.Kill() }()
+
+	if prio, err := proc.IOPriority(); err != nil || prio != (IOPriority{Class: IOPrioClassIdle}) {
+		t.Errorf("IOPriority() = %+v, %v, want idle", prio, err)
+	}
+	if cpus, err := proc.CPUAffinity(); err != nil || !reflect.DeepEqual(cpus, []int{0}) {
+		t.Errorf("CPUAffinity() = %v, %v, want [0]", cpus, err)
+	}
+
+	if err := proc.SetNice(15); err != nil {
+		t.Fatalf("SetNice() failed: %v", err)
+	}
+	if nice, err := proc.Nice(); err != nil || nice != 15 {
+		t.Errorf("Nice() = %d, %v, want 15", nice, err)
+	}
+
+	if err := proc.SetIOPriority(IOPriority{Class: IOPrioClassBestEffort, Level: 7}); err != nil {
+		t.Fatalf("SetIOPriority() failed: %v", err)
+	}
+	if prio, err := proc.IOPriority(); err != nil || prio != (IOPriority{Class: IOPrioClassBestEffort, Level: 7}) {
+		t.Errorf("IOPriority() = %+v, %v, want best-effort 7", prio, err)
+	}
+
+	if err := proc.SetSchedPolicy(SchedIdle); err != nil {
+		t.Fatalf("SetSchedPolicy() failed: %v", err)
+	}
+	if policy, err := proc.SchedPolicy(); err != nil || policy != SchedIdle {
+		t.Errorf("SchedPolicy() = %v, %v, want idle", policy, err)
+	}
+	// The nice value is kept across policy changes
+	if nice, err := proc.Nice(); err != nil || nice != 15 {
+		t.Errorf("Nice() after SetSchedPolicy() = %d, %v, want 15", nice, err)
+	}
+
+	var set unix.CPUSet
+	if err := unix.SchedGetaffinity(proc.PID(), &set); err != nil || set.Count() != 1 {
+		t.Errorf("Kernel affinity mask has %d CPUs, %v, want 1", set.Count(), err)
+	}
+}
+
+func TestSchedAttrsInvalid(t *testing.T) {
+	proc := New("true")
+	if err := proc.SetNice(20); err == nil {
+		t.Error("SetNice(20) succeeded, want error")
+	}
+	if err := proc.SetIOPriority(IOPriority{Class: IOPrioClassBestEffort, Level: 8}); err == nil {
+		t.Error("SetIOPriority() with level 8 succeeded, want error")
+	}
+	if err := proc.SetIOPriority(IOPriority{Class: IOPrioClassNone, Level: 3}); err == nil {
+		t.Error("SetIOPriority() with class none and level 3 succeeded, want error")
+	}
+	if err := proc.SetCPUAffinity(); err == nil {
+		t.Error("SetCPUAffinity() without CPUs succeeded, want error")
+	}
+	if err := proc.SetCPUAffinity(-1); err == nil {
+		t.Error("SetCPUAffinity(-1) succeeded, want error")
+	}
+	if err := proc.SetSchedPolicy(SchedPolicy(7)); err == nil {
+		t.Error("SetSchedPolicy(7) succeeded, want error")
+	}
+	if _, err := proc.Nice(); err == nil {
+		t.Error("Nice() succeeded without a value set")
+	}
+}
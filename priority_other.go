@@ -0,0 +1,51 @@
+//go:build !linux
+
+// Package processctrl scheduling attributes stub
+//
+// Nice values, I/O priorities, CPU affinity and scheduling policies of
+// child processes are only supported on Linux.
+
+package processctrl
+
+// schedSupported reports whether the scheduling attributes can be set.
+const schedSupported = false
+
+// setNice is not supported on this platform.
+func setNice(int, int) error {
+	return ErrNotSupported
+}
+
+// getNice is not supported on this platform.
+func getNice(int) (int, error) {
+	return 0, ErrNotSupported
+}
+
+// setIOPriority is not supported on this platform.
+func setIOPriority(int, IOPriority) error {
+	return ErrNotSupported
+}
+
+// getIOPriority is not supported on this platform.
+func getIOPriority(int) (IOPriority, error) {
+	return IOPriority{}, ErrNotSupported
+}
+
+// setCPUAffinity is not supported on this platform.
+func setCPUAffinity(int, []int) error {
+	return ErrNotSupported
+}
+
+// getCPUAffinity is not supported on this platform.
+func getCPUAffinity(int) ([]int, error) {
+	return nil, ErrNotSupported
+}
+
+// setSchedPolicy is not supported on this platform.
+func setSchedPolicy(int, SchedPolicy) error {
+	return ErrNotSupported
+}
+
+// getSchedPolicy is not supported on this platform.
+func getSchedPolicy(int) (SchedPolicy, error) {
+	return SchedNormal, ErrNotSupported
+}
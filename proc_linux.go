@@ -0,0 +1,186 @@
+//go:build linux
+
+// Package processctrl Linux /proc support
+//
+// This file contains helpers for reading process information from the
+// /proc filesystem.
+
+package processctrl
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// clockTicks is the value of USER_HZ, the unit of the time fields in
+// /proc/<pid>/stat. It is 100 on every architecture supported by Go.
+const clockTicks = 100
+
+// procStat holds the fields of /proc/<pid>/stat used by processctrl.
+type procStat struct {
+	comm      string
+	state     byte
+	ppid      int
+	utime     uint64 // in clock ticks
+	stime     uint64 // in clock ticks
+	cutime    uint64 // of waited-for children, in clock ticks
+	cstime    uint64 // of waited-for children, in clock ticks
+	threads   int
+	startTime uint64 // in clock ticks since boot
+	vsize     uint64 // in bytes
+	rss       int64  // in pages
+}
+
+// readProcStat parses /proc/<pid>/stat.
+func readProcStat(pid int) (*procStat, error) {
+	data, err := os.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+	if err != nil {
+		return nil, err
+	}
+	return parseProcStat(data)
+}
+
+// parseProcStat parses the contents of a /proc/<pid>/stat file.
+// The command name is enclosed in parentheses and may itself contain
+// spaces and parentheses, so the remaining fields are located after the
+// last closing parenthesis.
+func parseProcStat(data []byte) (*procStat, error) {
+	start := bytes.IndexByte(data, '(')
+	end := bytes.LastIndexByte(data, ')')
+	if start < 0 || end < start {
+		return nil, fmt.Errorf("malformed stat data")
+	}
+
+	st := &procStat{comm: string(data[start+1 : end])}
+
+	// Fields are numbered as in proc(5); fields[0] is field 3 (state)
+	fields := strings.Fields(string(data[end+1:]))
+	if len(fields) < 22 {
+		return nil, fmt.Errorf("malformed stat data: %d fields", len(fields))
+	}
+	st.state = fields[0][0]
+
+	var err error
+	parse := func(i int) uint64 {
+		if err != nil {
+			return 0
+		}
+		var v uint64
+		v, err = strconv.ParseUint(fields[i], 10, 64)
+		return v
+	}
+	st.ppid = int(parse(1))
+	st.utime = parse(11)
+	st.stime = parse(12)
+	st.cutime = parse(13)
+	st.cstime = parse(14)
+	st.threads = int(parse(17))
+	st.startTime = parse(19)
+	st.vsize = parse(20)
+	st.rss = int64(parse(21))
+	if err != nil {
+		return nil, fmt.Errorf("malformed stat data: %w", err)
+	}
+	return st, nil
+}
+
+var (
+	bootTimeOnce sync.Once
+	bootTime     time.Time
+	bootTimeErr  error
+)
+
+// readBootTime returns the system boot time from /proc/stat.
+func readBootTime() (time.Time, error) {
+	bootTimeOnce.Do(func() {
+		f, err := os.Open("/proc/stat")
+		if err != nil {
+			bootTimeErr = err
+			return
+		}
+		defer func() { _ = f.Close() }() // Ignore error on cleanup
+
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			line := scanner.Text()
+			if !strings.HasPrefix(line, "btime ") {
+				continue
+			}
+			secs, err := strconv.ParseInt(strings.TrimSpace(line[len("btime "):]), 10, 64)
+			if err != nil {
+				bootTimeErr = fmt.Errorf("malformed btime: %w", err)
+				return
+			}
+			bootTime = time.Unix(secs, 0)
+			return
+		}
+		bootTimeErr = fmt.Errorf("btime not found in /proc/stat")
+	})
+	return bootTime, bootTimeErr
+}
+
+// readProcStatus returns the "Key: value" pairs of /proc/<pid>/status.
+func readProcStatus(pid int) (map[string]string, error) {
+	data, err := os.ReadFile(fmt.Sprintf("/proc/%d/status", pid))
+	if err != nil {
+		return nil, err
+	}
+
+	status := make(map[string]string)
+	for _, line := range strings.Split(string(data), "\n") {
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		status[key] = strings.TrimSpace(value)
+	}
+	return status, nil
+}
+
+// readProcInfo collects process metadata from /proc/<pid>.
+func readProcInfo(pid int) (*ProcessInfo, error) {
+	st, err := readProcStat(pid)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read process stat: %w", err)
+	}
+
+	info := &ProcessInfo{
+		PID:   pid,
+		PPID:  st.ppid,
+		Name:  st.comm,
+		State: string(st.state),
+	}
+
+	if bt, err := readBootTime(); err == nil {
+		info.StartTime = bt.Add(time.Duration(st.startTime) * time.Second / clockTicks)
+	}
+
+	if data, err := os.ReadFile(fmt.Sprintf("/proc/%d/cmdline", pid)); err == nil {
+		data = bytes.TrimRight(data, "\x00")
+		if len(data) > 0 {
+			info.Cmdline = strings.Split(string(data), "\x00")
+		}
+	}
+
+	// The exe and cwd links are not readable for processes owned by
+	// other users, so errors are ignored and the fields left empty.
+	info.Executable, _ = os.Readlink(fmt.Sprintf("/proc/%d/exe", pid))
+	info.Dir, _ = os.Readlink(fmt.Sprintf("/proc/%d/cwd", pid))
+
+	if status, err := readProcStatus(pid); err == nil {
+		if uids := strings.Fields(status["Uid"]); len(uids) > 0 {
+			info.UID, _ = strconv.Atoi(uids[0])
+		}
+		if gids := strings.Fields(status["Gid"]); len(gids) > 0 {
+			info.GID, _ = strconv.Atoi(gids[0])
+		}
+	}
+
+	return info, nil
+}
@@ -0,0 +1,90 @@
+//go:build linux
+
+package processctrl
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseProcStat(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		comm    string
+		state   byte
+		ppid    int
+		wantErr bool
+	}{
+		{
+			name:  "simple",
+			data:  "1234 (sleep) S 1 1234 1234 0 -1 4194304 100 0 0 0 7 3 0 0 20 0 1 0 5000 8192000 200 18446744073709551615",
+			comm:  "sleep",
+			state: 'S',
+			ppid:  1,
+		},
+		{
+			name:  "comm with spaces and parentheses",
+			data:  "42 (a (b) c) T 7 42 42 0 -1 4194304 100 0 0 0 7 3 0 0 20 0 3 0 5000 8192000 200 18446744073709551615",
+			comm:  "a (b) c",
+			state: 'T',
+			ppid:  7,
+		},
+		{
+			name:    "truncated",
+			data:    "42 (x) S 1 2 3",
+			wantErr: true,
+		},
+		{
+			name:    "missing comm",
+			data:    "42 x S 1",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			st, err := parseProcStat([]byte(tt.data))
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("Expected error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseProcStat() failed: %v", err)
+			}
+			if st.comm != tt.comm || st.state != tt.state || st.ppid != tt.ppid {
+				t.Errorf("Got comm=%q state=%c ppid=%d", st.comm, st.state, st.ppid)
+			}
+		})
+	}
+}
+
+func TestAttachInfo(t *testing.T) {
+	cmd := startExternal(t, "sleep", "10")
+
+	proc, err := Attach(cmd.Process.Pid)
+	if err != nil {
+		t.Fatalf("Attach() failed: %v", err)
+	}
+	defer func() { _ = proc.Kill() }()
+
+	info, err := proc.Info()
+	if err != nil {
+		t.Fatalf("Info() failed: %v", err)
+	}
+
+	if info.PID != cmd.Process.Pid {
+		t.Errorf("Expected PID %d, got %d", cmd.Process.Pid, info.PID)
+	}
+	if info.Name != "sleep" {
+		t.Errorf("Expected name 'sleep', got %q", info.Name)
+	}
+	if len(info.Cmdline) != 2 || info.Cmdline[1] != "10" {
+		t.Errorf("Unexpected command line: %q", info.Cmdline)
+	}
+	if time.Since(info.StartTime) > time.Minute || time.Until(info.StartTime) > time.Minute {
+		t.Errorf("Unexpected start time: %v", info.StartTime)
+	}
+}
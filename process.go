@@ -34,11 +34,13 @@ package processctrl
 import (
 	"bufio"
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"os"
 	"os/exec"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -47,23 +49,95 @@ const (
 	defaultKillTimeout = 5 * time.Second
 	// streamGoroutines is the number of goroutines used for streaming output
 	streamGoroutines = 2
+	// streamDrainTimeout limits how long the exit result waits for output
+	// after the process has exited, for descendants that keep it open
+	streamDrainTimeout = time.Second
 )
 
+// ErrNotSupported is returned by operations that are not available on the
+// current platform.
+var ErrNotSupported = errors.New("operation not supported on this platform")
+
 // Process represents a managed external process with controllable execution.
 // It provides channels for reading stdout/stderr output and supports
 // pause/resume functionality across different platforms.
 type Process struct {
-	program        string
-	args           []string
-	cmd            *exec.Cmd
-	stdout         chan string
-	stderr         chan string
-	stdin          io.WriteCloser
-	mu             sync.RWMutex
-	paused         bool
-	running        bool
-	bufferSize     int
-	channelsClosed bool
+	program         string
+	args            []string
+	cmd             *exec.Cmd
+	stdout          chan string
+	stderr          chan string
+	stdin           io.WriteCloser
+	mu              sync.RWMutex
+	paused          bool
+	running         bool
+	bufferSize      int
+	channelsClosed  bool
+	pidfd           int           // Linux pidfd of the child, -1 if unavailable
+	handleDone      chan struct{} // closed when the pidfd exit watcher returns
+	exited          chan struct{} // closed once the process has exited
+	exitOnce        sync.Once
+	reaped          chan struct{} // closed once the process has been reaped
+	waitErr         error
+	exitSignal      os.Signal // signal that terminated the program, nil if it exited
+	programPID      int       // PID of the program, differs from the child's in a sandbox
+	startTicks      uint64    // Linux start time of the program, used to detect PID reuse
+	events          *eventEmitter
+	coop            *CooperativePause
+	lines           lineMatcher
+	resumeTimer     *time.Timer // pending automatic resume, nil if none
+	resumeAt        time.Time
+	resumeSeq       uint64 // identifies the current resume schedule
+	cpuLimit        float64
+	throttling      bool // the throttle loop is running
+	throttleStopped bool // the process is stopped by the throttle
+	transitioning   bool // Pause or Resume is waiting with p.mu released
+	statsMu         sync.Mutex
+	statsCPU        cpuSample // previous sample of Stats
+	treeCPU         cpuSample // previous sample of TreeStats
+	watchdog        *Watchdog
+	watchdogSeq     uint64 // identifies the current watchdog loop
+	firings         []RuleFiring
+	exitReason      ExitReason
+	exitRule        string
+	result          *ExitResult
+	startedAt       time.Time
+	pausedAt        time.Time     // start of the current pause
+	pausedTotal     time.Duration // time spent in completed pauses
+	outputLines     atomic.Uint64 // lines read from stdout and stderr
+	timeouts        Timeouts
+	timeoutSeq      uint64 // identifies the current timeout loop
+	rlimits         map[RlimitResource]Rlimit
+	sched           schedAttrs
+	cgroupCfg       *CgroupConfig
+	cgroup          *cgroupHandle // cgroup of the running process, nil if none
+	cgroupErr       error
+	oomKills        uint64 // OOM kills in the cgroup, read when the process exits
+	oomScoreAdj     *int
+	oomKillsBefore  uint64 // system-wide OOM kills before the start
+	oomKillsKnown   bool   // oomKillsBefore could be read
+	killSent        bool   // processctrl sent SIGKILL to the process
+	oomKilled       bool   // the process was killed by the OOM killer, see detectOOMKill
+	creds           *credentials
+	caps            *Capabilities
+	sandbox         *Sandbox
+	seccomp         *seccompRules
+	seccompKilled   bool        // the process was killed for violating its seccomp profile, see detectSeccompKill
+	execKilled      atomic.Bool // the program was killed for an exec its seccomp profile denies, see superviseExec
+	execListener    *os.File    // seccomp listener supervised for the exec of the program, nil if none
+	landlock        *LandlockConfig
+	landlockRules   *landlockRules // Landlock rules of the current run, nil if none
+	landlockErr     error
+	helper          *helperStart // helper being started, nil otherwise
+	sandboxInit     *helperStart // init process of the sandbox reporting the program's exit, nil if none
+	image           *os.File     // program executed from memory, nil if none
+	imageReleased   bool         // the image was closed after the process exited
+	script          *script      // source of a script run by an interpreter, nil if none
+	outputWriters   []*os.File   // write ends of the output pipes until the child has started
+	stdinFile       *os.File     // standard input connected by a Pipeline, nil if none
+	stdoutFile      *os.File     // standard output connected by a Pipeline, nil if none
+	dir             string       // working directory, the controller's if empty
+	searchPath      []string     // directories searched for the program, nil for PATH
 }
 
 // New creates a new Process instance with unbuffered output channels.
@@ -96,6 +170,10 @@ func NewWithBuffer(bufferSize int, program string, args ...string) *Process {
 		stderr:         make(chan string, bufferSize),
 		bufferSize:     bufferSize,
 		channelsClosed: false,
+		pidfd:          -1,
+		exited:         make(chan struct{}),
+		reaped:         make(chan struct{}),
+		events:         newEventEmitter(),
 		// cmd will be created in RunWithContext with proper context
 	}
 }
@@ -138,70 +216,184 @@ func (p *Process) RunWithContext(ctx context.Context) (<-chan string, <-chan str
 
 	// Create command with context for proper cancellation
 	p.cmd = exec.CommandContext(ctx, p.program, p.args...)
+	p.prepareProgram()
+	if err := p.prepareImage(); err != nil {
+		return nil, nil, err
+	}
+	p.prepareScript()
+	p.prepareHandle()
+	p.applyCredentials()
+	p.prepareCapabilities()
+	if err := p.prepareLandlock(); err != nil {
+		return nil, nil, err
+	}
+	if err := p.prepareSandbox(); err != nil {
+		return nil, nil, err
+	}
+	if err := p.prepareExecHelper(); err != nil {
+		return nil, nil, err
+	}
+	unlockThread := p.prepareRlimits()
+	defer unlockThread()
 
-	stdoutPipe, err := p.cmd.StdoutPipe()
+	stdoutPipe, err := p.stdoutPipe()
 	if err != nil {
+		p.releaseHelper()
 		return nil, nil, fmt.Errorf("failed to create stdout pipe: %w", err)
 	}
 
-	stderrPipe, err := p.cmd.StderrPipe()
+	stderrPipe, err := p.outputPipe(&p.cmd.Stderr)
 	if err != nil {
 		_ = stdoutPipe.Close() // Ignore error during cleanup
+		p.closeOutputWriters()
+		p.releaseHelper()
 		return nil, nil, fmt.Errorf("failed to create stderr pipe: %w", err)
 	}
 
-	stdinPipe, err := p.cmd.StdinPipe()
+	stdinPipe, err := p.stdinPipe()
 	if err != nil {
 		_ = stdoutPipe.Close() // Ignore error during cleanup
 		_ = stderrPipe.Close() // Ignore error during cleanup
+		p.closeOutputWriters()
+		p.releaseHelper()
 		return nil, nil, fmt.Errorf("failed to create stdin pipe: %w", err)
 	}
 	p.stdin = stdinPipe
 
-	if err := p.cmd.Start(); err != nil {
+	if err := p.prepareCgroup(); err != nil {
+		_ = stdoutPipe.Close() // Ignore error during cleanup
+		_ = stderrPipe.Close() // Ignore error during cleanup
+		_ = p.stdin.Close()    // Ignore error during cleanup
+		p.closeOutputWriters()
+		p.releaseHelper()
+		return nil, nil, err
+	}
+
+	p.oomKillsBefore, p.oomKillsKnown = readOOMKillCount()
+	// ptrace requests for the resource limits must come from the thread
+	// that started the child
+	var applyErr error
+	err = p.startWithCapabilities(func() error {
+		if err := p.cmd.Start(); err != nil {
+			return err
+		}
+		applyErr = p.applyRlimits()
+		return nil
+	})
+	p.closeOutputWriters()
+	if err != nil {
+		_ = stdoutPipe.Close() // Ignore error during cleanup
+		_ = stderrPipe.Close() // Ignore error during cleanup
+		_ = p.stdin.Close()    // Ignore error during cleanup
+		p.releaseHelper()
+		p.releaseCgroup()
+		return nil, nil, p.startError(err)
+	}
+	if applyErr != nil {
+		_ = stdoutPipe.Close() // Ignore error during cleanup
+		_ = stderrPipe.Close() // Ignore error during cleanup
+		_ = p.stdin.Close()    // Ignore error during cleanup
+		p.releaseHelper()
+		p.releaseCgroup()
+		return nil, nil, fmt.Errorf("failed to apply resource limits and scheduling attributes: %w", applyErr)
+	}
+	p.placeCgroup()
+	p.programPID = p.cmd.Process.Pid
+	// A helper starts the program once it is in the cgroup
+	if err := p.startHelper(); err != nil {
 		_ = stdoutPipe.Close() // Ignore error during cleanup
 		_ = stderrPipe.Close() // Ignore error during cleanup
 		_ = p.stdin.Close()    // Ignore error during cleanup
-		return nil, nil, fmt.Errorf("failed to start process: %w", err)
+		p.releaseCgroup()
+		return nil, nil, p.startError(err)
 	}
 
+	p.openHandle()
 	p.running = true
+	p.startedAt = time.Now()
+	p.watchState()
+	p.startThrottle()
+	p.startWatchdog()
+	p.startTimeouts()
 
 	var wg sync.WaitGroup
 	wg.Add(streamGoroutines)
-
-	go streamOutput(stdoutPipe, p.stdout, &wg)
-	go streamOutput(stderrPipe, p.stderr, &wg)
+	cg := p.cgroup
+	sc := p.script
+
+	go streamOutput(stdoutPipe, p.stdout, &wg, func(line string) {
+		p.outputLines.Add(1)
+		p.lines.match(line)
+	})
+	go streamOutput(stderrPipe, p.stderr, &wg, func(line string) {
+		p.outputLines.Add(1)
+		if sc != nil {
+			// Output may still be read after the result was recorded
+			p.mu.Lock()
+			sc.scan(line)
+			p.mu.Unlock()
+		}
+	})
 
 	go func() {
+		done := make(chan struct{})
+		go func() {
+			wg.Wait()
+			close(done)
+		}()
+
 		defer func() {
 			p.mu.Lock()
 			p.running = false
-			p.paused = false
+			p.setPaused(false)
+			p.cancelScheduledResume("process exited")
 			if p.stdin != nil {
 				_ = p.stdin.Close() // Ignore error during cleanup
 			}
+			p.closeHandle()
+			p.events.emit(EventExited, p.cmd.Process.Pid, "")
 			p.mu.Unlock()
 
-			// Close channels outside the mutex to avoid holding lock too long
+			// The channels are closed once all output has been read, which
+			// descendants that inherited the pipes may delay
+			<-done
+			_ = stdoutPipe.Close() // Ignore error during cleanup
+			_ = stderrPipe.Close() // Ignore error during cleanup
 			p.closeChannels()
 		}()
 
-		// Wait for either process completion or context cancellation
-		done := make(chan struct{})
-		go func() {
-			wg.Wait()
-			close(done)
-		}()
+		// Reap the process as soon as it exits; the context kills it when
+		// canceled. Wait leaves the output pipes open.
+		p.waitErr = p.cmd.Wait()
+		p.markExited()
 
+		// The result includes output written before the exit, but
+		// descendants that keep the pipes open do not hold it up
 		select {
 		case <-done:
-			// Process completed normally
-		case <-ctx.Done():
-			// Context was canceled
-			_ = p.cmd.Process.Kill() // Ignore error as process might already be dead
-			<-done                   // Wait for streams to finish
+		case <-time.After(streamDrainTimeout):
 		}
+		oomKills := finishCgroup(cg)
+		oomKillsAfter, _ := readOOMKillCount()
+		p.mu.Lock()
+		p.exitSignal = signalOf(p.cmd.ProcessState)
+		p.finishSandbox()
+		p.oomKills = oomKills
+		p.oomKilled = p.detectOOMKill(p.exitSignal, oomKillsAfter, ctx.Err() != nil)
+		if p.oomKilled {
+			p.setExitReason(ExitOOMKilled, "")
+		}
+		p.cgroup = nil
+		p.seccompKilled = p.detectSeccompKill(p.exitSignal)
+		if p.seccompKilled {
+			p.setExitReason(ExitSeccompKilled, "")
+		}
+		p.releaseScript()
+		p.releaseImage()
+		p.releaseExecListener()
+		p.recordResult()
+		p.mu.Unlock()
+		close(p.reaped)
 	}()
 
 	return p.stdout, p.stderr, nil
@@ -215,12 +407,52 @@ func (p *Process) RunWithContext(ctx context.Context) (<-chan string, <-chan str
 //   - r: The reader to read from (typically stdout or stderr pipe)
 //   - ch: The channel to send lines to
 //   - wg: WaitGroup to signal completion
-func streamOutput(r io.Reader, ch chan string, wg *sync.WaitGroup) {
+//   - match: Optional function called with each line before it is sent
+func streamOutput(r io.Reader, ch chan string, wg *sync.WaitGroup, match func(string)) {
 	defer wg.Done()
 	scanner := bufio.NewScanner(r)
 	for scanner.Scan() {
-		ch <- scanner.Text()
+		line := scanner.Text()
+		if match != nil {
+			match(line)
+		}
+		ch <- line
+	}
+}
+
+// outputPipe connects *w to a new pipe and returns its read end. Unlike
+// the pipes of exec.Cmd, the read end is not closed by Wait, so the
+// process can be reaped as soon as it exits while a descendant still
+// writes to the pipe. The write end is closed by closeOutputWriters.
+// It must be called with p.mu held before the command is started.
+func (p *Process) outputPipe(w *io.Writer) (*os.File, error) {
+	r, pw, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+	*w = pw
+	p.outputWriters = append(p.outputWriters, pw)
+	return r, nil
+}
+
+// closeOutputWriters closes the controller's write ends of the output
+// pipes once the child has been started, so that reading reaches the end
+// when the process and its descendants have closed theirs.
+// It must be called with p.mu held.
+func (p *Process) closeOutputWriters() {
+	for _, w := range p.outputWriters {
+		_ = w.Close() // Ignore error during cleanup
 	}
+	p.outputWriters = nil
+}
+
+// markExited records that the process has exited. It may be called by
+// both the pidfd exit watcher and the reaper; only the first call closes
+// the exited channel.
+func (p *Process) markExited() {
+	p.exitOnce.Do(func() {
+		close(p.exited)
+	})
 }
 
 // closeChannels safely closes the stdout and stderr channels.
@@ -233,6 +465,7 @@ func (p *Process) closeChannels() {
 	if !p.channelsClosed {
 		close(p.stdout)
 		close(p.stderr)
+		p.events.close()
 		p.channelsClosed = true
 	}
 }
@@ -279,6 +512,8 @@ func (p *Process) Terminate() error {
 //   - error: If the process is not running or if waiting fails
 //
 // Note: This method will block until the process exits naturally or is killed.
+// Descendants that inherited standard output or error do not delay it; the
+// stdout and stderr channels stay open until they have closed them too.
 func (p *Process) Wait() (*os.ProcessState, error) {
 	p.mu.RLock()
 	cmd := p.cmd
@@ -289,7 +524,19 @@ func (p *Process) Wait() (*os.ProcessState, error) {
 		return nil, fmt.Errorf("process is not running")
 	}
 
-	return cmd.ProcessState, cmd.Wait()
+	<-p.reaped
+	return cmd.ProcessState, p.waitErr
+}
+
+// Done returns a channel that is closed as soon as the process has exited.
+// On Linux the exit is detected through the process's pidfd, so the channel
+// may be closed before all output has been delivered on the stdout and
+// stderr channels. On other platforms, or on kernels without pidfd support,
+// it is closed once the process has been reaped.
+//
+// The channel is never closed if the process is never started.
+func (p *Process) Done() <-chan struct{} {
+	return p.exited
 }
 
 // IsRunning returns true if the process is currently running.
@@ -306,6 +553,10 @@ func (p *Process) IsRunning() bool {
 // A paused process is still running but temporarily suspended.
 // This method is thread-safe and can be called concurrently.
 //
+// On Linux the state is reconciled with the kernel, so a process stopped
+// or continued outside of processctrl (e.g. by kill -STOP or SIGTSTP) is
+// reported correctly within the state check interval.
+//
 // Returns true if the process is currently in a paused state.
 func (p *Process) IsPaused() bool {
 	p.mu.RLock()
@@ -313,6 +564,15 @@ func (p *Process) IsPaused() bool {
 	return p.paused
 }
 
+// Events returns a channel that receives state changes of the process,
+// such as pauses, resumes, stops and continues caused outside of
+// processctrl, and the process exit. The channel is buffered; events are
+// dropped when the buffer is full. It is closed together with the stdout
+// and stderr channels.
+func (p *Process) Events() <-chan Event {
+	return p.events.ch
+}
+
 // PID returns the process ID of the running process.
 // This method is thread-safe and can be called concurrently.
 //
@@ -368,12 +628,36 @@ func (p *Process) WriteString(s string) error {
 	return p.Write([]byte(s))
 }
 
+// Signal sends a signal to the process.
+// On Windows only os.Kill is supported.
+//
+// Returns an error if the process is not running or the signal cannot
+// be delivered.
+func (p *Process) Signal(sig os.Signal) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if !p.running {
+		return fmt.Errorf("process is not running")
+	}
+
+	if sig == os.Kill {
+		p.killSent = true
+	}
+	return p.signalImpl(sig)
+}
+
 // Pause suspends the process execution.
 // The process can be resumed later using Resume().
 // This method is thread-safe and uses platform-specific implementations:
 //   - Unix: SIGSTOP signal
 //   - Windows: NtSuspendProcess API
 //
+// On Linux, Pause only returns once the kernel reports the process as
+// stopped. If that does not happen within a short timeout (for example
+// because the process is in uninterruptible sleep), an error is returned
+// and the paused state is updated later when the stop takes effect.
+//
 // Returns an error if:
 //   - The process is not running
 //   - The process is already paused
@@ -382,15 +666,26 @@ func (p *Process) Pause() error {
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
+	return p.pauseLocked()
+}
+
+// pauseLocked implements Pause. It must be called with p.mu held.
+func (p *Process) pauseLocked() error {
 	if !p.running || p.paused {
 		return fmt.Errorf("process not running or already paused")
 	}
+	if p.transitioning {
+		return fmt.Errorf("pause or resume already in progress")
+	}
 
+	// Pause takes over from the CPU throttle until the process is resumed
+	p.releaseThrottle()
 	if err := p.pauseImpl(); err != nil {
 		return err
 	}
 
-	p.paused = true
+	p.setPaused(true)
+	p.events.emit(EventPaused, p.cmd.Process.Pid, "")
 	return nil
 }
 
@@ -399,6 +694,9 @@ func (p *Process) Pause() error {
 //   - Unix: SIGCONT signal
 //   - Windows: NtResumeProcess API
 //
+// Resuming a process paused with PauseFor or PauseUntil cancels the
+// scheduled resume.
+//
 // Returns an error if:
 //   - The process is not running
 //   - The process is not currently paused
@@ -407,18 +705,47 @@ func (p *Process) Resume() error {
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
+	return p.resumeLocked("")
+}
+
+// resumeLocked implements Resume. The message is attached to the
+// EventResumed event. It must be called with p.mu held.
+func (p *Process) resumeLocked(message string) error {
 	if !p.running || !p.paused {
 		return fmt.Errorf("process not running or not paused")
 	}
+	if p.transitioning {
+		return fmt.Errorf("pause or resume already in progress")
+	}
 
 	if err := p.resumeImpl(); err != nil {
 		return err
 	}
 
-	p.paused = false
+	p.setPaused(false)
+	p.cancelScheduledResume("resumed manually")
+	p.events.emit(EventResumed, p.cmd.Process.Pid, message)
 	return nil
 }
 
+// waitUnlocked runs wait with p.mu released, so that a Pause or Resume
+// waiting for the process to confirm the transition does not block other
+// operations. Further Pause and Resume calls are rejected meanwhile.
+// It must be called with p.mu held and reports os.ErrProcessDone if the
+// process was terminated or exited during the wait.
+func (p *Process) waitUnlocked(wait func() error) error {
+	p.transitioning = true
+	p.mu.Unlock()
+	err := wait()
+	p.mu.Lock()
+	p.transitioning = false
+
+	if err == nil && !p.running {
+		return os.ErrProcessDone
+	}
+	return err
+}
+
 // killWithSignal implements graceful and forceful process termination.
 // This method provides a unified interface that delegates to platform-specific
 // implementations for handling process termination.
@@ -436,10 +763,13 @@ func (p *Process) killWithSignal(timeout time.Duration, graceful bool) error {
 		return fmt.Errorf("process is not running")
 	}
 
+	p.releaseThrottle()
+	p.killSent = true
 	err := p.killWithSignalImpl(timeout, graceful)
 	if err == nil {
 		p.running = false
-		p.paused = false
+		p.setPaused(false)
+		p.cancelScheduledResume("process terminated")
 	}
 	return err
 }
@@ -2,7 +2,9 @@ package processctrl
 
 import (
 	"context"
+	"os"
 	"runtime"
+	"strconv"
 	"strings"
 	"testing"
 	"time"
@@ -541,3 +543,135 @@ func TestWaitCompleted(t *testing.T) {
 		t.Error("Process should not be running after completion")
 	}
 }
+
+// Test Done channel is closed when the process exits
+func TestDone(t *testing.T) {
+	var proc *Process
+	if runtime.GOOS == windowsOS {
+		proc = New("cmd", "/c", "echo done")
+	} else {
+		proc = New("echo", "done")
+	}
+
+	stdout, stderr, err := proc.Run()
+	if err != nil {
+		t.Fatalf("Run() failed: %v", err)
+	}
+
+	go func() {
+		for range stderr {
+		}
+	}()
+	go func() {
+		for range stdout {
+		}
+	}()
+
+	select {
+	case <-proc.Done():
+	case <-time.After(testTimeout * time.Second):
+		t.Fatal("Done() was not closed after the process exited")
+	}
+}
+
+// Test Result reports the exit of a completed process
+func TestResult(t *testing.T) {
+	var proc *Process
+	if runtime.GOOS == windowsOS {
+		proc = New("cmd", "/c", "exit 3")
+	} else {
+		proc = New("sh", "-c", "exit 3")
+	}
+
+	if _, err := proc.Result(); err == nil {
+		t.Error("Expected Result() to fail before the process is started")
+	}
+
+	stdout, stderr, err := proc.Run()
+	if err != nil {
+		t.Fatalf("Run() failed: %v", err)
+	}
+
+	go func() {
+		for range stderr {
+		}
+	}()
+	go func() {
+		for range stdout {
+		}
+	}()
+
+	// Result can be called repeatedly, also after the process has exited
+	for i := 0; i < 2; i++ {
+		result, err := proc.Result()
+		if err != nil {
+			t.Fatalf("Result() failed: %v", err)
+		}
+		if result.ExitCode() != 3 {
+			t.Errorf("Expected exit code 3, got %d", result.ExitCode())
+		}
+		if result.Reason != ExitNormal {
+			t.Errorf("Expected reason %v, got %v", ExitNormal, result.Reason)
+		}
+		if result.Err == nil {
+			t.Error("Expected an exit error for a non-zero exit code")
+		}
+	}
+}
+
+// Test Wait, Result and Done do not wait for a descendant that inherited
+// standard output and error
+func TestWaitBackgroundedChild(t *testing.T) {
+	if runtime.GOOS == windowsOS {
+		t.Skip("no POSIX shell on Windows")
+	}
+	proc := New("sh", "-c", "sleep 600 & echo $!")
+	stdout, stderr, err := proc.Run()
+	if err != nil {
+		t.Fatalf("Run() failed: %v", err)
+	}
+	go func() {
+		for range stderr {
+		}
+	}()
+
+	pid, err := strconv.Atoi(<-stdout)
+	if err != nil {
+		t.Fatalf("Failed to read the PID of the background process: %v", err)
+	}
+	child, err := os.FindProcess(pid)
+	if err != nil {
+		t.Fatalf("FindProcess() failed: %v", err)
+	}
+	defer func() { _ = child.Kill() }()
+
+	waited := make(chan struct{})
+	go func() {
+		_, _ = proc.Wait() // The process may have been reaped before the call
+		close(waited)
+	}()
+	select {
+	case <-waited:
+	case <-time.After(testTimeout * time.Second):
+		t.Fatal("Wait() did not return after the process exited")
+	}
+	select {
+	case <-proc.Done():
+	default:
+		t.Error("Done() was not closed after Wait() returned")
+	}
+	if result, err := proc.Result(); err != nil || result.ExitCode() != 0 {
+		t.Errorf("Result() = %v, %v, want exit code 0", result, err)
+	}
+
+	// The output channels are closed once the descendant has exited
+	_ = child.Kill()
+	select {
+	case _, ok := <-stdout:
+		if ok {
+			t.Error("Unexpected output after the process exited")
+		}
+	case <-time.After(testTimeout * time.Second):
+		t.Error("stdout was not closed after the background process exited")
+	}
+}
@@ -9,16 +9,39 @@ package processctrl
 
 import (
 	"fmt"
+	"os"
 	"syscall"
 	"time"
 )
 
 // pauseUnix implements Unix-specific process suspension using SIGSTOP.
+// In cooperative mode the process is asked to pause with a catchable
+// signal first, and SIGSTOP is only sent if it did not stop itself.
+// A process running in its own cgroup is frozen through the cgroup
+// instead, which stops the whole tree atomically. In a sandbox, all
+// processes of the sandbox are stopped.
 func (p *Process) pauseUnix() error {
-	if err := p.cmd.Process.Signal(syscall.SIGSTOP); err != nil {
+	if p.coop != nil {
+		stopped, err := p.pauseCooperative(p.coop)
+		if err != nil {
+			return fmt.Errorf("failed to pause process: %w", err)
+		}
+		if stopped {
+			return nil
+		}
+	}
+
+	if p.cgroupFreezes() {
+		if err := p.setCgroupFrozen(true); err != nil {
+			return fmt.Errorf("failed to freeze cgroup: %w", err)
+		}
+		return nil
+	}
+
+	if err := p.signalAll(syscall.SIGSTOP); err != nil {
 		return fmt.Errorf("failed to pause process: %w", err)
 	}
-	return nil
+	return p.confirmStopped(true)
 }
 
 // pauseImpl provides the cross-platform interface for Unix.
@@ -27,10 +50,31 @@ func (p *Process) pauseImpl() error {
 }
 
 // resumeUnix implements Unix-specific process resumption using SIGCONT.
+// In cooperative mode the resume notification and acknowledgement follow.
+// A frozen cgroup is thawed first.
 func (p *Process) resumeUnix() error {
-	if err := p.cmd.Process.Signal(syscall.SIGCONT); err != nil {
+	var ack <-chan struct{}
+	if p.coop != nil && p.coop.ResumeMarker != "" {
+		var cancel func()
+		ack, cancel = p.lines.expect(p.coop.ResumeMarker)
+		defer cancel()
+	}
+
+	if p.cgroupFreezes() {
+		if err := p.setCgroupFrozen(false); err != nil {
+			return fmt.Errorf("failed to thaw cgroup: %w", err)
+		}
+	}
+	if err := p.signalAll(syscall.SIGCONT); err != nil {
 		return fmt.Errorf("failed to resume process: %w", err)
 	}
+	if err := p.confirmStopped(false); err != nil {
+		return err
+	}
+
+	if p.coop != nil {
+		return p.resumeCooperative(p.coop, ack)
+	}
 	return nil
 }
 
@@ -39,9 +83,18 @@ func (p *Process) resumeImpl() error {
 	return p.resumeUnix()
 }
 
+// signalImpl provides the cross-platform interface for Unix.
+func (p *Process) signalImpl(sig os.Signal) error {
+	s, ok := sig.(syscall.Signal)
+	if !ok {
+		return fmt.Errorf("unsupported signal type %T", sig)
+	}
+	return p.signal(s)
+}
+
 // killWithSignalUnix implements graceful and forceful process termination for Unix systems.
-// When graceful is true, it first sends SIGTERM to allow the process to clean up,
-// then waits for the specified timeout before sending SIGKILL if needed.
+// When graceful is true, it first sends SIGTERM to allow the process to clean up
+// (continuing it if it is paused), then waits for the specified timeout before sending SIGKILL if needed.
 //
 // Parameters:
 //   - timeout: Maximum time to wait for graceful shutdown before force-killing
@@ -51,18 +104,20 @@ func (p *Process) resumeImpl() error {
 func (p *Process) killWithSignalUnix(timeout time.Duration, graceful bool) error {
 	if graceful {
 		// Try SIGTERM first
-		if err := p.cmd.Process.Signal(syscall.SIGTERM); err != nil {
+		if err := p.signal(syscall.SIGTERM); err != nil {
 			return fmt.Errorf("failed to send SIGTERM: %w", err)
 		}
+		if p.paused || p.transitioning {
+			// A stopped process cannot act on SIGTERM until continued
+			if p.cgroupFreezes() {
+				_ = p.thawCgroup() // Ignore error - process might already be dead
+			}
+			_ = p.signalAll(syscall.SIGCONT) // Ignore error - process might already be dead
+		}
 
 		// Wait for graceful shutdown
-		done := make(chan error, 1)
-		go func() {
-			done <- p.cmd.Wait()
-		}()
-
 		select {
-		case <-done:
+		case <-p.exited:
 			// Process exited gracefully
 			return nil
 		case <-time.After(timeout):
@@ -71,7 +126,7 @@ func (p *Process) killWithSignalUnix(timeout time.Duration, graceful bool) error
 	}
 
 	// Force kill with SIGKILL
-	return p.cmd.Process.Kill()
+	return p.signal(syscall.SIGKILL)
 }
 
 // killWithSignalImpl provides the cross-platform interface for Unix.
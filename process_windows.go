@@ -39,6 +39,7 @@ package processctrl
 
 import (
 	"fmt"
+	"os"
 	"time"
 
 	"golang.org/x/sys/windows"
@@ -54,7 +55,23 @@ var (
 
 // pauseWindows implements Windows-specific process suspension using NtSuspendProcess.
 func (p *Process) pauseWindows() error {
-	h, err := windows.OpenProcess(windows.PROCESS_SUSPEND_RESUME, false, uint32(p.cmd.Process.Pid))
+	return suspendProcess(uint32(p.cmd.Process.Pid))
+}
+
+// pauseImpl provides the cross-platform interface for Windows.
+func (p *Process) pauseImpl() error {
+	return p.pauseWindows()
+}
+
+// resumeWindows implements Windows-specific process resumption using NtResumeProcess.
+func (p *Process) resumeWindows() error {
+	return resumeProcess(uint32(p.cmd.Process.Pid))
+}
+
+// suspendProcess suspends all threads of the process with the given ID
+// using NtSuspendProcess.
+func suspendProcess(pid uint32) error {
+	h, err := windows.OpenProcess(windows.PROCESS_SUSPEND_RESUME, false, pid)
 	if err != nil {
 		return fmt.Errorf("failed to open process for suspend: %w", err)
 	}
@@ -69,14 +86,10 @@ func (p *Process) pauseWindows() error {
 	return nil
 }
 
-// pauseImpl provides the cross-platform interface for Windows.
-func (p *Process) pauseImpl() error {
-	return p.pauseWindows()
-}
-
-// resumeWindows implements Windows-specific process resumption using NtResumeProcess.
-func (p *Process) resumeWindows() error {
-	h, err := windows.OpenProcess(windows.PROCESS_SUSPEND_RESUME, false, uint32(p.cmd.Process.Pid))
+// resumeProcess resumes all threads of the process with the given ID
+// using NtResumeProcess.
+func resumeProcess(pid uint32) error {
+	h, err := windows.OpenProcess(windows.PROCESS_SUSPEND_RESUME, false, pid)
 	if err != nil {
 		return fmt.Errorf("failed to open process for resume: %w", err)
 	}
@@ -96,6 +109,15 @@ func (p *Process) resumeImpl() error {
 	return p.resumeWindows()
 }
 
+// signalImpl provides the cross-platform interface for Windows.
+// Only os.Kill is supported.
+func (p *Process) signalImpl(sig os.Signal) error {
+	if sig != os.Kill {
+		return fmt.Errorf("unsupported signal on Windows: %v", sig)
+	}
+	return p.cmd.Process.Kill()
+}
+
 // killWithSignalWindows implements graceful and forceful process termination for Windows systems.
 // Unlike Unix systems that use signals, Windows uses TerminateProcess API calls.
 // When graceful is true, it first tries TerminateProcess with exit code 1,
@@ -110,11 +132,6 @@ func (p *Process) killWithSignalWindows(timeout time.Duration, graceful bool) er
 	if graceful {
 		// On Windows, we don't have SIGTERM, so we'll try a gentle approach
 		// by giving the process a chance to exit gracefully
-		done := make(chan error, 1)
-		go func() {
-			done <- p.cmd.Wait()
-		}()
-
 		// First, try to close the process gracefully by terminating it
 		// This is less forceful than Kill()
 		h, err := windows.OpenProcess(windows.PROCESS_TERMINATE, false, uint32(p.cmd.Process.Pid))
@@ -124,7 +141,7 @@ func (p *Process) killWithSignalWindows(timeout time.Duration, graceful bool) er
 		}
 
 		select {
-		case <-done:
+		case <-p.exited:
 			// Process exited gracefully
 			return nil
 		case <-time.After(timeout):
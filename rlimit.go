@@ -0,0 +1,123 @@
+package processctrl
+
+import "fmt"
+
+// RlimitInfinity is the value of an unlimited resource limit.
+const RlimitInfinity = ^uint64(0)
+
+// RlimitResource identifies a POSIX resource limit, see setrlimit(2).
+type RlimitResource int
+
+const (
+	RlimitCPU        RlimitResource = iota // CPU time in seconds; SIGXCPU at the soft limit
+	RlimitFSIZE                            // Size of files created, in bytes; SIGXFSZ when exceeded
+	RlimitDATA                             // Size of the data segment, in bytes
+	RlimitSTACK                            // Size of the main thread's stack, in bytes
+	RlimitCORE                             // Size of core dumps, in bytes
+	RlimitNOFILE                           // Number of open file descriptors, plus one
+	RlimitAS                               // Size of the virtual address space, in bytes
+	RlimitNPROC                            // Number of processes of the real user ID
+	RlimitMEMLOCK                          // Locked memory, in bytes
+	RlimitLOCKS                            // Number of file locks
+	RlimitSIGPENDING                       // Number of queued signals
+	RlimitMSGQUEUE                         // Bytes in POSIX message queues
+	RlimitNICE                             // Ceiling of the nice value, as 20 - nice
+	RlimitRTPRIO                           // Ceiling of the real-time priority
+)
+
+// String returns the name of the resource limit.
+func (r RlimitResource) String() string {
+	names := [...]string{
+		"RLIMIT_CPU", "RLIMIT_FSIZE", "RLIMIT_DATA", "RLIMIT_STACK", "RLIMIT_CORE",
+		"RLIMIT_NOFILE", "RLIMIT_AS", "RLIMIT_NPROC", "RLIMIT_MEMLOCK", "RLIMIT_LOCKS",
+		"RLIMIT_SIGPENDING", "RLIMIT_MSGQUEUE", "RLIMIT_NICE", "RLIMIT_RTPRIO",
+	}
+	if r < 0 || int(r) >= len(names) {
+		return fmt.Sprintf("RlimitResource(%d)", int(r))
+	}
+	return names[r]
+}
+
+// Rlimit is a soft and hard resource limit. Use RlimitInfinity for no
+// limit.
+type Rlimit struct {
+	Cur uint64 // Soft limit, enforced by the kernel
+	Max uint64 // Hard limit, the ceiling for the soft limit
+}
+
+// SetRlimit sets a resource limit of the child process. Limits set before
+// the process is started are applied to the child only, before it runs its
+// first instruction; the controlling process keeps its own limits. Limits
+// set while the process is running are applied immediately.
+//
+// The child is started under ptrace so that it stops right after exec,
+// its limits are set with prlimit and it is then released. This requires
+// that the controller is allowed to trace its children, which is the case
+// unless restricted by Yama or a seccomp filter. A program started through
+// a helper, i.e. in a sandbox, with Landlock rules, a seccomp profile or
+// from memory, gets its limits from the helper right before it is
+// executed instead.
+//
+// Only Linux is supported; other platforms return ErrNotSupported.
+//
+// Parameters:
+//   - resource: The limit to set
+//   - limit: Soft and hard limit; the soft limit must not exceed the hard limit
+//
+// Returns an error if the limit is invalid, cannot be applied to the
+// running process or is not supported.
+func (p *Process) SetRlimit(resource RlimitResource, limit Rlimit) error {
+	if resource < RlimitCPU || resource > RlimitRTPRIO {
+		return fmt.Errorf("invalid resource limit: %v", resource)
+	}
+	if limit.Cur > limit.Max {
+		return fmt.Errorf("soft limit %d exceeds hard limit %d for %v", limit.Cur, limit.Max, resource)
+	}
+	if !rlimitSupported {
+		return ErrNotSupported
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.running {
+		if err := setRlimit(p.programPID, resource, limit); err != nil {
+			return fmt.Errorf("failed to set %v: %w", resource, err)
+		}
+	}
+	if p.rlimits == nil {
+		p.rlimits = make(map[RlimitResource]Rlimit)
+	}
+	p.rlimits[resource] = limit
+	return nil
+}
+
+// Rlimit returns a resource limit of the process. While the process is
+// running the effective limit is read from the kernel, so changes made by
+// the process itself are reflected. Before the process is started the
+// limit set with SetRlimit is returned.
+//
+// Only Linux is supported; other platforms return ErrNotSupported.
+//
+// Returns an error if the process is not running and the limit has not
+// been set, or the limit cannot be read.
+func (p *Process) Rlimit(resource RlimitResource) (Rlimit, error) {
+	if resource < RlimitCPU || resource > RlimitRTPRIO {
+		return Rlimit{}, fmt.Errorf("invalid resource limit: %v", resource)
+	}
+	if !rlimitSupported {
+		return Rlimit{}, ErrNotSupported
+	}
+
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	if p.running {
+		return getRlimit(p.programPID, resource)
+	}
+	limit, ok := p.rlimits[resource]
+	if !ok {
+		return Rlimit{}, fmt.Errorf("%v not set", resource)
+	}
+	return limit, nil
+}
@@ -0,0 +1,138 @@
+//go:build linux
+
+// Package processctrl Linux resource limits
+//
+// This file applies resource limits to child processes with prlimit(2).
+// To close the window between exec and prlimit in which the child would
+// run with the controller's limits, the child is started with
+// PTRACE_TRACEME. The kernel then stops it with SIGTRAP right after the
+// exec, before its first instruction; the limits and scheduling
+// attributes and the OOM score adjustment are applied and the child is
+// detached and continues. A helper that executes the program (see
+// helper_linux.go) sets the resource limits itself right before the
+// exec, since the first exec starts the helper rather than the program.
+
+package processctrl
+
+import (
+	"fmt"
+	"runtime"
+
+	"golang.org/x/sys/unix"
+)
+
+// rlimitSupported reports whether SetRlimit is available.
+const rlimitSupported = true
+
+// rlimitResources maps resource limits to the kernel's resource numbers.
+var rlimitResources = [...]int{
+	RlimitCPU:        unix.RLIMIT_CPU,
+	RlimitFSIZE:      unix.RLIMIT_FSIZE,
+	RlimitDATA:       unix.RLIMIT_DATA,
+	RlimitSTACK:      unix.RLIMIT_STACK,
+	RlimitCORE:       unix.RLIMIT_CORE,
+	RlimitNOFILE:     unix.RLIMIT_NOFILE,
+	RlimitAS:         unix.RLIMIT_AS,
+	RlimitNPROC:      unix.RLIMIT_NPROC,
+	RlimitMEMLOCK:    unix.RLIMIT_MEMLOCK,
+	RlimitLOCKS:      unix.RLIMIT_LOCKS,
+	RlimitSIGPENDING: unix.RLIMIT_SIGPENDING,
+	RlimitMSGQUEUE:   unix.RLIMIT_MSGQUEUE,
+	RlimitNICE:       unix.RLIMIT_NICE,
+	RlimitRTPRIO:     unix.RLIMIT_RTPRIO,
+}
+
+// setRlimit sets a resource limit of the process with the given PID.
+func setRlimit(pid int, resource RlimitResource, limit Rlimit) error {
+	rlim := unix.Rlimit{Cur: limit.Cur, Max: limit.Max}
+	return unix.Prlimit(pid, rlimitResources[resource], &rlim, nil)
+}
+
+// getRlimit returns a resource limit of the process with the given PID.
+func getRlimit(pid int, resource RlimitResource) (Rlimit, error) {
+	var rlim unix.Rlimit
+	if err := unix.Prlimit(pid, rlimitResources[resource], nil, &rlim); err != nil {
+		return Rlimit{}, err
+	}
+	return Rlimit{Cur: rlim.Cur, Max: rlim.Max}, nil
+}
+
+// prepareRlimits arranges for the child to stop after exec if resource
+// limits, scheduling attributes or an OOM score adjustment are set.
+// Resource limits of a program started through a helper are set by the
+// helper right before it executes the program instead, so that they do
+// not restrict the helper. ptrace requests must come from the thread that
+// started the child, so the calling goroutine is locked to its thread
+// until the returned function is called, which must happen after
+// applyRlimits.
+// It must be called with p.mu held before the command is started, after
+// the helper has been prepared.
+func (p *Process) prepareRlimits() func() {
+	rlimits := len(p.rlimits) > 0 && p.helper == nil
+	if !rlimits && !p.sched.isSet() && p.oomScoreAdj == nil {
+		return func() {}
+	}
+	runtime.LockOSThread()
+	p.cmd.SysProcAttr.Ptrace = true
+	return runtime.UnlockOSThread
+}
+
+// applyRlimits sets the resource limits, unless a helper sets them,
+// the scheduling attributes and OOM score adjustment of the stopped child
+// and lets it continue. If they
+// cannot be applied, the child is killed and reaped. It must be called
+// with p.mu held right after the command has been started.
+func (p *Process) applyRlimits() error {
+	if !p.cmd.SysProcAttr.Ptrace {
+		return nil
+	}
+	pid := p.cmd.Process.Pid
+
+	err := func() error {
+		var ws unix.WaitStatus
+		if _, err := unix.Wait4(pid, &ws, unix.WALL, nil); err != nil {
+			return err
+		}
+		if !ws.Stopped() {
+			return fmt.Errorf("child did not stop after exec: %v", ws)
+		}
+		if p.helper == nil {
+			for resource, limit := range p.rlimits {
+				if err := setRlimit(pid, resource, limit); err != nil {
+					return fmt.Errorf("failed to set %v: %w", resource, err)
+				}
+			}
+		}
+		if err := applySched(pid, &p.sched); err != nil {
+			return err
+		}
+		if p.oomScoreAdj != nil {
+			if err := setOOMScoreAdj(pid, *p.oomScoreAdj); err != nil {
+				return fmt.Errorf("failed to set OOM score adjustment: %w", err)
+			}
+		}
+		return unix.PtraceDetach(pid)
+	}()
+	if err == nil {
+		return nil
+	}
+
+	_ = p.cmd.Process.Kill() // Ignore error - process might already be dead
+	_ = p.cmd.Wait()         // Ignore error - the process was killed
+	if p.pidfd >= 0 {
+		_ = unix.Close(p.pidfd) // Ignore error during cleanup
+		p.pidfd = -1
+	}
+	return err
+}
+
+// setOwnRlimits sets resource limits of the calling process. Helpers call
+// it right before they execute the program.
+func setOwnRlimits(limits map[RlimitResource]Rlimit) error {
+	for resource, limit := range limits {
+		if err := setRlimit(0, resource, limit); err != nil {
+			return fmt.Errorf("failed to set %v: %w", resource, err)
+		}
+	}
+	return nil
+}
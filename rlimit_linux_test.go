@@ -0,0 +1,137 @@
+//go:build linux
+
+package processctrl
+
+import (
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+
+	"golang.org/x/sys/unix"
+)
+
+// exitSignal returns the signal that terminated the process, or 0.
+func exitSignal(result *ExitResult) syscall.Signal {
+	sig, _ := result.Signal.(syscall.Signal)
+	return sig
+}
+
+func TestRlimitAppliedToChildOnly(t *testing.T) {
+	var before unix.Rlimit
+	if err := unix.Getrlimit(unix.RLIMIT_NOFILE, &before); err != nil {
+		t.Fatalf("Getrlimit() failed: %v", err)
+	}
+
+	proc := New("sh", "-c", "ulimit -n; ulimit -Hn; sleep 0.2")
+	if err := proc.SetRlimit(RlimitNOFILE, Rlimit{Cur: 32, Max: 64}); err != nil {
+		t.Fatalf("SetRlimit() failed: %v", err)
+	}
+	if got, err := proc.Rlimit(RlimitNOFILE); err != nil || got != (Rlimit{Cur: 32, Max: 64}) {
+		t.Errorf("Rlimit() before start = %+v, %v", got, err)
+	}
+
+	stdout, stderr, err := proc.Run()
+	if err != nil {
+		t.Fatalf("Run() failed: %v", err)
+	}
+	go func() {
+		for range stderr {
+		}
+	}()
+
+	// Read back the effective limit from the kernel
+	if got, err := proc.Rlimit(RlimitNOFILE); err != nil || got != (Rlimit{Cur: 32, Max: 64}) {
+		t.Errorf("Rlimit() of running process = %+v, %v", got, err)
+	}
+
+	var lines []string
+	for line := range stdout {
+		lines = append(lines, line)
+	}
+	if strings.Join(lines, " ") != "32 64" {
+		t.Errorf("Child should see soft limit 32 and hard limit 64, got %v", lines)
+	}
+
+	var after unix.Rlimit
+	if err := unix.Getrlimit(unix.RLIMIT_NOFILE, &after); err != nil {
+		t.Fatalf("Getrlimit() failed: %v", err)
+	}
+	if after != before {
+		t.Errorf("Controller limits changed from %+v to %+v", before, after)
+	}
+}
+
+func TestRlimitFileSizeExceeded(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "out")
+	proc := New("dd", "if=/dev/zero", "of="+out, "bs=4096", "count=4")
+	if err := proc.SetRlimit(RlimitFSIZE, Rlimit{Cur: 4096, Max: 4096}); err != nil {
+		t.Fatalf("SetRlimit() failed: %v", err)
+	}
+
+	_, result := runCollect(t, proc)
+	if sig := exitSignal(result); sig != syscall.SIGXFSZ {
+		t.Errorf("Expected the child to be killed by SIGXFSZ, got %v (%v)", sig, result.State)
+	}
+}
+
+func TestRlimitCPUExceeded(t *testing.T) {
+	proc := New("sh", "-c", "while :; do :; done")
+	if err := proc.SetRlimit(RlimitCPU, Rlimit{Cur: 1, Max: 2}); err != nil {
+		t.Fatalf("SetRlimit() failed: %v", err)
+	}
+
+	_, result := runCollect(t, proc)
+	if sig := exitSignal(result); sig != syscall.SIGXCPU {
+		t.Errorf("Expected the child to be killed by SIGXCPU, got %v (%v)", sig, result.State)
+	}
+}
+
+func TestSetRlimitRunning(t *testing.T) {
+	proc := newLongRunning()
+	startTest(t, proc)
+
+	if err := proc.SetRlimit(RlimitCORE, Rlimit{Cur: 0, Max: 0}); err != nil {
+		t.Fatalf("SetRlimit() failed: %v", err)
+	}
+	if got, err := proc.Rlimit(RlimitCORE); err != nil || got != (Rlimit{}) {
+		t.Errorf("Rlimit() = %+v, %v, want zero limit", got, err)
+	}
+}
+
+func TestSetRlimitInvalid(t *testing.T) {
+	proc := New("true")
+
+	if err := proc.SetRlimit(RlimitNOFILE, Rlimit{Cur: 10, Max: 5}); err == nil {
+		t.Error("SetRlimit() should fail if the soft limit exceeds the hard limit")
+	}
+	if err := proc.SetRlimit(RlimitResource(99), Rlimit{}); err == nil {
+		t.Error("SetRlimit() should fail for an unknown resource")
+	}
+	if _, err := proc.Rlimit(RlimitAS); err == nil {
+		t.Error("Rlimit() should fail for a limit that was not set")
+	}
+}
+
+func TestRlimitWithHelper(t *testing.T) {
+	if !seccompSupported {
+		t.Skip("seccomp profiles are not supported on this architecture")
+	}
+	// The limits are too low for the helper's Go runtime, but enough for
+	// the shell
+	proc := New("sh", "-c", "ulimit -n; ulimit -v")
+	if err := proc.SetSeccomp(&SeccompProfile{Presets: []string{SeccompNoNetwork}}); err != nil {
+		t.Fatalf("SetSeccomp() failed: %v", err)
+	}
+	if err := proc.SetRlimit(RlimitNOFILE, Rlimit{Cur: 4, Max: 4}); err != nil {
+		t.Fatalf("SetRlimit() failed: %v", err)
+	}
+	if err := proc.SetRlimit(RlimitAS, Rlimit{Cur: 16 << 20, Max: 16 << 20}); err != nil {
+		t.Fatalf("SetRlimit() failed: %v", err)
+	}
+
+	lines, result := runCollect(t, proc)
+	if strings.Join(lines, " ") != "4 16384" || result.ExitCode() != 0 {
+		t.Errorf("Output = %q, ExitCode() = %d, want limits 4 and 16384 and exit code 0", lines, result.ExitCode())
+	}
+}
@@ -0,0 +1,31 @@
+//go:build !linux
+
+// Package processctrl resource limits stub
+//
+// Resource limits of child processes are applied with prlimit(2), which
+// is only available on Linux.
+
+package processctrl
+
+// rlimitSupported reports whether SetRlimit is available.
+const rlimitSupported = false
+
+// setRlimit is not supported on this platform.
+func setRlimit(int, RlimitResource, Rlimit) error {
+	return ErrNotSupported
+}
+
+// getRlimit is not supported on this platform.
+func getRlimit(int, RlimitResource) (Rlimit, error) {
+	return Rlimit{}, ErrNotSupported
+}
+
+// prepareRlimits is a no-op on platforms without resource limits.
+func (p *Process) prepareRlimits() func() {
+	return func() {}
+}
+
+// applyRlimits is a no-op on platforms without resource limits.
+func (p *Process) applyRlimits() error {
+	return nil
+}
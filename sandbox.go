@@ -0,0 +1,201 @@
+package processctrl
+
+import (
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// IDMap maps a range of user or group IDs in a sandbox to IDs on the host.
+type IDMap struct {
+	ContainerID int // First ID in the sandbox
+	HostID      int // First ID on the host
+	Size        int // Number of IDs
+}
+
+// BindMount makes a host path available read-only in a sandbox.
+type BindMount struct {
+	Source string // Host path
+	Target string // Path in the sandbox; defaults to Source, see Sandbox for missing targets
+}
+
+// Sandbox isolates a process for running untrusted commands. The process
+// is started in new user, PID, mount, UTS, IPC and network namespaces:
+// it only sees its own processes, cannot reach the network except through
+// its own loopback interface, and runs as root of a user namespace. Root
+// of the sandbox is never mapped to root of the host, so on the host the
+// process only has the rights of the user and group IDs it is mapped to.
+//
+// The file system is the host's, mounted read-only unless WritableRoot is
+// set, with the bind mounts in ReadOnly and an empty tmpfs on /tmp if
+// PrivateTmp is set. Changes to mounts in the sandbox are not visible on
+// the host. Bind mount targets that do not exist are created in the
+// private /tmp, or with WritableRoot on the host; others must exist.
+type Sandbox struct {
+	UIDMappings  []IDMap     // User ID mappings; see SetSandbox for the default
+	GIDMappings  []IDMap     // Group ID mappings; see SetSandbox for the default
+	Hostname     string      // Host name in the sandbox; defaults to "sandbox"
+	ReadOnly     []BindMount // Host paths mounted read-only
+	PrivateTmp   bool        // Mount an empty tmpfs on /tmp
+	WritableRoot bool        // Keep the host file system writable, as far as the mapped IDs allow
+}
+
+// defaultSandboxHostname is the host name of a sandbox without Hostname.
+const defaultSandboxHostname = "sandbox"
+
+// maxHostnameLen is the maximum length of a host name on Linux.
+const maxHostnameLen = 64
+
+// SetSandbox runs the process in a sandbox, or disables the sandbox if s
+// is nil. It must be called before the process is started.
+//
+// A small init process, started from the controller's own executable
+// (see Init), sets up the sandbox and starts the program as its child; it
+// is process 1 of the sandbox. The init process forwards signals to the
+// program and reports its exit status, so the exit result's ExitCode and
+// Signal describe the program; the processes left in the sandbox are
+// killed when the program exits. PID() and events refer to the init
+// process, while Stats, resource limits, scheduling attributes, timeouts
+// and the detection of self-stops act on the program. Pause, Resume and
+// SetCPULimit stop and continue all processes in the sandbox; use
+// SetCgroup to limit the resources of the whole sandbox.
+//
+// The init process runs as root of the sandbox, so user and group ID 0
+// must be mapped. Credentials and capabilities are applied to the program
+// inside the sandbox, so user and group IDs refer to the sandbox and must
+// be mapped too. Mapping IDs other than the controller's own requires
+// root. Host ID 0 cannot be mapped. Without mappings, root of the sandbox
+// is mapped to the controller's effective user and group, or if the
+// controller runs as root, to the first subordinate user and group ID of
+// its user in /etc/subuid and /etc/subgid. Bind mount sources must be
+// accessible to the mapped IDs.
+//
+// Only Linux is supported; other platforms return ErrNotSupported.
+//
+// Returns an error if a mapping or bind mount is invalid, ID 0 is not
+// mapped, host ID 0 is mapped, a controller running as root has no
+// subordinate IDs for the default mappings, a bind mount source does not
+// exist, the host name is too long, sandboxes are not supported or the
+// process is running.
+func (p *Process) SetSandbox(s *Sandbox) error {
+	if !sandboxSupported {
+		return ErrNotSupported
+	}
+
+	var sandbox *Sandbox
+	if s != nil {
+		copied := Sandbox{
+			UIDMappings:  append([]IDMap(nil), s.UIDMappings...),
+			GIDMappings:  append([]IDMap(nil), s.GIDMappings...),
+			Hostname:     s.Hostname,
+			ReadOnly:     append([]BindMount(nil), s.ReadOnly...),
+			PrivateTmp:   s.PrivateTmp,
+			WritableRoot: s.WritableRoot,
+		}
+		if len(copied.UIDMappings) == 0 {
+			m, err := defaultIDMap(os.Geteuid(), "/etc/subuid")
+			if err != nil {
+				return err
+			}
+			copied.UIDMappings = []IDMap{m}
+		}
+		if len(copied.GIDMappings) == 0 {
+			m, err := defaultIDMap(os.Getegid(), "/etc/subgid")
+			if err != nil {
+				return err
+			}
+			copied.GIDMappings = []IDMap{m}
+		}
+		for _, maps := range [][]IDMap{copied.UIDMappings, copied.GIDMappings} {
+			root := false
+			for _, m := range maps {
+				if m.ContainerID < 0 || m.HostID < 0 || m.Size <= 0 {
+					return fmt.Errorf("invalid ID mapping: %+v", m)
+				}
+				if m.HostID == 0 {
+					return fmt.Errorf("host ID 0 must not be mapped in a sandbox: %+v", m)
+				}
+				root = root || m.ContainerID == 0
+			}
+			if !root {
+				return fmt.Errorf("ID 0 must be mapped for the init process of the sandbox")
+			}
+		}
+		if copied.Hostname == "" {
+			copied.Hostname = defaultSandboxHostname
+		}
+		if len(copied.Hostname) > maxHostnameLen {
+			return fmt.Errorf("host name is longer than %d bytes", maxHostnameLen)
+		}
+		for i, m := range copied.ReadOnly {
+			if m.Target == "" {
+				m.Target = m.Source
+			}
+			if !filepath.IsAbs(m.Source) || !filepath.IsAbs(m.Target) {
+				return fmt.Errorf("bind mount paths must be absolute: %+v", m)
+			}
+			if _, err := os.Stat(m.Source); err != nil {
+				return fmt.Errorf("invalid bind mount source: %w", err)
+			}
+			copied.ReadOnly[i] = BindMount{Source: filepath.Clean(m.Source), Target: filepath.Clean(m.Target)}
+		}
+		sandbox = &copied
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.running {
+		return fmt.Errorf("cannot change the sandbox of a running process")
+	}
+	p.sandbox = sandbox
+	return nil
+}
+
+// defaultIDMap returns the mapping of root of a sandbox without mappings:
+// the controller's effective ID id or, if that is 0, the first
+// subordinate ID of the controller's user in file.
+func defaultIDMap(id int, file string) (IDMap, error) {
+	if id != 0 {
+		return IDMap{ContainerID: 0, HostID: id, Size: 1}, nil
+	}
+	start, err := subordinateID(file)
+	if err != nil {
+		return IDMap{}, fmt.Errorf("root of a sandbox is not mapped to root of the host, "+
+			"set the ID mappings or subordinate IDs in %s: %w", file, err)
+	}
+	return IDMap{ContainerID: 0, HostID: start, Size: 1}, nil
+}
+
+// subordinateID returns the first ID of the controller's user in a
+// subordinate ID file like /etc/subuid, whose lines have the form
+// user:start:count with the user given by name or ID.
+func subordinateID(file string) (int, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return 0, err
+	}
+	uid := strconv.Itoa(os.Geteuid())
+	names := []string{uid}
+	if u, err := user.LookupId(uid); err == nil {
+		names = append(names, u.Username)
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		fields := strings.Split(strings.TrimSpace(line), ":")
+		if len(fields) != 3 || !slices.Contains(names, fields[0]) {
+			continue
+		}
+		start, err := strconv.Atoi(fields[1])
+		if err != nil || start <= 0 {
+			continue
+		}
+		if count, err := strconv.Atoi(fields[2]); err == nil && count > 0 {
+			return start, nil
+		}
+	}
+	return 0, fmt.Errorf("no subordinate IDs for user %s", names[len(names)-1])
+}
@@ -0,0 +1,352 @@
+//go:build linux
+
+// Package processctrl sandbox init process
+//
+// This file contains the init process of a sandbox, a helper (see
+// helper_linux.go) started in the new namespaces. It sets up the mounts,
+// the host name and the loopback interface, starts the program and stays
+// process 1 of the sandbox, forwarding signals to the program and
+// reaping orphans until the program exits.
+
+package processctrl
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// runSandboxInit runs the init process of a sandbox and returns its exit
+// code.
+func runSandboxInit(conn *os.File) int {
+	// Signals are queued until the program has started
+	sigs := make(chan os.Signal, 64)
+	signal.Notify(sigs)
+
+	var cfg sandboxConfig
+	if err := readHelperConfig(conn, &cfg); err != nil {
+		return helperFailed(conn, err)
+	}
+	if err := setupSandbox(&cfg); err != nil {
+		return helperFailed(conn, fmt.Errorf("failed to set up sandbox: %w", err))
+	}
+	pid, execKilled, err := startSandboxed(&cfg)
+	if err != nil {
+		return helperFailed(conn, fmt.Errorf("failed to start process in sandbox: %w", err))
+	}
+	sendHelperReply(conn, helperReply{PID: pid})
+
+	go forwardSignals(sigs, pid)
+	ws, err := reapSandbox(pid)
+	if err != nil {
+		return 1
+	}
+	// The controller gets the exit status of the program, since the init
+	// process cannot be terminated by the same signal
+	sendHelperReply(conn, helperReply{Status: &ws, Killed: execKilled.Load()})
+	if ws.Signaled() {
+		return 128 + int(ws.Signal())
+	}
+	return ws.ExitStatus()
+}
+
+// setupSandbox sets up the mounts, the host name and the loopback
+// interface in the namespaces of the init process.
+func setupSandbox(cfg *sandboxConfig) error {
+	// Keep mount changes from propagating back to the host
+	if err := unix.Mount("", "/", "", unix.MS_REC|unix.MS_PRIVATE, ""); err != nil {
+		return fmt.Errorf("failed to make mounts private: %w", err)
+	}
+	// The host file system is read-only below the mounts that follow
+	if !cfg.WritableRoot {
+		if err := remountReadOnly("/"); err != nil {
+			return fmt.Errorf("failed to make the root read-only: %w", err)
+		}
+	}
+	// A new /proc shows only the processes of the sandbox
+	if err := unix.Mount("proc", "/proc", "proc", unix.MS_NOSUID|unix.MS_NODEV|unix.MS_NOEXEC, ""); err != nil {
+		return fmt.Errorf("failed to mount /proc: %w", err)
+	}
+	// Bind mount sources are opened before the private /tmp can hide
+	// them, and the private /tmp is mounted before the bind mounts, so
+	// that targets below /tmp remain visible
+	sources := make([]*os.File, len(cfg.ReadOnly))
+	defer func() {
+		for _, f := range sources {
+			if f != nil {
+				_ = f.Close() // Ignore error during cleanup
+			}
+		}
+	}()
+	for i, m := range cfg.ReadOnly {
+		fd, err := unix.Open(m.Source, unix.O_PATH|unix.O_CLOEXEC, 0)
+		if err != nil {
+			return fmt.Errorf("failed to open %s: %w", m.Source, err)
+		}
+		sources[i] = os.NewFile(uintptr(fd), m.Source)
+	}
+	if cfg.PrivateTmp {
+		if err := unix.Mount("tmpfs", "/tmp", "tmpfs", unix.MS_NOSUID|unix.MS_NODEV, "mode=1777"); err != nil {
+			return fmt.Errorf("failed to mount /tmp: %w", err)
+		}
+	}
+	for i, m := range cfg.ReadOnly {
+		source := fmt.Sprintf("/proc/self/fd/%d", sources[i].Fd())
+		if err := bindReadOnly(source, m.Target); err != nil {
+			return fmt.Errorf("failed to mount %s on %s: %w", m.Source, m.Target, err)
+		}
+	}
+	if err := unix.Sethostname([]byte(cfg.Hostname)); err != nil {
+		return fmt.Errorf("failed to set host name: %w", err)
+	}
+	if err := loopbackUp(); err != nil {
+		return fmt.Errorf("failed to bring up loopback interface: %w", err)
+	}
+	return nil
+}
+
+// bindReadOnly bind mounts source read-only on target, including the
+// mounts below it.
+func bindReadOnly(source, target string) error {
+	if err := createMountPoint(source, target); err != nil {
+		return err
+	}
+	if err := unix.Mount(source, target, "", unix.MS_BIND|unix.MS_REC, ""); err != nil {
+		return err
+	}
+	return remountReadOnly(target)
+}
+
+// remountReadOnly makes the mount on target and the mounts below it
+// read-only.
+func remountReadOnly(target string) error {
+	attr := unix.MountAttr{Attr_set: unix.MOUNT_ATTR_RDONLY}
+	err := unix.MountSetattr(unix.AT_FDCWD, target, unix.AT_RECURSIVE, &attr)
+	if !errors.Is(err, unix.ENOSYS) {
+		return err
+	}
+
+	// Before Linux 5.12 every mount is remounted on its own
+	mounts, err := mountsBelow(target)
+	if err != nil {
+		return err
+	}
+	for _, m := range mounts {
+		if err := remountOneReadOnly(m); err != nil {
+			return fmt.Errorf("failed to remount %s: %w", m, err)
+		}
+	}
+	return nil
+}
+
+// mountsBelow returns the mount points of the calling process at or
+// below target, parents first, from /proc/self/mountinfo.
+func mountsBelow(target string) ([]string, error) {
+	data, err := os.ReadFile("/proc/self/mountinfo")
+	if err != nil {
+		return nil, err
+	}
+	var mounts []string
+	for _, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 5 {
+			continue
+		}
+		// Blanks and backslashes in paths are octal escapes
+		mount := unescapeMountPath(fields[4])
+		if mount == target || target == "/" || strings.HasPrefix(mount, target+"/") {
+			mounts = append(mounts, mount)
+		}
+	}
+	return mounts, nil
+}
+
+// unescapeMountPath decodes the octal escapes of a path in
+// /proc/self/mountinfo.
+func unescapeMountPath(path string) string {
+	var b strings.Builder
+	for i := 0; i < len(path); i++ {
+		if path[i] == '\\' && i+3 < len(path) {
+			if n, err := strconv.ParseUint(path[i+1:i+4], 8, 8); err == nil {
+				b.WriteByte(byte(n))
+				i += 3
+				continue
+			}
+		}
+		b.WriteByte(path[i])
+	}
+	return b.String()
+}
+
+// remountOneReadOnly makes only the mount on target read-only. Flags
+// locked by the user namespace must be kept when remounting.
+func remountOneReadOnly(target string) error {
+	var st unix.Statfs_t
+	if err := unix.Statfs(target, &st); err != nil {
+		return err
+	}
+	flags := uintptr(unix.MS_BIND | unix.MS_REMOUNT | unix.MS_RDONLY)
+	for flag, ms := range map[int64]uintptr{
+		unix.ST_NOSUID:     unix.MS_NOSUID,
+		unix.ST_NODEV:      unix.MS_NODEV,
+		unix.ST_NOEXEC:     unix.MS_NOEXEC,
+		unix.ST_NOATIME:    unix.MS_NOATIME,
+		unix.ST_NODIRATIME: unix.MS_NODIRATIME,
+		unix.ST_RELATIME:   unix.MS_RELATIME,
+	} {
+		if int64(st.Flags)&flag != 0 {
+			flags |= ms
+		}
+	}
+	return unix.Mount("", target, "", flags, "")
+}
+
+// createMountPoint creates target as a directory or an empty file,
+// depending on source, if it does not exist.
+func createMountPoint(source, target string) error {
+	if _, err := os.Stat(target); err == nil {
+		return nil
+	}
+	info, err := os.Stat(source)
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		return os.MkdirAll(target, 0o755)
+	}
+	if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+// loopbackUp brings up the loopback interface of the network namespace.
+func loopbackUp() error {
+	fd, err := unix.Socket(unix.AF_INET, unix.SOCK_DGRAM|unix.SOCK_CLOEXEC, 0)
+	if err != nil {
+		return err
+	}
+	defer unix.Close(fd)
+
+	ifr, err := unix.NewIfreq("lo")
+	if err != nil {
+		return err
+	}
+	if err := unix.IoctlIfreq(fd, unix.SIOCGIFFLAGS, ifr); err != nil {
+		return err
+	}
+	ifr.SetUint16(ifr.Uint16() | unix.IFF_UP)
+	return unix.IoctlIfreq(fd, unix.SIOCSIFFLAGS, ifr)
+}
+
+// startSandboxed starts the program or its image with the credentials,
+// capabilities, Landlock rules, resource limits and seccomp filter of the
+// configuration and returns its PID. If the seccomp filter denies exec,
+// the init process supervises the program's exec and the returned flag is
+// set if the program is killed for one.
+func startSandboxed(cfg *sandboxConfig) (int, *atomic.Bool, error) {
+	cmd := exec.Command(cfg.Path)
+	cmd.Args = cfg.Args
+	if cfg.Image {
+		// The exec helper executes the image instead
+		cmd.Err = nil
+	}
+	if cfg.Script {
+		setExtraFile(cmd, scriptFD, os.NewFile(scriptFD, "script"))
+	}
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	cmd.SysProcAttr = &syscall.SysProcAttr{Credential: cfg.Credential}
+	if cred := cfg.Credential; cred != nil {
+		cred.NoSetGroups = len(cred.Groups) == 0 && !setgroupsAllowed()
+	}
+	if cfg.Caps != nil {
+		cmd.SysProcAttr.AmbientCaps = ambientCaps(cfg.Caps)
+	}
+	var helper *helperStart
+	if cfg.Image || cfg.Landlock != nil || cfg.Seccomp != nil || len(cfg.Rlimits) > 0 {
+		var err error
+		helper, err = newHelper(cmd, helperExec, execConfig{
+			Path:     cfg.Path,
+			Args:     cfg.Args,
+			Image:    cfg.Image,
+			Landlock: cfg.Landlock,
+			Seccomp:  cfg.Seccomp,
+			Rlimits:  cfg.Rlimits,
+		})
+		if err != nil {
+			return 0, nil, err
+		}
+		if cfg.Image {
+			setExtraFile(cmd, helperImageFD, os.NewFile(helperImageFD, "image"))
+		}
+	}
+	execKilled := new(atomic.Bool)
+	if helper != nil && cfg.Seccomp != nil && cfg.Seccomp.deniesExec() {
+		// The listener stays open until the init process exits
+		helper.onListener = func(listener *os.File) {
+			go superviseExec(listener, cfg.Seccomp, func(pid int) {
+				if pid == cmd.Process.Pid {
+					execKilled.Store(true)
+				}
+			})
+		}
+	}
+	if err := withRestrictedThread(cfg.Caps, cmd.Start); err != nil {
+		if helper != nil {
+			helper.close()
+		}
+		return 0, nil, err
+	}
+	if helper != nil {
+		if _, err := helper.run(); err != nil {
+			_ = cmd.Process.Kill() // Ignore error - the helper might already be dead
+			_ = cmd.Wait()         // Ignore error - the helper failed
+			return 0, nil, err
+		}
+	}
+	return cmd.Process.Pid, execKilled, nil
+}
+
+// forwardSignals delivers the signals received by the init process to
+// the program.
+func forwardSignals(sigs <-chan os.Signal, pid int) {
+	for sig := range sigs {
+		s, ok := sig.(syscall.Signal)
+		if !ok || s == syscall.SIGCHLD || s == syscall.SIGURG || s == syscall.SIGPIPE {
+			// Signals of the init process itself and of the Go runtime
+			continue
+		}
+		_ = unix.Kill(pid, s) // Ignore error - the program might have exited
+	}
+}
+
+// reapSandbox reaps the program and orphaned processes until the program
+// exits, and returns the exit status of the program.
+func reapSandbox(pid int) (unix.WaitStatus, error) {
+	for {
+		var ws unix.WaitStatus
+		wpid, err := unix.Wait4(-1, &ws, 0, nil)
+		if err == unix.EINTR {
+			continue
+		}
+		if err != nil {
+			return 0, err
+		}
+		if wpid == pid {
+			return ws, nil
+		}
+	}
+}
@@ -0,0 +1,239 @@
+//go:build linux
+
+// Package processctrl Linux sandbox
+//
+// This file starts child processes in new namespaces. The namespaces are
+// created by the standard library when the child is cloned, but mounts,
+// the host name and the loopback interface have to be set up from inside
+// them before the program runs. The child is therefore a helper that
+// becomes the init process of the sandbox (see sandbox_init_linux.go).
+
+package processctrl
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// sandboxSupported reports whether SetSandbox is available.
+const sandboxSupported = true
+
+// sandboxNamespaces are the namespaces created for a sandbox.
+const sandboxNamespaces = syscall.CLONE_NEWUSER | syscall.CLONE_NEWPID | syscall.CLONE_NEWNS |
+	syscall.CLONE_NEWUTS | syscall.CLONE_NEWIPC | syscall.CLONE_NEWNET
+
+// sandboxConfig is sent to the init process of a sandbox.
+type sandboxConfig struct {
+	Path         string
+	Args         []string
+	Hostname     string
+	ReadOnly     []BindMount
+	PrivateTmp   bool
+	WritableRoot bool
+	Credential   *syscall.Credential
+	Caps         *Capabilities
+	Image        bool // The program image is helperImageFD
+	Script       bool // The script source is scriptFD
+	Landlock     *landlockRules
+	Seccomp      *seccompRules
+	Rlimits      map[RlimitResource]Rlimit
+}
+
+// prepareSandbox replaces the command with the init process of the
+// sandbox. It must be called with p.mu held before the command is
+// started, after the credentials and capabilities have been applied.
+func (p *Process) prepareSandbox() error {
+	if p.sandbox == nil || p.cmd.Err != nil {
+		// Without a program, Start reports the error
+		return nil
+	}
+
+	attr := p.cmd.SysProcAttr
+	if cred := attr.Credential; cred != nil {
+		if !mapped(p.sandbox.UIDMappings, cred.Uid) {
+			return fmt.Errorf("user %d is not mapped in the sandbox", cred.Uid)
+		}
+		for _, gid := range append([]uint32{cred.Gid}, cred.Groups...) {
+			if !mapped(p.sandbox.GIDMappings, gid) {
+				return fmt.Errorf("group %d is not mapped in the sandbox", gid)
+			}
+		}
+	}
+
+	h, err := newHelper(p.cmd, helperSandbox, sandboxConfig{
+		Path:         p.cmd.Path,
+		Args:         p.cmd.Args,
+		Hostname:     p.sandbox.Hostname,
+		ReadOnly:     p.sandbox.ReadOnly,
+		PrivateTmp:   p.sandbox.PrivateTmp,
+		WritableRoot: p.sandbox.WritableRoot,
+		Credential:   attr.Credential,
+		Caps:         p.caps,
+		Image:        p.image != nil,
+		Script:       p.script != nil && p.script.file != nil,
+		Landlock:     p.landlockRules,
+		Seccomp:      p.seccomp,
+		Rlimits:      p.rlimits,
+	})
+	if err != nil {
+		return err
+	}
+	p.helper = h
+	p.passImage()
+
+	// The program gets the credentials and capabilities from the init
+	// process, which runs as root of the sandbox to keep its capabilities
+	// across exec. Unprivileged users can only map their own group with
+	// setgroups(2) denied.
+	setgroups := os.Geteuid() == 0 && setgroupsAllowed()
+	attr.Credential = &syscall.Credential{Uid: 0, Gid: 0, NoSetGroups: !setgroups}
+	attr.AmbientCaps = nil
+	attr.Cloneflags |= sandboxNamespaces
+	attr.UidMappings = sysIDMaps(p.sandbox.UIDMappings)
+	attr.GidMappings = sysIDMaps(p.sandbox.GIDMappings)
+	attr.GidMappingsEnableSetgroups = setgroups
+	return nil
+}
+
+// sysIDMaps converts ID mappings for SysProcAttr.
+func sysIDMaps(maps []IDMap) []syscall.SysProcIDMap {
+	result := make([]syscall.SysProcIDMap, len(maps))
+	for i, m := range maps {
+		result[i] = syscall.SysProcIDMap{ContainerID: m.ContainerID, HostID: m.HostID, Size: m.Size}
+	}
+	return result
+}
+
+// mapped reports whether id is in one of the mappings.
+func mapped(maps []IDMap, id uint32) bool {
+	for _, m := range maps {
+		if int64(id) >= int64(m.ContainerID) && int64(id) < int64(m.ContainerID)+int64(m.Size) {
+			return true
+		}
+	}
+	return false
+}
+
+// sandboxStatusTimeout bounds how long the reaper waits for the exit
+// status of the program from the init process of a sandbox, which has
+// already sent it or died by then.
+const sandboxStatusTimeout = time.Second
+
+// watchSandbox keeps the socket to the init process of the sandbox, which
+// reports the exit status of the program on it, and makes the program
+// with the PID pid in the sandbox the process that throttling, timeouts,
+// statistics and state detection act on.
+// It must be called with p.mu held while the process is started.
+func (p *Process) watchSandbox(h *helperStart, pid int) {
+	p.sandboxInit = h
+	p.programPID = hostPID(p.cmd.Process.Pid, pid)
+}
+
+// finishSandbox reads the exit status of the program from the init
+// process of the sandbox and closes the socket to it. If the init process
+// was killed before reporting it, the program was killed with it and the
+// signal that terminated the init process is kept.
+// It must be called with p.mu held after the process has been reaped.
+func (p *Process) finishSandbox() {
+	h := p.sandboxInit
+	if h == nil {
+		return
+	}
+	p.sandboxInit = nil
+
+	_ = h.conn.SetDeadline(time.Now().Add(sandboxStatusTimeout)) // Ignore error - the read fails instead
+	if reply, err := h.read(); err == nil && reply != nil && reply.Status != nil {
+		p.exitSignal = nil
+		if reply.Status.Signaled() {
+			p.exitSignal = reply.Status.Signal()
+		}
+		if reply.Killed {
+			p.execKilled.Store(true)
+		}
+	}
+	_ = h.conn.Close() // Ignore error during cleanup
+}
+
+// hostPID returns the PID of the process that has the PID nsPID in the
+// PID namespace of the init process initPID, or initPID if it is not
+// found, e.g. because it already exited.
+func hostPID(initPID, nsPID int) int {
+	ns, err := os.Readlink(fmt.Sprintf("/proc/%d/ns/pid", initPID))
+	if err != nil {
+		return initPID
+	}
+	for _, pid := range namespacePids(ns) {
+		status, err := readProcStatus(pid)
+		if err != nil {
+			continue
+		}
+		// NSpid lists the PIDs from the outermost to the innermost namespace
+		ids := strings.Fields(status["NSpid"])
+		if len(ids) > 0 && ids[len(ids)-1] == strconv.Itoa(nsPID) {
+			return pid
+		}
+	}
+	return initPID
+}
+
+// signalAll delivers sig to the process and, if it runs in a sandbox, to
+// all processes in the sandbox.
+func (p *Process) signalAll(sig syscall.Signal) error {
+	if err := p.signal(sig); err != nil || p.sandbox == nil {
+		return err
+	}
+
+	// Processes forked while the first pass runs are caught by the second
+	for range 2 {
+		pids, err := p.sandboxPids()
+		if err != nil {
+			return err
+		}
+		for _, pid := range pids {
+			_ = unix.Kill(pid, sig) // Ignore error - process might already be dead
+		}
+	}
+	return nil
+}
+
+// sandboxPids returns the PIDs of the processes in the sandbox other than
+// its init process.
+func (p *Process) sandboxPids() ([]int, error) {
+	ns, err := os.Readlink(fmt.Sprintf("/proc/%d/ns/pid", p.cmd.Process.Pid))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read PID namespace: %w", err)
+	}
+	var pids []int
+	for _, pid := range namespacePids(ns) {
+		if pid != p.cmd.Process.Pid {
+			pids = append(pids, pid)
+		}
+	}
+	return pids, nil
+}
+
+// namespacePids returns the PIDs of the processes in the PID namespace
+// ns, given as the target of a /proc/<pid>/ns/pid link.
+func namespacePids(ns string) []int {
+	entries, err := os.ReadDir("/proc")
+	if err != nil {
+		return nil
+	}
+	var pids []int
+	for _, entry := range entries {
+		pid, err := strconv.Atoi(entry.Name())
+		if err != nil {
+			continue
+		}
+		if link, err := os.Readlink("/proc/" + entry.Name() + "/ns/pid"); err == nil && link == ns {
+			pids = append(pids, pid)
+		}
+	}
+	return pids
+}
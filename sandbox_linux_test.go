@@ -0,0 +1,416 @@
+//go:build linux
+
+package processctrl
+
+import (
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// testSandbox sets the ID mappings of a sandbox for the tests: the
+// defaults or, if the tests run as root, which must not be mapped, a range
+// of unprivileged host IDs.
+func testSandbox(s *Sandbox) *Sandbox {
+	if os.Geteuid() == 0 && len(s.UIDMappings) == 0 {
+		ids := []IDMap{{ContainerID: 0, HostID: 100000, Size: 2000}}
+		s.UIDMappings, s.GIDMappings = ids, ids
+	}
+	return s
+}
+
+// accessibleTempDir returns a temporary directory that the unprivileged
+// host IDs of a sandbox can read.
+func accessibleTempDir(t *testing.T) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	for _, d := range []string{filepath.Dir(dir), dir} {
+		if err := os.Chmod(d, 0o755); err != nil {
+			t.Fatalf("Chmod() failed: %v", err)
+		}
+	}
+	return dir
+}
+
+// requireSandbox skips the test if user namespaces are not available.
+func requireSandbox(t *testing.T) {
+	t.Helper()
+
+	probe := New("true")
+	if err := probe.SetSandbox(testSandbox(&Sandbox{})); err != nil {
+		t.Fatalf("SetSandbox() failed: %v", err)
+	}
+	if _, _, err := probe.Run(); err != nil {
+		t.Skipf("Sandbox not available: %v", err)
+	}
+	waitResult(t, probe)
+}
+
+// sandboxOutput runs a shell script in a sandbox and returns its output
+// lines.
+func sandboxOutput(t *testing.T, s *Sandbox, script string) []string {
+	t.Helper()
+
+	requireSandbox(t)
+	proc := New("sh", "-c", script)
+	if err := proc.SetSandbox(testSandbox(s)); err != nil {
+		t.Fatalf("SetSandbox() failed: %v", err)
+	}
+	lines, result := runCollect(t, proc)
+	if result.ExitCode() != 0 {
+		t.Fatalf("ExitCode() = %d, want 0", result.ExitCode())
+	}
+	return lines
+}
+
+func TestSandboxNamespaces(t *testing.T) {
+	lines := sandboxOutput(t, &Sandbox{Hostname: "box"},
+		`echo $PPID; hostname; id -u; tail -n +3 /proc/net/dev | cut -d: -f1 | tr -d ' '`)
+	// The shell is a child of the init process and only sees loopback
+	want := []string{"1", "box", "0", "lo"}
+	if strings.Join(lines, "\n") != strings.Join(want, "\n") {
+		t.Errorf("Output = %q, want %q", lines, want)
+	}
+
+	hostname, err := os.Hostname()
+	if err != nil {
+		t.Fatalf("Hostname() failed: %v", err)
+	}
+	if hostname == "box" {
+		t.Error("Host name of the sandbox leaked to the host")
+	}
+}
+
+func TestSandboxLoopbackUp(t *testing.T) {
+	// The kernel lists ::1 only once the interface is up
+	if _, err := os.Stat("/proc/net/if_inet6"); err != nil {
+		t.Skip("IPv6 not available")
+	}
+	lines := sandboxOutput(t, &Sandbox{}, `grep -c ' lo$' /proc/net/if_inet6`)
+	if len(lines) != 1 || lines[0] == "0" {
+		t.Errorf("Output = %q, want loopback addresses", lines)
+	}
+}
+
+func TestSandboxReadOnlyAndPrivateTmp(t *testing.T) {
+	dir := accessibleTempDir(t)
+	if err := os.WriteFile(filepath.Join(dir, "data"), []byte("hello\n"), 0o644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	target := filepath.Join(t.TempDir(), "mnt")
+
+	lines := sandboxOutput(t, &Sandbox{
+		ReadOnly:   []BindMount{{Source: dir}, {Source: dir, Target: target}},
+		PrivateTmp: true,
+	}, fmt.Sprintf(`cat %[1]s/data
+touch %[1]s/new 2>/dev/null && echo writable || echo read-only
+cat %[2]s/data
+touch /tmp/scratch && echo tmp-writable`, dir, target))
+
+	want := []string{"hello", "read-only", "hello", "tmp-writable"}
+	if strings.Join(lines, "\n") != strings.Join(want, "\n") {
+		t.Errorf("Output = %q, want %q", lines, want)
+	}
+	if _, err := os.Stat("/tmp/scratch"); err == nil {
+		t.Error("File in private /tmp visible on the host")
+	}
+	if _, err := os.Stat(target); err == nil {
+		t.Error("Mount point in private /tmp created on the host")
+	}
+}
+
+func TestSandboxReadOnlyRoot(t *testing.T) {
+	// Without a private /tmp, the host's is visible but read-only
+	dir := accessibleTempDir(t)
+	if err := os.Chmod(dir, 0o777); err != nil {
+		t.Fatalf("Chmod() failed: %v", err)
+	}
+	file := filepath.Join(dir, "file")
+	lines := sandboxOutput(t, &Sandbox{}, fmt.Sprintf("touch %s 2>&1 || true", file))
+	if len(lines) != 1 || !strings.Contains(lines[0], "Read-only file system") {
+		t.Errorf("Output = %q, want the write to fail on a read-only file system", lines)
+	}
+	if _, err := os.Stat(file); err == nil {
+		t.Error("File written outside /tmp on the host")
+	}
+
+	lines = sandboxOutput(t, &Sandbox{WritableRoot: true}, fmt.Sprintf("touch %s 2>&1", file))
+	if len(lines) != 0 {
+		t.Errorf("Output = %q, want no error with a writable root", lines)
+	}
+	if _, err := os.Stat(file); err != nil {
+		t.Errorf("File not written with a writable root: %v", err)
+	}
+}
+
+func TestSandboxRootNotMappedToHostRoot(t *testing.T) {
+	// A controller running as root needs subordinate IDs for the defaults
+	proc := New("true")
+	if err := proc.SetSandbox(&Sandbox{}); err != nil {
+		if os.Geteuid() != 0 {
+			t.Fatalf("SetSandbox() failed: %v", err)
+		}
+		return
+	}
+	for _, m := range append(proc.sandbox.UIDMappings, proc.sandbox.GIDMappings...) {
+		if m.HostID == 0 {
+			t.Errorf("Default mapping %+v maps host ID 0", m)
+		}
+	}
+}
+
+func TestDefaultIDMap(t *testing.T) {
+	u, err := user.Current()
+	if err != nil {
+		t.Skipf("Current() failed: %v", err)
+	}
+	file := filepath.Join(t.TempDir(), "subuid")
+	if err := os.WriteFile(file, []byte("other:100000:65536\n"+u.Username+":300000:65536\n"), 0o644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	if m, err := defaultIDMap(1234, file); err != nil || m != (IDMap{ContainerID: 0, HostID: 1234, Size: 1}) {
+		t.Errorf("defaultIDMap(1234) = %+v, %v, want the ID itself", m, err)
+	}
+	if m, err := defaultIDMap(0, file); err != nil || m != (IDMap{ContainerID: 0, HostID: 300000, Size: 1}) {
+		t.Errorf("defaultIDMap(0) = %+v, %v, want the subordinate ID 300000", m, err)
+	}
+	if err := os.WriteFile(file, []byte("other:100000:65536\n"), 0o644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	if m, err := defaultIDMap(0, file); err == nil {
+		t.Errorf("defaultIDMap(0) = %+v without subordinate IDs, want error", m)
+	}
+}
+
+func TestSandboxPauseResumeTerminate(t *testing.T) {
+	requireSandbox(t)
+	proc := New("sh", "-c", "sleep 30 & exec sleep 30")
+	if err := proc.SetSandbox(testSandbox(&Sandbox{})); err != nil {
+		t.Fatalf("SetSandbox() failed: %v", err)
+	}
+	startTest(t, proc)
+
+	ns, err := os.Readlink(fmt.Sprintf("/proc/%d/ns/pid", proc.PID()))
+	if err != nil {
+		t.Fatalf("Failed to read PID namespace: %v", err)
+	}
+	// init, the shell turned sleep and the background sleep
+	deadline := time.Now().Add(testTimeout * time.Second)
+	for len(namespacePids(ns)) < 3 {
+		if time.Now().After(deadline) {
+			t.Fatalf("Processes in sandbox = %v, want 3", namespacePids(ns))
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	states := func() []string {
+		var states []string
+		for _, pid := range namespacePids(ns) {
+			status := readStatus(t, fmt.Sprintf("/proc/%d/status", pid))
+			states = append(states, status["State"][:1])
+		}
+		return states
+	}
+
+	if err := proc.Pause(); err != nil {
+		t.Fatalf("Pause() failed: %v", err)
+	}
+	for _, state := range states() {
+		if state != "T" {
+			t.Errorf("States after Pause() = %v, want all stopped", states())
+			break
+		}
+	}
+	if err := proc.Resume(); err != nil {
+		t.Fatalf("Resume() failed: %v", err)
+	}
+	for _, state := range states() {
+		if state == "T" {
+			t.Errorf("States after Resume() = %v, want none stopped", states())
+			break
+		}
+	}
+
+	// SIGTERM is forwarded to the program, which does not catch it
+	start := time.Now()
+	if err := proc.Terminate(); err != nil {
+		t.Fatalf("Terminate() failed: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed >= defaultKillTimeout {
+		t.Errorf("Terminate() took %v, SIGTERM was not forwarded", elapsed)
+	}
+	if result := waitResult(t, proc); result.Signal != syscall.SIGTERM || result.ExitCode() != -1 {
+		t.Errorf("Signal = %v, ExitCode() = %d, want %v, -1", result.Signal, result.ExitCode(), syscall.SIGTERM)
+	}
+	if pids := namespacePids(ns); len(pids) != 0 {
+		t.Errorf("Processes left in sandbox: %v", pids)
+	}
+}
+
+func TestSandboxCPULimit(t *testing.T) {
+	requireSandbox(t)
+	proc := New("sh", "-c", "while :; do :; done")
+	if err := proc.SetSandbox(testSandbox(&Sandbox{})); err != nil {
+		t.Fatalf("SetSandbox() failed: %v", err)
+	}
+	if err := proc.SetCPULimit(20); err != nil {
+		t.Fatalf("SetCPULimit() failed: %v", err)
+	}
+	startTest(t, proc)
+	if proc.programPID == proc.PID() {
+		t.Fatal("Program PID is that of the init process")
+	}
+
+	// The program is throttled, not the idle init process
+	time.Sleep(time.Second)
+	if usage := measureCPU(t, proc, 2*time.Second); usage > 40 || usage < 5 {
+		t.Errorf("Expected CPU usage around 20%%, got %.1f%%", usage)
+	}
+	stats, err := proc.Stats()
+	if err != nil {
+		t.Fatalf("Stats() failed: %v", err)
+	}
+	if stats.PID != proc.programPID {
+		t.Errorf("Stats().PID = %d, want the program's %d", stats.PID, proc.programPID)
+	}
+}
+
+func TestSandboxCPUBudget(t *testing.T) {
+	requireSandbox(t)
+	proc := New("sh", "-c", "while :; do :; done")
+	if err := proc.SetSandbox(testSandbox(&Sandbox{})); err != nil {
+		t.Fatalf("SetSandbox() failed: %v", err)
+	}
+	if err := proc.SetTimeouts(Timeouts{CPU: 300 * time.Millisecond}); err != nil {
+		t.Fatalf("SetTimeouts() failed: %v", err)
+	}
+	startTest(t, proc)
+
+	if result := waitResult(t, proc); result.Reason != ExitCPUBudget || result.Signal != syscall.SIGTERM {
+		t.Errorf("Reason = %v, Signal = %v, want %v, %v", result.Reason, result.Signal, ExitCPUBudget, syscall.SIGTERM)
+	}
+}
+
+func TestSandboxCooperativePause(t *testing.T) {
+	// The init process forwards SIGTSTP, which stops sleep
+	requireSandbox(t)
+	proc := New("sh", "-c", "exec sleep 10")
+	if err := proc.SetSandbox(testSandbox(&Sandbox{})); err != nil {
+		t.Fatalf("SetSandbox() failed: %v", err)
+	}
+	if err := proc.SetCooperativePause(&CooperativePause{Timeout: testTimeout * time.Second}); err != nil {
+		t.Fatalf("SetCooperativePause() failed: %v", err)
+	}
+	startTest(t, proc)
+
+	start := time.Now()
+	if err := proc.Pause(); err != nil {
+		t.Fatalf("Pause() failed: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed >= testTimeout*time.Second {
+		t.Error("Pause() waited for the timeout although the program stopped itself")
+	}
+	if err := proc.Resume(); err != nil {
+		t.Fatalf("Resume() failed: %v", err)
+	}
+}
+
+func TestSandboxCredentialsAndCapabilities(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("Mapping other users requires root")
+	}
+
+	ids := []IDMap{{ContainerID: 0, HostID: 100000, Size: 2000}}
+	requireSandbox(t)
+	proc := New("sh", "-c", `id -u; id -g; grep -E '^Cap(Amb|Bnd)' /proc/self/status | cut -f2`)
+	if err := proc.SetSandbox(&Sandbox{UIDMappings: ids, GIDMappings: ids}); err != nil {
+		t.Fatalf("SetSandbox() failed: %v", err)
+	}
+	if err := proc.SetCredentials(&Credentials{User: "1000", Group: "1000"}); err != nil {
+		t.Fatalf("SetCredentials() failed: %v", err)
+	}
+	if err := proc.SetCapabilities(&Capabilities{
+		Bounding: []Capability{CapNetBindService},
+		Ambient:  []Capability{CapNetBindService},
+	}); err != nil {
+		t.Fatalf("SetCapabilities() failed: %v", err)
+	}
+
+	lines, result := runCollect(t, proc)
+	if result.ExitCode() != 0 {
+		t.Fatalf("ExitCode() = %d, want 0", result.ExitCode())
+	}
+	caps := capMask(CapNetBindService)
+	want := []string{"1000", "1000", caps, caps}
+	if strings.Join(lines, "\n") != strings.Join(want, "\n") {
+		t.Errorf("Output = %q, want %q", lines, want)
+	}
+}
+
+func TestSandboxRlimit(t *testing.T) {
+	requireSandbox(t)
+	proc := New("sh", "-c", "ulimit -n")
+	if err := proc.SetSandbox(testSandbox(&Sandbox{})); err != nil {
+		t.Fatalf("SetSandbox() failed: %v", err)
+	}
+	// The limit is too low for the init process
+	if err := proc.SetRlimit(RlimitNOFILE, Rlimit{Cur: 4, Max: 4}); err != nil {
+		t.Fatalf("SetRlimit() failed: %v", err)
+	}
+
+	lines, result := runCollect(t, proc)
+	if strings.Join(lines, " ") != "4" || result.ExitCode() != 0 {
+		t.Errorf("Output = %q, ExitCode() = %d, want limit 4 and exit code 0", lines, result.ExitCode())
+	}
+}
+
+func TestSandboxUnmappedCredentials(t *testing.T) {
+	proc := New("true")
+	if err := proc.SetSandbox(testSandbox(&Sandbox{})); err != nil {
+		t.Fatalf("SetSandbox() failed: %v", err)
+	}
+	if err := proc.SetCredentials(&Credentials{User: "12345", Group: "0"}); err != nil {
+		t.Fatalf("SetCredentials() failed: %v", err)
+	}
+	if _, _, err := proc.Run(); err == nil || !strings.Contains(err.Error(), "not mapped") {
+		t.Errorf("Run() error = %v, want unmapped user", err)
+	}
+}
+
+func TestSetSandboxInvalid(t *testing.T) {
+	proc := New("true")
+	for _, s := range []*Sandbox{
+		{UIDMappings: []IDMap{{ContainerID: 0, HostID: 0, Size: 0}}},
+		{UIDMappings: []IDMap{{ContainerID: 0, HostID: 0, Size: 1}}},
+		{GIDMappings: []IDMap{{ContainerID: 0, HostID: 0, Size: 65536}}},
+		{UIDMappings: []IDMap{{ContainerID: 1000, HostID: 0, Size: 1}}},
+		{GIDMappings: []IDMap{{ContainerID: -1, HostID: 0, Size: 1}}},
+		{Hostname: strings.Repeat("x", 65)},
+		{ReadOnly: []BindMount{{Source: "relative"}}},
+		{ReadOnly: []BindMount{{Source: "/no-such-path-processctrl"}}},
+	} {
+		if err := proc.SetSandbox(s); err == nil {
+			t.Errorf("SetSandbox(%+v) succeeded, want error", s)
+		}
+	}
+}
+
+func TestUnescapeMountPath(t *testing.T) {
+	for path, want := range map[string]string{
+		"/":                 "/",
+		`/mnt/a\040b`:       "/mnt/a b",
+		`/mnt/back\134rest`: `/mnt/back\rest`,
+		`/mnt/short\04`:     `/mnt/short\04`,
+	} {
+		if got := unescapeMountPath(path); got != want {
+			t.Errorf("unescapeMountPath(%q) = %q, want %q", path, got, want)
+		}
+	}
+}
@@ -0,0 +1,26 @@
+//go:build !linux
+
+// Package processctrl sandbox stub
+//
+// Sandboxes are built from Linux namespaces. On other platforms
+// SetSandbox returns ErrNotSupported.
+
+package processctrl
+
+import "syscall"
+
+// sandboxSupported reports whether SetSandbox is available.
+const sandboxSupported = false
+
+// prepareSandbox is a no-op on platforms without sandboxes.
+func (p *Process) prepareSandbox() error {
+	return nil
+}
+
+// signalAll delivers sig to the process.
+func (p *Process) signalAll(sig syscall.Signal) error {
+	return p.signal(sig)
+}
+
+// finishSandbox is a no-op on platforms without sandboxes.
+func (p *Process) finishSandbox() {}
@@ -0,0 +1,279 @@
+package processctrl
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// scheduleHorizons are the successively longer periods searched for the
+// next transition of a schedule. Most schedules repeat weekly, but cron
+// windows restricted to certain months or days of the month may not.
+var scheduleHorizons = []time.Duration{
+	8 * 24 * time.Hour,
+	32 * 24 * time.Hour,
+	367 * 24 * time.Hour,
+}
+
+// scheduleLookback is how far before a point in time windows are searched
+// for an occurrence that is still in progress.
+const scheduleLookback = 8 * 24 * time.Hour
+
+// Window describes a set of recurring time intervals, evaluated in the
+// location of the Schedule it belongs to. Windows are created with
+// ParseTimeWindow, ParseCronWindow or as TimeWindow literals.
+type Window interface {
+	// occurrences returns the intervals of the window that overlap
+	// [from, to) when evaluated in loc.
+	occurrences(from, to time.Time, loc *time.Location) []interval
+}
+
+// interval is a half-open time interval [start, end).
+type interval struct {
+	start, end time.Time
+}
+
+// Schedule is a set of allowed windows during which a process may run.
+// Outside of all windows the process is kept paused by a Scheduler.
+//
+// Windows are defined in wall-clock time of Location. On days with a
+// daylight saving time transition, window boundaries that fall into the
+// skipped hour are moved forward by the length of the gap, and boundaries
+// in the repeated hour are resolved by time.Date, which picks one of its
+// two occurrences. Windows therefore never disappear or repeat on such
+// days; they only become shorter or longer.
+type Schedule struct {
+	Location *time.Location // Time zone of the windows; defaults to time.Local
+	Windows  []Window       // Allowed windows
+}
+
+// location returns the schedule's time zone.
+func (s *Schedule) location() *time.Location {
+	if s.Location == nil {
+		return time.Local
+	}
+	return s.Location
+}
+
+// merged returns the merged, sorted occurrences of all windows overlapping
+// [from, to).
+func (s *Schedule) merged(from, to time.Time) []interval {
+	loc := s.location()
+
+	var all []interval
+	for _, w := range s.Windows {
+		for _, iv := range w.occurrences(from, to, loc) {
+			if iv.end.After(from) && iv.start.Before(to) && iv.end.After(iv.start) {
+				all = append(all, iv)
+			}
+		}
+	}
+
+	sort.Slice(all, func(i, j int) bool {
+		return all[i].start.Before(all[j].start)
+	})
+
+	var merged []interval
+	for _, iv := range all {
+		if n := len(merged); n > 0 && !iv.start.After(merged[n-1].end) {
+			if iv.end.After(merged[n-1].end) {
+				merged[n-1].end = iv.end
+			}
+			continue
+		}
+		merged = append(merged, iv)
+	}
+	return merged
+}
+
+// Active reports whether t falls inside one of the schedule's windows.
+func (s *Schedule) Active(t time.Time) bool {
+	for _, iv := range s.merged(t.Add(-scheduleLookback), t.Add(time.Minute)) {
+		if !t.Before(iv.start) && t.Before(iv.end) {
+			return true
+		}
+	}
+	return false
+}
+
+// NextTransition returns the first time after t at which the schedule
+// switches between active and inactive. The boolean is false if there is
+// no transition within the next year, i.e. the schedule is always or
+// never active.
+func (s *Schedule) NextTransition(t time.Time) (time.Time, bool) {
+	for _, horizon := range scheduleHorizons {
+		end := t.Add(horizon)
+		for _, iv := range s.merged(t.Add(-scheduleLookback), end) {
+			switch {
+			case iv.start.After(t):
+				return iv.start, true
+			case iv.end.After(t) && iv.end.Before(end):
+				return iv.end, true
+			}
+		}
+	}
+	return time.Time{}, false
+}
+
+// TimeWindow is a daily time range on selected weekdays, such as
+// "Mon-Fri 22:00-06:00". If End is not after Start, the window extends
+// past midnight into the following day; a window that starts at 22:00 on
+// Friday ends at 06:00 on Saturday.
+type TimeWindow struct {
+	Days  []time.Weekday // Days on which the window starts; empty means every day
+	Start time.Duration  // Start as offset from midnight
+	End   time.Duration  // End as offset from midnight, up to 24h
+}
+
+// occurrences implements Window.
+func (w TimeWindow) occurrences(from, to time.Time, loc *time.Location) []interval {
+	var out []interval
+
+	// Start one day early to include windows extending past midnight
+	day := from.In(loc).AddDate(0, 0, -1)
+	y, m, d := day.Date()
+	for i := 0; ; i++ {
+		date := time.Date(y, m, d+i, 0, 0, 0, 0, loc)
+		if !date.Before(to) {
+			break
+		}
+		if !w.matchesDay(date.Weekday()) {
+			continue
+		}
+
+		start := wallClock(y, m, d+i, w.Start, loc)
+		endDay := d + i
+		if w.End <= w.Start {
+			endDay++
+		}
+		out = append(out, interval{start: start, end: wallClock(y, m, endDay, w.End, loc)})
+	}
+	return out
+}
+
+// matchesDay reports whether the window starts on the given weekday.
+func (w TimeWindow) matchesDay(day time.Weekday) bool {
+	if len(w.Days) == 0 {
+		return true
+	}
+	for _, d := range w.Days {
+		if d == day {
+			return true
+		}
+	}
+	return false
+}
+
+// wallClock returns the time at the given offset from midnight of a day,
+// interpreted as wall-clock time in loc.
+func wallClock(y int, m time.Month, d int, offset time.Duration, loc *time.Location) time.Time {
+	h := int(offset / time.Hour)
+	minute := int(offset % time.Hour / time.Minute)
+	return time.Date(y, m, d, h, minute, 0, 0, loc)
+}
+
+// ParseTimeWindow parses a weekday and time range specification.
+//
+// The format is "[DAYS] HH:MM-HH:MM", where DAYS is a comma-separated list
+// of weekdays or weekday ranges using three-letter English names, for
+// example "Mon-Fri 08:00-18:00", "Sat,Sun 00:00-24:00" or "22:00-06:00"
+// (every day).
+func ParseTimeWindow(spec string) (TimeWindow, error) {
+	fields := strings.Fields(spec)
+
+	var w TimeWindow
+	switch len(fields) {
+	case 1:
+		// Every day
+	case 2:
+		days, err := parseWeekdays(fields[0])
+		if err != nil {
+			return TimeWindow{}, fmt.Errorf("invalid time window %q: %w", spec, err)
+		}
+		w.Days = days
+	default:
+		return TimeWindow{}, fmt.Errorf("invalid time window %q", spec)
+	}
+
+	startSpec, endSpec, ok := strings.Cut(fields[len(fields)-1], "-")
+	if !ok {
+		return TimeWindow{}, fmt.Errorf("invalid time window %q: missing time range", spec)
+	}
+
+	var err error
+	if w.Start, err = parseClock(startSpec); err != nil {
+		return TimeWindow{}, fmt.Errorf("invalid time window %q: %w", spec, err)
+	}
+	if w.End, err = parseClock(endSpec); err != nil {
+		return TimeWindow{}, fmt.Errorf("invalid time window %q: %w", spec, err)
+	}
+	if w.Start == 24*time.Hour {
+		return TimeWindow{}, fmt.Errorf("invalid time window %q: start cannot be 24:00", spec)
+	}
+	return w, nil
+}
+
+// weekdayNames maps three-letter English weekday names to weekdays.
+var weekdayNames = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+// parseWeekdays parses a list such as "Mon-Fri" or "Mon,Wed,Sat-Sun".
+// Ranges wrap around the end of the week, so "Fri-Mon" is valid.
+func parseWeekdays(spec string) ([]time.Weekday, error) {
+	var days []time.Weekday
+	seen := make(map[time.Weekday]bool)
+
+	for _, part := range strings.Split(spec, ",") {
+		first, last, isRange := strings.Cut(part, "-")
+		from, ok := weekdayNames[strings.ToLower(first)]
+		if !ok {
+			return nil, fmt.Errorf("unknown weekday %q", first)
+		}
+		to := from
+		if isRange {
+			if to, ok = weekdayNames[strings.ToLower(last)]; !ok {
+				return nil, fmt.Errorf("unknown weekday %q", last)
+			}
+		}
+
+		for d := from; ; d = (d + 1) % 7 {
+			if !seen[d] {
+				seen[d] = true
+				days = append(days, d)
+			}
+			if d == to {
+				break
+			}
+		}
+	}
+	return days, nil
+}
+
+// parseClock parses a time of day in HH:MM format, allowing 24:00.
+func parseClock(spec string) (time.Duration, error) {
+	hs, ms, ok := strings.Cut(spec, ":")
+	if !ok {
+		return 0, fmt.Errorf("invalid time %q", spec)
+	}
+	h, err := strconv.Atoi(hs)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q", spec)
+	}
+	m, err := strconv.Atoi(ms)
+	if err != nil || len(ms) != 2 {
+		return 0, fmt.Errorf("invalid time %q", spec)
+	}
+	if h < 0 || h > 24 || m < 0 || m > 59 || (h == 24 && m != 0) {
+		return 0, fmt.Errorf("invalid time %q", spec)
+	}
+	return time.Duration(h)*time.Hour + time.Duration(m)*time.Minute, nil
+}
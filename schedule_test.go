@@ -0,0 +1,284 @@
+package processctrl
+
+import (
+	"testing"
+	"time"
+)
+
+// intervalWindow is a Window with fixed occurrences, for testing.
+type intervalWindow []interval
+
+func (w intervalWindow) occurrences(_, _ time.Time, _ *time.Location) []interval {
+	return w
+}
+
+func loadLocation(t *testing.T, name string) *time.Location {
+	t.Helper()
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		t.Skipf("time zone %s not available: %v", name, err)
+	}
+	return loc
+}
+
+func mustTimeWindow(t *testing.T, spec string) TimeWindow {
+	t.Helper()
+	w, err := ParseTimeWindow(spec)
+	if err != nil {
+		t.Fatalf("ParseTimeWindow(%q) failed: %v", spec, err)
+	}
+	return w
+}
+
+func TestParseTimeWindow(t *testing.T) {
+	tests := []struct {
+		spec    string
+		days    int
+		start   time.Duration
+		end     time.Duration
+		wantErr bool
+	}{
+		{spec: "Mon-Fri 08:00-18:00", days: 5, start: 8 * time.Hour, end: 18 * time.Hour},
+		{spec: "sat,sun 00:00-24:00", days: 2, start: 0, end: 24 * time.Hour},
+		{spec: "22:30-06:00", days: 0, start: 22*time.Hour + 30*time.Minute, end: 6 * time.Hour},
+		{spec: "Fri-Mon 01:00-02:00", days: 4, start: time.Hour, end: 2 * time.Hour},
+		{spec: "Mon-Fri", wantErr: true},
+		{spec: "Foo 08:00-18:00", wantErr: true},
+		{spec: "08:00-25:00", wantErr: true},
+		{spec: "24:00-06:00", wantErr: true},
+		{spec: "8-18", wantErr: true},
+		{spec: "Mon 08:00-18:00 extra", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			w, err := ParseTimeWindow(tt.spec)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("Expected error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseTimeWindow() failed: %v", err)
+			}
+			if len(w.Days) != tt.days || w.Start != tt.start || w.End != tt.end {
+				t.Errorf("Got %+v", w)
+			}
+		})
+	}
+}
+
+func TestScheduleActiveAndNextTransition(t *testing.T) {
+	loc := loadLocation(t, "Europe/Zurich")
+	sched := &Schedule{
+		Location: loc,
+		Windows:  []Window{mustTimeWindow(t, "Mon-Fri 18:00-08:00"), mustTimeWindow(t, "Sat,Sun 00:00-24:00")},
+	}
+
+	tests := []struct {
+		name   string
+		at     time.Time
+		active bool
+		next   time.Time
+	}{
+		{
+			name:   "weekday business hours",
+			at:     time.Date(2025, 6, 4, 12, 0, 0, 0, loc), // Wednesday
+			active: false,
+			next:   time.Date(2025, 6, 4, 18, 0, 0, 0, loc),
+		},
+		{
+			name:   "weekday night",
+			at:     time.Date(2025, 6, 4, 23, 0, 0, 0, loc),
+			active: true,
+			next:   time.Date(2025, 6, 5, 8, 0, 0, 0, loc),
+		},
+		{
+			name:   "weekend merges with Friday night",
+			at:     time.Date(2025, 6, 6, 20, 0, 0, 0, loc), // Friday
+			active: true,
+			next:   time.Date(2025, 6, 9, 0, 0, 0, 0, loc), // End of Sunday
+		},
+		{
+			name:   "exact window start",
+			at:     time.Date(2025, 6, 4, 18, 0, 0, 0, loc),
+			active: true,
+			next:   time.Date(2025, 6, 5, 8, 0, 0, 0, loc),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := sched.Active(tt.at); got != tt.active {
+				t.Errorf("Active() = %v, want %v", got, tt.active)
+			}
+			next, ok := sched.NextTransition(tt.at)
+			if !ok || !next.Equal(tt.next) {
+				t.Errorf("NextTransition() = %v, %v, want %v", next, ok, tt.next)
+			}
+		})
+	}
+}
+
+func TestScheduleDST(t *testing.T) {
+	loc := loadLocation(t, "Europe/Zurich")
+
+	// On 2025-03-30 clocks jump from 02:00 to 03:00 in Zurich, so a
+	// window starting at 02:30 starts at 03:30 CEST instead.
+	sched := &Schedule{Location: loc, Windows: []Window{mustTimeWindow(t, "Sun 02:30-04:00")}}
+	next, ok := sched.NextTransition(time.Date(2025, 3, 30, 0, 0, 0, 0, loc))
+	if want := time.Date(2025, 3, 30, 3, 30, 0, 0, loc); !ok || !next.Equal(want) {
+		t.Errorf("NextTransition() across spring forward = %v, want %v", next, want)
+	}
+
+	// A window from midnight to 03:00 on the day clocks fall back
+	// (2025-10-26) lasts four hours of real time.
+	sched = &Schedule{Location: loc, Windows: []Window{mustTimeWindow(t, "Sun 00:00-03:00")}}
+	start := time.Date(2025, 10, 26, 0, 0, 0, 0, loc)
+	next, ok = sched.NextTransition(start)
+	if !ok || next.Sub(start) != 4*time.Hour {
+		t.Errorf("NextTransition() across fall back = %v (after %v), want 4h", next, next.Sub(start))
+	}
+}
+
+func TestScheduleAlwaysActive(t *testing.T) {
+	sched := &Schedule{Windows: []Window{mustTimeWindow(t, "00:00-24:00")}}
+	now := time.Now()
+	if !sched.Active(now) {
+		t.Error("Schedule should always be active")
+	}
+	if _, ok := sched.NextTransition(now); ok {
+		t.Error("Always-active schedule should have no transition")
+	}
+}
+
+func TestParseCronWindow(t *testing.T) {
+	loc := loadLocation(t, "America/New_York")
+
+	w, err := ParseCronWindow("0 22 * * mon-fri", 8*time.Hour)
+	if err != nil {
+		t.Fatalf("ParseCronWindow() failed: %v", err)
+	}
+	sched := &Schedule{Location: loc, Windows: []Window{w}}
+
+	friday := time.Date(2025, 6, 6, 23, 0, 0, 0, loc)
+	if !sched.Active(friday) {
+		t.Error("Schedule should be active on Friday night")
+	}
+	next, ok := sched.NextTransition(friday)
+	if want := time.Date(2025, 6, 7, 6, 0, 0, 0, loc); !ok || !next.Equal(want) {
+		t.Errorf("NextTransition() = %v, want %v", next, want)
+	}
+	next, ok = sched.NextTransition(next)
+	if want := time.Date(2025, 6, 9, 22, 0, 0, 0, loc); !ok || !next.Equal(want) {
+		t.Errorf("NextTransition() = %v, want %v", next, want)
+	}
+
+	// Both day fields restricted: the 1st of the month or any Sunday
+	w, err = ParseCronWindow("30 3 1 * 7", time.Hour)
+	if err != nil {
+		t.Fatalf("ParseCronWindow() failed: %v", err)
+	}
+	sched = &Schedule{Location: loc, Windows: []Window{w}}
+	next, ok = sched.NextTransition(time.Date(2025, 6, 2, 0, 0, 0, 0, loc)) // Monday
+	if want := time.Date(2025, 6, 8, 3, 30, 0, 0, loc); !ok || !next.Equal(want) {
+		t.Errorf("NextTransition() = %v, want %v", next, want)
+	}
+
+	for _, spec := range []string{"* * * *", "60 * * * *", "* * * * 8", "*/0 * * * *", "5-1 * * * *"} {
+		if _, err := ParseCronWindow(spec, time.Hour); err == nil {
+			t.Errorf("ParseCronWindow(%q) should fail", spec)
+		}
+	}
+	if _, err := ParseCronWindow("* * * * *", 0); err == nil {
+		t.Error("ParseCronWindow() should reject a zero duration")
+	}
+}
+
+func TestSchedulerPausesOutsideWindows(t *testing.T) {
+	proc := newLongRunning()
+	startTest(t, proc)
+
+	now := time.Now()
+	window := intervalWindow{{start: now.Add(300 * time.Millisecond), end: now.Add(600 * time.Millisecond)}}
+	sched, err := NewScheduler(proc, &Schedule{Windows: []Window{window}})
+	if err != nil {
+		t.Fatalf("NewScheduler() failed: %v", err)
+	}
+	if err := sched.Start(); err != nil {
+		t.Fatalf("Start() failed: %v", err)
+	}
+
+	expectEvents(t, proc.Events(), EventPaused, EventResumed, EventPaused)
+	if next, ok := sched.NextTransition(); ok {
+		t.Errorf("Expected no further transition, got %v", next)
+	}
+
+	if err := sched.Stop(); err != nil {
+		t.Fatalf("Stop() failed: %v", err)
+	}
+	if proc.IsPaused() {
+		t.Error("Stop() should resume a process paused by the scheduler")
+	}
+	if err := sched.Stop(); err == nil {
+		t.Error("Stop() should fail on stopped scheduler")
+	}
+}
+
+// slowController is a Controller whose Pause blocks until released.
+type slowController struct {
+	Controller
+	pausing chan struct{}
+	release chan struct{}
+}
+
+func (c *slowController) Pause() error {
+	close(c.pausing)
+	<-c.release
+	return nil
+}
+
+func (c *slowController) IsPaused() bool        { return false }
+func (c *slowController) IsRunning() bool       { return false }
+func (c *slowController) Done() <-chan struct{} { return nil }
+
+func TestSchedulerSlowPause(t *testing.T) {
+	ctrl := &slowController{pausing: make(chan struct{}), release: make(chan struct{})}
+	start := time.Now().Add(time.Hour)
+	window := intervalWindow{{start: start, end: start.Add(time.Hour)}}
+	sched, err := NewScheduler(ctrl, &Schedule{Windows: []Window{window}})
+	if err != nil {
+		t.Fatalf("NewScheduler() failed: %v", err)
+	}
+	if err := sched.Start(); err != nil {
+		t.Fatalf("Start() failed: %v", err)
+	}
+	<-ctrl.pausing
+
+	// Queries must not wait for the pause to complete
+	got := make(chan time.Time)
+	go func() {
+		next, _ := sched.NextTransition()
+		got <- next
+	}()
+	select {
+	case next := <-got:
+		if !next.Equal(start) {
+			t.Errorf("NextTransition() = %v, want %v", next, start)
+		}
+	case <-time.After(testTimeout * time.Second):
+		t.Fatal("NextTransition() blocked while pausing")
+	}
+
+	close(ctrl.release)
+	if err := sched.Stop(); err != nil {
+		t.Fatalf("Stop() failed: %v", err)
+	}
+}
+
+func TestNewSchedulerEmpty(t *testing.T) {
+	if _, err := NewScheduler(New("echo"), &Schedule{}); err == nil {
+		t.Error("NewScheduler() should reject an empty schedule")
+	}
+}
@@ -0,0 +1,170 @@
+package processctrl
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// schedulerRecheckInterval bounds how long a Scheduler sleeps between
+// evaluations, so that wall-clock changes (e.g. after a system suspend or
+// an NTP correction) are picked up promptly.
+const schedulerRecheckInterval = time.Minute
+
+// Scheduler pauses and resumes a process according to a Schedule of
+// allowed windows. The process is paused when it leaves an allowed window
+// and resumed when it enters one.
+//
+// The scheduler only acts on transitions, so manual Pause and Resume calls
+// in between are respected until the next transition. It never resumes a
+// process that it did not pause itself.
+type Scheduler struct {
+	ctrl     Controller
+	schedule *Schedule
+	mu       sync.Mutex
+	running  bool
+	paused   bool // the scheduler paused the process
+	next     time.Time
+	hasNext  bool
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+// NewScheduler creates a scheduler that controls ctrl according to the
+// given schedule. The scheduler is not active until Start is called.
+//
+// Parameters:
+//   - ctrl: The process to control, e.g. a *Process or *AttachedProcess
+//   - schedule: The allowed windows; must contain at least one window
+//
+// Returns an error if the schedule is empty.
+func NewScheduler(ctrl Controller, schedule *Schedule) (*Scheduler, error) {
+	if schedule == nil || len(schedule.Windows) == 0 {
+		return nil, fmt.Errorf("schedule has no windows")
+	}
+	return &Scheduler{ctrl: ctrl, schedule: schedule}, nil
+}
+
+// Start evaluates the schedule immediately, pausing the process if it is
+// outside of all allowed windows, and then keeps it in sync with the
+// schedule until Stop is called or the process exits.
+//
+// Returns an error if the scheduler is already running.
+func (s *Scheduler) Start() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.running {
+		return fmt.Errorf("scheduler already running")
+	}
+	s.running = true
+	s.stop = make(chan struct{})
+	s.done = make(chan struct{})
+
+	go s.run(s.stop, s.done)
+	return nil
+}
+
+// Stop ends scheduling. If the scheduler paused the process, it is resumed
+// so that it is not left paused without anyone to resume it.
+//
+// Returns an error if the scheduler is not running or resuming fails.
+func (s *Scheduler) Stop() error {
+	s.mu.Lock()
+	if !s.running {
+		s.mu.Unlock()
+		return fmt.Errorf("scheduler not running")
+	}
+	s.running = false
+	close(s.stop)
+	done := s.done
+	s.mu.Unlock()
+
+	<-done
+
+	s.mu.Lock()
+	s.hasNext = false
+	resume := s.paused
+	s.paused = false
+	s.mu.Unlock()
+
+	if resume && s.ctrl.IsRunning() && s.ctrl.IsPaused() {
+		return s.ctrl.Resume()
+	}
+	return nil
+}
+
+// NextTransition returns the time at which the scheduler will next pause
+// or resume the process. The boolean is false if the scheduler is not
+// running or the schedule has no transition within the next year.
+func (s *Scheduler) NextTransition() (time.Time, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.next, s.hasNext
+}
+
+// run is the scheduling loop.
+func (s *Scheduler) run(stop, done chan struct{}) {
+	defer close(done)
+
+	first := true
+	var wasActive bool
+	for {
+		now := time.Now()
+		active := s.schedule.Active(now)
+		next, hasNext := s.schedule.NextTransition(now)
+
+		s.mu.Lock()
+		s.next, s.hasNext = next, hasNext
+		s.mu.Unlock()
+		if first || active != wasActive {
+			s.apply(active)
+		}
+
+		first = false
+		wasActive = active
+
+		wait := schedulerRecheckInterval
+		if hasNext {
+			if until := time.Until(next); until < wait {
+				wait = until
+			}
+		}
+
+		select {
+		case <-stop:
+			return
+		case <-s.ctrl.Done():
+			return
+		case <-time.After(wait):
+		}
+	}
+}
+
+// apply pauses or resumes the process for the given schedule state.
+// Errors are ignored, since the process may have been paused, resumed or
+// terminated concurrently; the next transition is applied regardless.
+// The action is decided with s.mu held, but Pause and Resume, which can
+// take a while, are called after releasing it.
+func (s *Scheduler) apply(active bool) {
+	s.mu.Lock()
+	pause := !active && !s.ctrl.IsPaused()
+	resume := active && s.paused
+	if resume {
+		s.paused = false
+	}
+	s.mu.Unlock()
+
+	switch {
+	case pause:
+		if s.ctrl.Pause() == nil {
+			s.mu.Lock()
+			s.paused = true
+			s.mu.Unlock()
+		}
+	case resume:
+		if s.ctrl.IsPaused() {
+			_ = s.ctrl.Resume() // Ignore error - process might have exited
+		}
+	}
+}
@@ -0,0 +1,148 @@
+package processctrl
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// maxScriptErrors limits the script errors recorded for a run.
+const maxScriptErrors = 100
+
+// ScriptError is a message of the interpreter of a script that refers to
+// a line of the script.
+type ScriptError struct {
+	Line    int    // Line of the script, starting at 1
+	Message string // Line of standard error that refers to the script
+}
+
+// script is the source of a process created with NewScript.
+type script struct {
+	file    *os.File       // Source in a memfd passed as scriptFD, nil for a temporary file or once released
+	path    string         // Path the interpreter reads the source from
+	dir     string         // Temporary directory of the source, if any
+	pattern *regexp.Regexp // Matches references to lines of the source
+	errors  []ScriptError
+}
+
+// NewScript creates a new Process that runs the script source with the
+// interpreter, for example "sh" or "python3", passing args to the script.
+// The interpreter is looked up like the program of New. If interpreter is
+// empty, it is taken from the shebang line of the script, e.g.
+// "#!/usr/bin/env python3"; the line is split into fields, so it may
+// contain several arguments.
+//
+// The source is never quoted or passed on a command line: the interpreter
+// gets a path to read it from as its first argument. On Linux, the source
+// is stored in a sealed memfd that the process inherits, and the path
+// refers to it through /proc/self/fd, which works in sandboxes and with
+// other credentials. Otherwise it is a temporary file that only the
+// controller's user can read. Either is released when the process exits.
+// Standard input stays available to the script.
+//
+// Messages on standard error that refer to lines of the script, like
+// "line 3" from sh and bash or "File ..., line 3" from Python, are
+// reported in ExitResult.ScriptErrors.
+//
+// Returns an error if no interpreter is given and the script has no
+// shebang line, or the source cannot be stored.
+func NewScript(interpreter, source string, args ...string) (*Process, error) {
+	var interpreterArgs []string
+	if interpreter == "" {
+		fields := shebang(source)
+		if len(fields) == 0 {
+			return nil, fmt.Errorf("no interpreter given and the script has no shebang line")
+		}
+		interpreter, interpreterArgs = fields[0], fields[1:]
+	}
+
+	s, err := openScript(source)
+	if err != nil {
+		return nil, err
+	}
+	s.pattern = scriptPattern(s.path)
+
+	argv := append(append(interpreterArgs, s.path), args...)
+	p := New(interpreter, argv...)
+	p.script = s
+	return p, nil
+}
+
+// shebang returns the fields of the shebang line of source, or nil if it
+// has none.
+func shebang(source string) []string {
+	if !strings.HasPrefix(source, "#!") {
+		return nil
+	}
+	line, _, _ := strings.Cut(source[2:], "\n")
+	return strings.Fields(line)
+}
+
+// scriptPattern matches the references to lines of the script at path in
+// the messages of common interpreters, e.g. "path: line 3" from bash,
+// "path: 3" from dash, "File "path", line 3" from Python, "path line 3"
+// from Perl and "path:3" from Ruby and Node.js.
+func scriptPattern(path string) *regexp.Regexp {
+	return regexp.MustCompile(regexp.QuoteMeta(path) + `(?:", line | line |: line |:\s*)(\d+)`)
+}
+
+// tempScript stores source in a private temporary file.
+func tempScript(source string) (*script, error) {
+	dir, err := os.MkdirTemp("", "processctrl-script-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to store script: %w", err)
+	}
+	path := filepath.Join(dir, "script")
+	if err := os.WriteFile(path, []byte(source), 0o600); err != nil {
+		_ = os.RemoveAll(dir) // Ignore error during cleanup
+		return nil, fmt.Errorf("failed to store script: %w", err)
+	}
+	return &script{path: path, dir: dir}, nil
+}
+
+// scan records line if it refers to a line of the script.
+func (s *script) scan(line string) {
+	if len(s.errors) >= maxScriptErrors {
+		return
+	}
+	m := s.pattern.FindStringSubmatch(line)
+	if m == nil {
+		return
+	}
+	n, err := strconv.Atoi(m[1])
+	if err != nil || n < 1 {
+		return
+	}
+	s.errors = append(s.errors, ScriptError{Line: n, Message: line})
+}
+
+// releaseScript closes the memfd or removes the temporary file of the
+// script, so that neither is kept until garbage collection.
+// It must be called with p.mu held after the process has exited.
+func (p *Process) releaseScript() {
+	if p.script == nil {
+		return
+	}
+	if p.script.file != nil {
+		_ = p.script.file.Close() // Ignore error - the source is not needed anymore
+		p.script.file = nil
+	}
+	if p.script.dir != "" {
+		_ = os.RemoveAll(p.script.dir) // Ignore error - the file is not needed anymore
+		p.script.dir = ""
+	}
+}
+
+// scriptErrors returns the script errors of the run found so far.
+// It must be called with p.mu held.
+func (p *Process) scriptErrors() []ScriptError {
+	if p.script == nil {
+		return nil
+	}
+	// Errors scanned later must not be appended to the returned slice
+	return slices.Clip(p.script.errors)
+}
@@ -0,0 +1,45 @@
+//go:build linux
+
+// Package processctrl Linux script sources
+//
+// Script sources are stored in memfds, which processes inherit as
+// scriptFD, so the interpreter can open the source through /proc without
+// a file on disk. Kernels without memfd_create fall back to temporary
+// files.
+
+package processctrl
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+
+	"golang.org/x/sys/unix"
+)
+
+// openScript stores source for the interpreter.
+func openScript(source string) (*script, error) {
+	fd, err := unix.MemfdCreate("processctrl-script", unix.MFD_CLOEXEC|unix.MFD_ALLOW_SEALING)
+	if err != nil {
+		return tempScript(source)
+	}
+
+	f := os.NewFile(uintptr(fd), "memfd:processctrl-script")
+	if _, err := f.WriteString(source); err != nil {
+		_ = f.Close() // Ignore error during cleanup
+		return nil, fmt.Errorf("failed to store script: %w", err)
+	}
+	if _, err := unix.FcntlInt(uintptr(fd), unix.F_ADD_SEALS, imageSeals); err != nil {
+		_ = f.Close() // Ignore error during cleanup
+		return nil, fmt.Errorf("failed to seal script: %w", err)
+	}
+	return &script{file: f, path: "/proc/self/fd/" + strconv.Itoa(scriptFD)}, nil
+}
+
+// prepareScript passes the memfd of the script to the process.
+// It must be called with p.mu held before the command is started.
+func (p *Process) prepareScript() {
+	if p.script != nil && p.script.file != nil {
+		setExtraFile(p.cmd, scriptFD, p.script.file)
+	}
+}
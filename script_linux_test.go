@@ -0,0 +1,164 @@
+//go:build linux
+
+package processctrl
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+func TestNewScriptPassesSourceVerbatim(t *testing.T) {
+	source := `printf '%s\n' "it's \"quoted\" \$HOME" "$1" "$#"
+read -r line
+echo "input: $line"
+`
+	proc, err := NewScript("sh", source, "a b; rm -rf /", "c")
+	if err != nil {
+		t.Fatalf("NewScript() failed: %v", err)
+	}
+	stdout, stderr, err := proc.Run()
+	if err != nil {
+		t.Fatalf("Run() failed: %v", err)
+	}
+	go func() {
+		for range stderr {
+		}
+	}()
+	if err := proc.WriteString("hello\n"); err != nil {
+		t.Fatalf("WriteString() failed: %v", err)
+	}
+
+	var lines []string
+	for line := range stdout {
+		lines = append(lines, line)
+	}
+	want := []string{`it's "quoted" $HOME`, "a b; rm -rf /", "2", "input: hello"}
+	if strings.Join(lines, "\n") != strings.Join(want, "\n") {
+		t.Errorf("Output = %q, want %q", lines, want)
+	}
+}
+
+func TestNewScriptShebang(t *testing.T) {
+	proc, err := NewScript("", "#!/bin/sh -e\necho before\nfalse\necho after\n")
+	if err != nil {
+		t.Fatalf("NewScript() failed: %v", err)
+	}
+	lines, result := runCollect(t, proc)
+	if strings.Join(lines, "\n") != "before" || result.ExitCode() != 1 {
+		t.Errorf("Output = %q, ExitCode() = %d, want before, 1", lines, result.ExitCode())
+	}
+
+	// An explicit interpreter takes precedence
+	proc, err = NewScript("sh", "#!/no/such/interpreter\necho explicit\n")
+	if err != nil {
+		t.Fatalf("NewScript() failed: %v", err)
+	}
+	lines, _ = runCollect(t, proc)
+	if strings.Join(lines, "\n") != "explicit" {
+		t.Errorf("Output = %q, want explicit", lines)
+	}
+
+	if _, err := NewScript("", "echo no shebang\n"); err == nil {
+		t.Error("NewScript() without interpreter and shebang succeeded")
+	}
+}
+
+func TestNewScriptErrorLines(t *testing.T) {
+	proc, err := NewScript("sh", "true\n\nno_such_command_xyz\nexit 3\n")
+	if err != nil {
+		t.Fatalf("NewScript() failed: %v", err)
+	}
+	_, result := runCollect(t, proc)
+	if len(result.ScriptErrors) != 1 || result.ScriptErrors[0].Line != 3 {
+		t.Fatalf("ScriptErrors = %+v, want one error in line 3", result.ScriptErrors)
+	}
+	if !strings.Contains(result.ScriptErrors[0].Message, "no_such_command_xyz") {
+		t.Errorf("Message = %q, want the interpreter's message", result.ScriptErrors[0].Message)
+	}
+}
+
+func TestNewScriptPythonErrorLine(t *testing.T) {
+	if err := exec.Command("python3", "-c", "pass").Run(); err != nil {
+		t.Skip("python3 not available")
+	}
+	proc, err := NewScript("python3", "x = 1\nraise ValueError(x)\n")
+	if err != nil {
+		t.Fatalf("NewScript() failed: %v", err)
+	}
+	_, result := runCollect(t, proc)
+	if len(result.ScriptErrors) != 1 || result.ScriptErrors[0].Line != 2 {
+		t.Errorf("ScriptErrors = %+v, want one error in line 2", result.ScriptErrors)
+	}
+}
+
+func TestScriptInSandbox(t *testing.T) {
+	requireSandbox(t)
+	proc, err := NewScript("sh", "echo \"in $(hostname)\"\n")
+	if err != nil {
+		t.Fatalf("NewScript() failed: %v", err)
+	}
+	if err := proc.SetSandbox(testSandbox(&Sandbox{PrivateTmp: true})); err != nil {
+		t.Fatalf("SetSandbox() failed: %v", err)
+	}
+	lines, _ := runCollect(t, proc)
+	if strings.Join(lines, "\n") != "in sandbox" {
+		t.Errorf("Output = %q, want in sandbox", lines)
+	}
+}
+
+func TestTempScriptRemovedOnExit(t *testing.T) {
+	s, err := tempScript("echo from file\n")
+	if err != nil {
+		t.Fatalf("tempScript() failed: %v", err)
+	}
+	s.pattern = scriptPattern(s.path)
+	proc := New("sh", s.path)
+	proc.script = s
+
+	lines, _ := runCollect(t, proc)
+	if strings.Join(lines, "\n") != "from file" {
+		t.Errorf("Output = %q, want from file", lines)
+	}
+	if _, err := os.Stat(s.path); !os.IsNotExist(err) {
+		t.Errorf("Script %s still exists: %v", s.path, err)
+	}
+}
+
+func TestScriptMemfdClosedOnExit(t *testing.T) {
+	proc, err := NewScript("sh", "echo from memfd\n")
+	if err != nil {
+		t.Fatalf("NewScript() failed: %v", err)
+	}
+	file := proc.script.file
+	if file == nil {
+		t.Skip("memfd_create is not available")
+	}
+
+	lines, _ := runCollect(t, proc)
+	if strings.Join(lines, "\n") != "from memfd" {
+		t.Errorf("Output = %q, want from memfd", lines)
+	}
+	if _, err := file.Stat(); !errors.Is(err, os.ErrClosed) {
+		t.Errorf("Script memfd still open: %v", err)
+	}
+}
+
+func TestScriptThroughExecHelper(t *testing.T) {
+	if !seccompSupported {
+		t.Skip("seccomp profiles are not supported on this architecture")
+	}
+	proc, err := NewScript("sh", "echo filtered\n")
+	if err != nil {
+		t.Fatalf("NewScript() failed: %v", err)
+	}
+	if err := proc.SetSeccomp(&SeccompProfile{Presets: []string{SeccompNoPtrace}}); err != nil {
+		t.Fatalf("SetSeccomp() failed: %v", err)
+	}
+	lines, _ := runCollect(t, proc)
+	if strings.Join(lines, "\n") != "filtered" {
+		t.Errorf("Output = %q, want filtered", lines)
+	}
+}
@@ -0,0 +1,15 @@
+//go:build !linux
+
+// Package processctrl script sources
+//
+// Without memfds, script sources are stored in temporary files.
+
+package processctrl
+
+// openScript stores source for the interpreter.
+func openScript(source string) (*script, error) {
+	return tempScript(source)
+}
+
+// prepareScript is a no-op, as the interpreter opens a temporary file.
+func (p *Process) prepareScript() {}
@@ -0,0 +1,50 @@
+package processctrl
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestShebang(t *testing.T) {
+	tests := []struct {
+		source string
+		want   []string
+	}{
+		{"#!/bin/sh\necho hi\n", []string{"/bin/sh"}},
+		{"#! /usr/bin/env python3 -u\r\nprint(1)", []string{"/usr/bin/env", "python3", "-u"}},
+		{"#!/bin/sh", []string{"/bin/sh"}},
+		{"echo hi\n", nil},
+		{" #!/bin/sh\n", nil},
+		{"#!\n", []string{}},
+	}
+	for _, tt := range tests {
+		if got := shebang(tt.source); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("shebang(%q) = %q, want %q", tt.source, got, tt.want)
+		}
+	}
+}
+
+func TestScriptScan(t *testing.T) {
+	const path = "/proc/self/fd/5"
+	s := &script{path: path, pattern: scriptPattern(path)}
+	for _, line := range []string{
+		path + ": 3: foo: not found",                 // dash
+		path + ": line 4: foo: command not found",    // bash
+		`  File "` + path + `", line 5, in <module>`, // Python
+		"Died at " + path + " line 6.",               // Perl
+		path + ":7:in `<main>': unhandled exception", // Ruby
+		"unrelated output",                           // No reference
+		"/proc/self/fd/50: 8: not the script",        // Other path
+		path + ": 0: invalid line",                   // No line 0
+	} {
+		s.scan(line)
+	}
+
+	var lines []int
+	for _, e := range s.errors {
+		lines = append(lines, e.Line)
+	}
+	if want := []int{3, 4, 5, 6, 7}; !reflect.DeepEqual(lines, want) {
+		t.Errorf("Lines = %v, want %v", lines, want)
+	}
+}
@@ -0,0 +1,170 @@
+package processctrl
+
+import (
+	"fmt"
+	"syscall"
+)
+
+// SeccompAction is what happens when a process makes a system call that
+// its seccomp profile does not permit.
+type SeccompAction int
+
+const (
+	// SeccompErrno makes the system call fail with the profile's Errno.
+	SeccompErrno SeccompAction = iota
+	// SeccompKill kills the process with SIGSYS.
+	SeccompKill
+)
+
+// String returns a human-readable name for the action.
+func (a SeccompAction) String() string {
+	switch a {
+	case SeccompErrno:
+		return "errno"
+	case SeccompKill:
+		return "kill"
+	default:
+		return fmt.Sprintf("SeccompAction(%d)", int(a))
+	}
+}
+
+// Seccomp presets, which deny groups of system calls.
+const (
+	SeccompNoNetwork = "no-network" // socket(2) for anything but Unix domain sockets, and io_uring
+	SeccompNoExec    = "no-exec"    // execve(2) and execveat(2)
+	SeccompNoPtrace  = "no-ptrace"  // ptrace(2), process_vm_readv(2) and process_vm_writev(2)
+)
+
+// seccompPresets are the system calls denied by each preset, except for
+// those of SeccompNoNetwork, which sets DenySockets.
+var seccompPresets = map[string][]string{
+	SeccompNoNetwork: nil,
+	SeccompNoExec:    {"execve", "execveat"},
+	SeccompNoPtrace:  {"ptrace", "process_vm_readv", "process_vm_writev"},
+}
+
+// SeccompProfile restricts the system calls of a process with a
+// seccomp-BPF filter. System calls are given by their Linux names, e.g.
+// "openat".
+//
+// A profile is an allowlist if Allow is not nil: only the listed system
+// calls are permitted. Otherwise it is a denylist that permits everything
+// but the system calls in Deny and the presets. Deny and the presets take
+// precedence over Allow. System calls of other ABIs, such as 32-bit calls
+// on x86-64, are always violations.
+type SeccompProfile struct {
+	Allow   []string      // Permitted system calls; nil permits all that are not denied
+	Deny    []string      // Denied system calls
+	Presets []string      // Presets of denied system calls, e.g. SeccompNoNetwork
+	Action  SeccompAction // What happens on a violation
+	Errno   syscall.Errno // Error returned with SeccompErrno; defaults to EPERM
+}
+
+// seccompRules is a resolved SeccompProfile.
+type seccompRules struct {
+	Allow       []uint32 // nil permits all system calls that are not denied
+	Deny        []uint32
+	DenySockets bool // Deny socket(2) for anything but AF_UNIX, and io_uring
+	Kill        bool
+	Errno       uint32
+}
+
+// maxErrno is the highest error number the kernel returns.
+const maxErrno = 4095
+
+// SetSeccomp installs a seccomp filter in the process before the program
+// is executed, or removes it if profile is nil. It must be called before
+// the process is started.
+//
+// The filter is installed by a helper, started from the controller's own
+// executable (see Init), which then executes the program; the filter
+// stays in effect for the program and all its descendants. Without
+// CAP_SYS_ADMIN, no_new_privs is set as the kernel requires. A process
+// killed for a violation is reported with ExitSeccompKilled in its exit
+// result.
+//
+// If execve and execveat are denied, e.g. with SeccompNoExec, the
+// helper's exec of the program is approved by the controller, or the init
+// process of a sandbox, through a seccomp user notification, which
+// requires Linux 5.5. Any later exec fails with Errno or, with SeccompKill,
+// the process that tried it is killed with SIGKILL; if that is the
+// program, it is reported with ExitSeccompKilled as well.
+//
+// Only Linux on amd64 and arm64 is supported; other platforms return
+// ErrNotSupported.
+//
+// Returns an error if a system call or preset is unknown, the action or
+// errno is invalid, seccomp is not supported or the process is running.
+func (p *Process) SetSeccomp(profile *SeccompProfile) error {
+	if !seccompSupported {
+		return ErrNotSupported
+	}
+
+	var rules *seccompRules
+	if profile != nil {
+		var err error
+		if rules, err = resolveSeccomp(profile); err != nil {
+			return err
+		}
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.running {
+		return fmt.Errorf("cannot change the seccomp profile of a running process")
+	}
+	p.seccomp = rules
+	return nil
+}
+
+// resolveSeccomp resolves the system call names of a profile.
+func resolveSeccomp(profile *SeccompProfile) (*seccompRules, error) {
+	rules := &seccompRules{Kill: profile.Action == SeccompKill, Errno: uint32(syscall.EPERM)}
+	switch profile.Action {
+	case SeccompErrno:
+		if profile.Errno != 0 {
+			if profile.Errno > maxErrno {
+				return nil, fmt.Errorf("invalid errno: %d", int(profile.Errno))
+			}
+			rules.Errno = uint32(profile.Errno)
+		}
+	case SeccompKill:
+	default:
+		return nil, fmt.Errorf("invalid seccomp action: %v", profile.Action)
+	}
+
+	deny := append([]string(nil), profile.Deny...)
+	for _, preset := range profile.Presets {
+		names, ok := seccompPresets[preset]
+		if !ok {
+			return nil, fmt.Errorf("unknown seccomp preset %q", preset)
+		}
+		deny = append(deny, names...)
+		rules.DenySockets = rules.DenySockets || preset == SeccompNoNetwork
+	}
+
+	var err error
+	if rules.Deny, err = seccompNumbers(deny); err != nil {
+		return nil, err
+	}
+	if profile.Allow != nil {
+		if rules.Allow, err = seccompNumbers(profile.Allow); err != nil {
+			return nil, err
+		}
+	}
+	return rules, nil
+}
+
+// seccompNumbers returns the numbers of the named system calls.
+func seccompNumbers(names []string) ([]uint32, error) {
+	numbers := make([]uint32, 0, len(names))
+	for _, name := range names {
+		nr, ok := seccompSyscalls[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown system call %q", name)
+		}
+		numbers = append(numbers, nr)
+	}
+	return numbers, nil
+}
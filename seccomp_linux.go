@@ -0,0 +1,363 @@
+//go:build linux
+
+// Package processctrl Linux seccomp filters
+//
+// This file compiles and installs seccomp-BPF filters. A filter applies
+// to the thread that installs it and everything it executes, so it is
+// installed by the exec helper (see helper_linux.go) right before it
+// executes the program. The filter must still allow that final execve
+// when exec is denied. The helper therefore first installs a filter that
+// reports every exec to a supervisor through a seccomp user notification
+// and sends its listener to the controller, or to the init process of a
+// sandbox, which supervises it. The first exec reported is the helper's,
+// since no code of the program has run before it, and is let through; any
+// later one is denied by the supervisor. The program never holds the
+// listener, which is closed on exec, so it cannot approve its own exec.
+
+package processctrl
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strconv"
+	"syscall"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// seccompSupported reports whether SetSeccomp is available.
+const seccompSupported = seccompArch != 0
+
+// Offsets in struct seccomp_data.
+const (
+	seccompNrOff   = 0
+	seccompArchOff = 4
+	seccompArg0Off = 16 // Lower half on little-endian architectures
+)
+
+// seccompX32 is set in the numbers of x32 system calls on x86-64.
+const seccompX32 = 0x40000000
+
+// BPF instructions of the filters.
+const (
+	bpfLoad = unix.BPF_LD | unix.BPF_W | unix.BPF_ABS
+	bpfJeq  = unix.BPF_JMP | unix.BPF_JEQ | unix.BPF_K
+	bpfJge  = unix.BPF_JMP | unix.BPF_JGE | unix.BPF_K
+	bpfRet  = unix.BPF_RET | unix.BPF_K
+)
+
+// bpfStmt returns a BPF instruction without jump offsets.
+func bpfStmt(code uint16, k uint32) unix.SockFilter {
+	return unix.SockFilter{Code: code, K: k}
+}
+
+// bpfJump returns a BPF instruction that skips jt instructions if the
+// accumulator equals k and jf otherwise.
+func bpfJump(k uint32, jt, jf uint8) unix.SockFilter {
+	return unix.SockFilter{Code: bpfJeq, Jt: jt, Jf: jf, K: k}
+}
+
+// seccompNotif is struct seccomp_notif, a system call reported to the
+// supervisor of a filter.
+type seccompNotif struct {
+	id    uint64
+	pid   uint32
+	flags uint32
+	nr    int32
+	arch  uint32
+	ip    uint64
+	args  [6]uint64
+}
+
+// seccompNotifResp is struct seccomp_notif_resp, the supervisor's answer
+// to a notification.
+type seccompNotifResp struct {
+	id    uint64
+	val   int64
+	error int32
+	flags uint32
+}
+
+// detectSeccompKill reports whether the process was killed for violating
+// its seccomp profile, given the signal that terminated the program.
+// It must be called with p.mu held.
+func (p *Process) detectSeccompKill(sig os.Signal) bool {
+	if p.seccomp == nil {
+		return false
+	}
+	return p.execKilled.Load() || sig == syscall.SIGSYS && p.seccomp.Kill
+}
+
+// watchExec supervises the seccomp listener sent by the exec helper, see
+// superviseExec.
+// It must be called with p.mu held while the process is started.
+func (p *Process) watchExec(listener *os.File) {
+	p.execListener = listener
+	pid := p.programPID
+	go superviseExec(listener, p.seccomp, func(killed int) {
+		if killed == pid {
+			p.execKilled.Store(true)
+		}
+	})
+}
+
+// releaseExecListener closes the seccomp listener of the process, which
+// ends its supervision.
+// It must be called with p.mu held after the process has been reaped.
+func (p *Process) releaseExecListener() {
+	if p.execListener != nil {
+		_ = p.execListener.Close() // Ignore error during cleanup
+		p.execListener = nil
+	}
+}
+
+// deniesExec reports whether the rules deny execve or execveat, in which
+// case the exec helper's exec of the program is approved by a supervisor.
+func (r *seccompRules) deniesExec() bool {
+	for _, name := range []string{"execve", "execveat"} {
+		nr := seccompSyscalls[name]
+		if slices.Contains(r.Deny, nr) || r.Allow != nil && !slices.Contains(r.Allow, nr) {
+			return true
+		}
+	}
+	return false
+}
+
+// program compiles the rules to a BPF program. If the rules deny exec, it
+// allows execve and execveat and leaves them to the filter of
+// execListenerProgram, which is installed before.
+func (r *seccompRules) program() []unix.SockFilter {
+	const allow = unix.SECCOMP_RET_ALLOW
+
+	violation := uint32(unix.SECCOMP_RET_ERRNO) | r.Errno&unix.SECCOMP_RET_DATA
+	if r.Kill {
+		violation = unix.SECCOMP_RET_KILL_PROCESS
+	}
+
+	prog := []unix.SockFilter{
+		// System calls of other ABIs use other numbers
+		bpfStmt(bpfLoad, seccompArchOff),
+		bpfJump(seccompArch, 1, 0),
+		bpfStmt(bpfRet, violation),
+		bpfStmt(bpfLoad, seccompNrOff),
+		{Code: bpfJge, Jt: 0, Jf: 1, K: seccompX32},
+		bpfStmt(bpfRet, violation),
+	}
+	if r.deniesExec() {
+		for _, name := range []string{"execve", "execveat"} {
+			prog = append(prog, bpfJump(seccompSyscalls[name], 0, 1), bpfStmt(bpfRet, allow))
+		}
+	}
+	if r.DenySockets {
+		prog = append(prog,
+			bpfJump(seccompSyscalls["socket"], 0, 4),
+			bpfStmt(bpfLoad, seccompArg0Off),
+			bpfJump(unix.AF_UNIX, 1, 0),
+			bpfStmt(bpfRet, violation),
+			bpfStmt(bpfLoad, seccompNrOff),
+		)
+		// io_uring opens sockets of any family without socket(2)
+		for _, name := range []string{"io_uring_setup", "io_uring_enter", "io_uring_register"} {
+			prog = append(prog, bpfJump(seccompSyscalls[name], 0, 1), bpfStmt(bpfRet, violation))
+		}
+	}
+	for _, nr := range r.Deny {
+		prog = append(prog, bpfJump(nr, 0, 1), bpfStmt(bpfRet, violation))
+	}
+	if r.Allow == nil {
+		return append(prog, bpfStmt(bpfRet, allow))
+	}
+	for _, nr := range r.Allow {
+		prog = append(prog, bpfJump(nr, 0, 1), bpfStmt(bpfRet, allow))
+	}
+	return append(prog, bpfStmt(bpfRet, violation))
+}
+
+// execListenerProgram returns the filter that reports every execve and
+// execveat to the supervisor of its listener. Other ABIs are left to the
+// filter of the profile.
+func execListenerProgram() []unix.SockFilter {
+	return []unix.SockFilter{
+		bpfStmt(bpfLoad, seccompArchOff),
+		bpfJump(seccompArch, 0, 3),
+		bpfStmt(bpfLoad, seccompNrOff),
+		bpfJump(seccompSyscalls["execve"], 2, 0),
+		bpfJump(seccompSyscalls["execveat"], 1, 0),
+		bpfStmt(bpfRet, unix.SECCOMP_RET_ALLOW),
+		bpfStmt(bpfRet, unix.SECCOMP_RET_USER_NOTIF),
+	}
+}
+
+// sendExecListener installs the filter of execListenerProgram and sends
+// its listener over conn, to be received by helperStart.run.
+func sendExecListener(conn *os.File) error {
+	fd, err := installSeccomp(execListenerProgram(), unix.SECCOMP_FILTER_FLAG_NEW_LISTENER)
+	if err != nil {
+		return err
+	}
+	defer unix.Close(fd)
+	// The newline carrying the descriptor is skipped by the JSON decoder
+	return unix.Sendmsg(int(conn.Fd()), []byte("\n"), unix.UnixRights(fd), nil, 0)
+}
+
+// superviseExec answers the exec notifications of a seccomp listener until
+// no process uses its filter anymore or the listener is closed. The first
+// exec is the exec helper's and continues. Every later one fails with the
+// errno of the rules or, if they kill, the process that tried it is killed
+// with SIGKILL after killed has been called with its PID.
+func superviseExec(listener *os.File, rules *seccompRules, killed func(pid int)) {
+	rc, err := listener.SyscallConn()
+	if err != nil {
+		return
+	}
+	helperExec := true
+	for {
+		var notif seccompNotif
+		var recvErr error
+		err := rc.Read(func(fd uintptr) bool {
+			fds := []unix.PollFd{{Fd: int32(fd), Events: unix.POLLIN}}
+			if n, err := unix.Poll(fds, 0); err != nil || n == 0 {
+				return false
+			}
+			if fds[0].Revents&unix.POLLIN == 0 {
+				// No process uses the filter anymore
+				recvErr = io.EOF
+				return true
+			}
+			recvErr = seccompIoctl(fd, unix.SECCOMP_IOCTL_NOTIF_RECV, unsafe.Pointer(&notif))
+			return true
+		})
+		if err != nil || recvErr == io.EOF {
+			return
+		}
+		if recvErr != nil {
+			// The process was killed before the notification was received
+			continue
+		}
+
+		_ = rc.Control(func(fd uintptr) {
+			resp := seccompNotifResp{id: notif.id}
+			switch {
+			case helperExec:
+				helperExec = false
+				resp.flags = unix.SECCOMP_USER_NOTIF_FLAG_CONTINUE
+			case rules.Kill:
+				resp.error = -int32(unix.EPERM)
+				// The PID is only valid while the notification is
+				if seccompIoctl(fd, unix.SECCOMP_IOCTL_NOTIF_ID_VALID, unsafe.Pointer(&notif.id)) == nil {
+					if pid := threadGroup(int(notif.pid)); pid > 0 {
+						killed(pid)
+						_ = unix.Kill(pid, unix.SIGKILL) // Ignore error - the process might already be dead
+					}
+				}
+			default:
+				resp.error = -int32(rules.Errno)
+			}
+			_ = seccompIoctl(fd, unix.SECCOMP_IOCTL_NOTIF_SEND, unsafe.Pointer(&resp)) // Ignore error - the process might be gone
+		})
+	}
+}
+
+// seccompIoctl performs an ioctl on a seccomp listener.
+func seccompIoctl(fd uintptr, req uint, arg unsafe.Pointer) error {
+	_, _, errno := unix.Syscall(unix.SYS_IOCTL, fd, uintptr(req), uintptr(arg))
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// threadGroup returns the PID of the process the thread tid belongs to, or
+// 0 if it is gone.
+func threadGroup(tid int) int {
+	status, err := readProcStatus(tid)
+	if err != nil {
+		return 0
+	}
+	pid, _ := strconv.Atoi(status["Tgid"]) // Zero if malformed
+	return pid
+}
+
+// kernelSigaction is the struct sigaction of the rt_sigaction system
+// call, which has the same layout on all architectures with seccomp
+// support.
+type kernelSigaction struct {
+	handler  uintptr
+	flags    uint64
+	restorer uintptr
+	mask     uint64
+}
+
+// resetSignalHandlers gives every caught signal its default action, as
+// the exec of the program would, so that no signal runs Go code on the
+// calling thread under the seccomp filter. That code could violate the
+// program's profile and kill the helper with SIGSYS before it executes
+// the program. Signals are blocked while the handlers are replaced, and
+// the previous mask is restored, so the program starts with the mask it
+// would have had. Ignored signals stay ignored, as across an exec.
+func resetSignalHandlers() error {
+	all, old := ^uint64(0), uint64(0)
+	if err := sigprocmask(unix.SIG_SETMASK, &all, &old); err != nil {
+		return err
+	}
+	for sig := 1; sig <= 64; sig++ {
+		if sig == int(syscall.SIGKILL) || sig == int(syscall.SIGSTOP) {
+			continue
+		}
+		var act kernelSigaction
+		if sigaction(sig, nil, &act) != nil || act.handler == 0 || act.handler == 1 {
+			// Unused signal number, or SIG_DFL or SIG_IGN already
+			continue
+		}
+		if err := sigaction(sig, &kernelSigaction{}, nil); err != nil {
+			_ = sigprocmask(unix.SIG_SETMASK, &old, nil) // Ignore error - the helper fails anyway
+			return err
+		}
+	}
+	return sigprocmask(unix.SIG_SETMASK, &old, nil)
+}
+
+// sigprocmask changes the signal mask of the calling thread.
+func sigprocmask(how int, set, old *uint64) error {
+	_, _, errno := unix.RawSyscall6(unix.SYS_RT_SIGPROCMASK, uintptr(how), uintptr(unsafe.Pointer(set)),
+		uintptr(unsafe.Pointer(old)), 8, 0, 0)
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// sigaction changes or reads the action of a signal.
+func sigaction(sig int, act, old *kernelSigaction) error {
+	_, _, errno := unix.RawSyscall6(unix.SYS_RT_SIGACTION, uintptr(sig), uintptr(unsafe.Pointer(act)),
+		uintptr(unsafe.Pointer(old)), 8, 0, 0)
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// installSeccomp installs a seccomp filter for the current thread and
+// returns the descriptor the flags ask for, if any.
+func installSeccomp(filter []unix.SockFilter, flags uintptr) (int, error) {
+	prog := unix.SockFprog{Len: uint16(len(filter)), Filter: &filter[0]}
+	set := func() (int, error) {
+		fd, _, errno := unix.Syscall(unix.SYS_SECCOMP, unix.SECCOMP_SET_MODE_FILTER, flags, uintptr(unsafe.Pointer(&prog)))
+		if errno != 0 {
+			return -1, errno
+		}
+		return int(fd), nil
+	}
+	fd, err := set()
+	if err == unix.EACCES {
+		// Without CAP_SYS_ADMIN, filters require no_new_privs
+		if err := unix.Prctl(unix.PR_SET_NO_NEW_PRIVS, 1, 0, 0, 0); err != nil {
+			return -1, fmt.Errorf("failed to set no_new_privs: %w", err)
+		}
+		fd, err = set()
+	}
+	return fd, err
+}
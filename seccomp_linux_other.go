@@ -0,0 +1,14 @@
+//go:build linux && !amd64 && !arm64
+
+// Package processctrl seccomp system calls stub
+//
+// Seccomp profiles are only supported on amd64 and arm64. On other
+// architectures SetSeccomp returns ErrNotSupported.
+
+package processctrl
+
+// seccompArch is zero, since seccomp profiles are not supported.
+const seccompArch = 0
+
+// seccompSyscalls is empty, since seccomp profiles are not supported.
+var seccompSyscalls map[string]uint32
@@ -0,0 +1,244 @@
+//go:build linux
+
+package processctrl
+
+import (
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// runSeccomp runs a shell script with a seccomp profile and returns its
+// output lines and exit result.
+func runSeccomp(t *testing.T, profile *SeccompProfile, shell, script string) ([]string, *ExitResult) {
+	t.Helper()
+
+	if !seccompSupported {
+		t.Skip("seccomp profiles are not supported on this architecture")
+	}
+	proc := New(shell, "-c", script)
+	if err := proc.SetSeccomp(profile); err != nil {
+		t.Fatalf("SetSeccomp() failed: %v", err)
+	}
+	return runCollect(t, proc)
+}
+
+func init() {
+	testPrograms["exec-retry"] = execRetry
+	testPrograms["io-uring-setup"] = ioUringSetup
+}
+
+// ioUringSetup prints the result of creating an io_uring instance, which
+// can open network sockets without socket(2).
+func ioUringSetup() int {
+	var params [120]byte // struct io_uring_params
+	fd, _, errno := unix.RawSyscall(unix.SYS_IO_URING_SETUP, 1, uintptr(unsafe.Pointer(&params[0])), 0)
+	if errno != 0 {
+		fmt.Println("io_uring_setup:", errno)
+		return 0
+	}
+	unix.Close(int(fd))
+	fmt.Println("io_uring_setup: ok")
+	return 0
+}
+
+// execRetry tries to execute a program many times, directly and from
+// forked children, and prints how many attempts succeeded.
+func execRetry() int {
+	fmt.Println("started")
+	path, err := unix.BytePtrFromString("/bin/true")
+	if err != nil {
+		return 1
+	}
+	argv := []*byte{path, nil}
+	executed := 0
+	for range 100 {
+		_, _, errno := unix.RawSyscall(unix.SYS_EXECVE, uintptr(unsafe.Pointer(path)), uintptr(unsafe.Pointer(&argv[0])), 0)
+		if errno != unix.EPERM {
+			executed++
+		}
+		if exec.Command("/bin/true").Run() == nil {
+			executed++
+		}
+	}
+	fmt.Println("executed:", executed)
+	return 0
+}
+
+func TestSeccompNoExec(t *testing.T) {
+	// The shell itself is executed, but its child fails to execute anything
+	// else
+	lines, result := runSeccomp(t, &SeccompProfile{Presets: []string{SeccompNoExec}},
+		"sh", "echo started; true; /bin/true 2>/dev/null; echo $?")
+	want := []string{"started", "126"}
+	if strings.Join(lines, "\n") != strings.Join(want, "\n") {
+		t.Errorf("Output = %q, want %q", lines, want)
+	}
+	if result.ExitCode() != 0 || result.SeccompKilled {
+		t.Errorf("ExitCode() = %d, SeccompKilled = %v, want 0, false", result.ExitCode(), result.SeccompKilled)
+	}
+}
+
+func TestSeccompNoExecRetry(t *testing.T) {
+	if !seccompSupported {
+		t.Skip("seccomp profiles are not supported on this architecture")
+	}
+	// Only the helper's exec of the program is approved, however often the
+	// program tries
+	proc := newTestProgram(t, "exec-retry")
+	if err := proc.SetSeccomp(&SeccompProfile{Presets: []string{SeccompNoExec}}); err != nil {
+		t.Fatalf("SetSeccomp() failed: %v", err)
+	}
+	lines, result := runCollect(t, proc)
+	want := []string{"started", "executed: 0"}
+	if strings.Join(lines, "\n") != strings.Join(want, "\n") {
+		t.Errorf("Output = %q, want %q", lines, want)
+	}
+	if result.ExitCode() != 0 {
+		t.Errorf("ExitCode() = %d, want 0", result.ExitCode())
+	}
+}
+
+func TestSeccompNoExecKill(t *testing.T) {
+	// A child that executes something is killed, and so is the program
+	lines, result := runSeccomp(t, &SeccompProfile{Presets: []string{SeccompNoExec}, Action: SeccompKill},
+		"sh", "echo started; /bin/true; echo $?; exec /bin/true")
+	want := []string{"started", fmt.Sprint(128 + int(syscall.SIGKILL))}
+	if strings.Join(lines, "\n") != strings.Join(want, "\n") {
+		t.Errorf("Output = %q, want %q", lines, want)
+	}
+	if result.Reason != ExitSeccompKilled || !result.SeccompKilled {
+		t.Errorf("Reason = %v, SeccompKilled = %v, want %v, true", result.Reason, result.SeccompKilled, ExitSeccompKilled)
+	}
+}
+
+func TestSeccompKillReportsSIGSYS(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "dir")
+	lines, result := runSeccomp(t, &SeccompProfile{Deny: []string{"mkdir", "mkdirat"}, Action: SeccompKill},
+		"sh", "echo started; exec mkdir "+dir)
+	if strings.Join(lines, "\n") != "started" {
+		t.Errorf("Output = %q, want started", lines)
+	}
+	if sig := exitSignal(result); sig != syscall.SIGSYS {
+		t.Errorf("Exit signal = %v, want SIGSYS", sig)
+	}
+	if result.Reason != ExitSeccompKilled || !result.SeccompKilled {
+		t.Errorf("Reason = %v, SeccompKilled = %v, want %v, true", result.Reason, result.SeccompKilled, ExitSeccompKilled)
+	}
+}
+
+func TestSeccompAllowlistWithErrno(t *testing.T) {
+	var allow []string
+	for name := range seccompSyscalls {
+		if name != "mkdir" && name != "mkdirat" {
+			allow = append(allow, name)
+		}
+	}
+	dir := filepath.Join(t.TempDir(), "dir")
+	lines, result := runSeccomp(t, &SeccompProfile{Allow: allow, Errno: syscall.ENOSYS},
+		"sh", "mkdir "+dir+" 2>&1")
+	if len(lines) != 1 || !strings.Contains(lines[0], "Function not implemented") {
+		t.Errorf("Output = %q, want ENOSYS", lines)
+	}
+	if result.ExitCode() == 0 {
+		t.Error("mkdir succeeded despite the allowlist")
+	}
+}
+
+func TestSeccompNoNetwork(t *testing.T) {
+	if _, err := exec.LookPath("bash"); err != nil {
+		t.Skip("bash not available")
+	}
+	lines, _ := runSeccomp(t, &SeccompProfile{Presets: []string{SeccompNoNetwork}},
+		"bash", "{ exec 3<>/dev/tcp/127.0.0.1/1; } 2>&1; echo done")
+	if len(lines) < 2 || !strings.Contains(lines[0], "socket: Operation not permitted") || lines[len(lines)-1] != "done" {
+		t.Errorf("Output = %q, want EPERM from socket(2)", lines)
+	}
+}
+
+func TestSeccompNoNetworkIOUring(t *testing.T) {
+	if !seccompSupported {
+		t.Skip("seccomp profiles are not supported on this architecture")
+	}
+	proc := newTestProgram(t, "io-uring-setup")
+	if err := proc.SetSeccomp(&SeccompProfile{Presets: []string{SeccompNoNetwork}}); err != nil {
+		t.Fatalf("SetSeccomp() failed: %v", err)
+	}
+	lines, _ := runCollect(t, proc)
+	if want := "io_uring_setup: " + syscall.EPERM.Error(); strings.Join(lines, "\n") != want {
+		t.Errorf("Output = %q, want %s", lines, want)
+	}
+}
+
+func TestSeccompKeepsSignalMask(t *testing.T) {
+	// The helper blocks signals while it resets its handlers, but the
+	// program starts with the same mask and ignored signals as without
+	// a seccomp profile
+	const script = "exec grep -E '^Sig(Blk|Ign)' /proc/self/status"
+	want, _ := runCollect(t, New("sh", "-c", script))
+	lines, result := runSeccomp(t, &SeccompProfile{Deny: []string{"mkdir", "mkdirat"}}, "sh", script)
+	if result.ExitCode() != 0 || strings.Join(lines, "\n") != strings.Join(want, "\n") {
+		t.Errorf("Output = %q, want %q", lines, want)
+	}
+}
+
+func TestSeccompInSandbox(t *testing.T) {
+	if !seccompSupported {
+		t.Skip("seccomp profiles are not supported on this architecture")
+	}
+	requireSandbox(t)
+	proc := New("sh", "-c", "exec mkdir /tmp/seccomp-sandbox")
+	if err := proc.SetSandbox(testSandbox(&Sandbox{})); err != nil {
+		t.Fatalf("SetSandbox() failed: %v", err)
+	}
+	if err := proc.SetSeccomp(&SeccompProfile{Deny: []string{"mkdir", "mkdirat"}, Action: SeccompKill}); err != nil {
+		t.Fatalf("SetSeccomp() failed: %v", err)
+	}
+	_, result := runCollect(t, proc)
+	if !result.SeccompKilled || result.Signal != syscall.SIGSYS {
+		t.Errorf("SeccompKilled = %v, Signal = %v, want true, %v", result.SeccompKilled, result.Signal, syscall.SIGSYS)
+	}
+}
+
+func TestSeccompNoExecKillInSandbox(t *testing.T) {
+	if !seccompSupported {
+		t.Skip("seccomp profiles are not supported on this architecture")
+	}
+	requireSandbox(t)
+	// The init process supervises the exec and reports the kill
+	proc := New("sh", "-c", "exec /bin/true")
+	if err := proc.SetSandbox(testSandbox(&Sandbox{})); err != nil {
+		t.Fatalf("SetSandbox() failed: %v", err)
+	}
+	if err := proc.SetSeccomp(&SeccompProfile{Presets: []string{SeccompNoExec}, Action: SeccompKill}); err != nil {
+		t.Fatalf("SetSeccomp() failed: %v", err)
+	}
+	_, result := runCollect(t, proc)
+	if !result.SeccompKilled || result.Signal != syscall.SIGKILL {
+		t.Errorf("SeccompKilled = %v, Signal = %v, want true, %v", result.SeccompKilled, result.Signal, syscall.SIGKILL)
+	}
+}
+
+func TestSetSeccompInvalid(t *testing.T) {
+	if !seccompSupported {
+		t.Skip("seccomp profiles are not supported on this architecture")
+	}
+	proc := New("true")
+	for _, profile := range []*SeccompProfile{
+		{Deny: []string{"no_such_syscall"}},
+		{Allow: []string{"read", "no_such_syscall"}},
+		{Presets: []string{"no-fun"}},
+		{Action: SeccompAction(7)},
+		{Errno: 5000},
+	} {
+		if err := proc.SetSeccomp(profile); err == nil {
+			t.Errorf("SetSeccomp(%+v) succeeded, want error", profile)
+		}
+	}
+}
@@ -0,0 +1,24 @@
+//go:build !linux
+
+// Package processctrl seccomp stub
+//
+// seccomp is a Linux feature. On other platforms SetSeccomp returns
+// ErrNotSupported.
+
+package processctrl
+
+import "os"
+
+// seccompSupported reports whether SetSeccomp is available.
+const seccompSupported = false
+
+// seccompSyscalls is empty, since seccomp profiles are not supported.
+var seccompSyscalls map[string]uint32
+
+// releaseExecListener does nothing on platforms without seccomp.
+func (p *Process) releaseExecListener() {}
+
+// detectSeccompKill always reports false on platforms without seccomp.
+func (p *Process) detectSeccompKill(os.Signal) bool {
+	return false
+}
@@ -0,0 +1,294 @@
+package processctrl
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// shellSafe are the bytes that never need quoting in a POSIX shell word.
+const shellSafe = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789@%+=:,./-_"
+
+// commandSafe are the bytes of a program name that the shell always
+// reads as a plain command name, unless the name is a reserved word.
+const commandSafe = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789_./-"
+
+// shellReserved are the reserved words of POSIX shells and of bash and
+// zsh made of shellSafe bytes. They only have a meaning unquoted as the
+// first word of a command.
+var shellReserved = map[string]bool{
+	"case": true, "do": true, "done": true, "elif": true, "else": true, "esac": true,
+	"fi": true, "for": true, "if": true, "in": true, "then": true, "until": true,
+	"while": true, "function": true, "select": true, "time": true, "coproc": true,
+}
+
+// Quote returns arg quoted for a POSIX shell, so that the shell reads it as
+// exactly one word with the same content. Arguments made of letters,
+// digits and @%+=:,./-_ are returned unchanged; others are put in single
+// quotes, which disable all expansions. As the first word of a command,
+// use QuoteCommand, since unquoted words like FOO=bar, if or %1 have a
+// meaning there.
+func Quote(arg string) string {
+	if arg == "" {
+		return "''"
+	}
+	if strings.Trim(arg, shellSafe) == "" {
+		return arg
+	}
+	return "'" + strings.ReplaceAll(arg, "'", `'\''`) + "'"
+}
+
+// QuoteCommand returns the command line that runs argv in a POSIX shell,
+// with every argument quoted by Quote. The program is put in single quotes
+// as well unless it contains a slash or is made of letters, digits and
+// _./- only, since the shell could otherwise read it as something else,
+// like a variable assignment (FOO=bar), a reserved word (if, time) or a
+// job specification (%1).
+func QuoteCommand(argv ...string) string {
+	quoted := make([]string, len(argv))
+	for i, arg := range argv {
+		quoted[i] = Quote(arg)
+	}
+	if len(argv) > 0 {
+		quoted[0] = quoteProgram(argv[0])
+	}
+	return strings.Join(quoted, " ")
+}
+
+// quoteProgram quotes the first word of a command for QuoteCommand.
+func quoteProgram(program string) string {
+	quoted := Quote(program)
+	if quoted != program {
+		return quoted
+	}
+	if !shellReserved[program] && !strings.Contains(program, "=") &&
+		(strings.Contains(program, "/") || strings.Trim(program, commandSafe) == "") {
+		return program
+	}
+	return "'" + program + "'"
+}
+
+// CommandLine returns the program and arguments of the process as a
+// POSIX shell command line, for logs and dry runs. Running it in a shell
+// passes exactly the same arguments. Settings that are not part of the
+// command line, like credentials or a sandbox, are not included.
+func (p *Process) CommandLine() string {
+	return QuoteCommand(append([]string{p.program}, p.args...)...)
+}
+
+// ShellCommand builds a command line for a POSIX shell from argument
+// vectors. Every argument and path is quoted with Quote, so only the
+// operators added through its methods have a meaning to the shell.
+//
+// A command or operator without a command, like Pipe with no arguments,
+// is not written; the first such mistake is returned by Err and Process.
+type ShellCommand struct {
+	b   strings.Builder
+	err error
+}
+
+// NewShellCommand returns a shell command that runs argv.
+func NewShellCommand(argv ...string) *ShellCommand {
+	c := &ShellCommand{}
+	if len(argv) == 0 {
+		c.err = fmt.Errorf("shell command has no program")
+		return c
+	}
+	c.b.WriteString(QuoteCommand(argv...))
+	return c
+}
+
+// add appends an operator and the command argv, or records an error if
+// argv is empty, since the shell rejects an operator without a command.
+func (c *ShellCommand) add(op string, argv []string) *ShellCommand {
+	if len(argv) == 0 {
+		if c.err == nil {
+			c.err = fmt.Errorf("shell operator %q has no command", strings.TrimSpace(op))
+		}
+		return c
+	}
+	c.b.WriteString(op)
+	c.b.WriteString(QuoteCommand(argv...))
+	return c
+}
+
+// redirect appends a redirection operator and its path, which unlike a
+// program is never read as an assignment or reserved word.
+func (c *ShellCommand) redirect(op, path string) *ShellCommand {
+	c.b.WriteString(op)
+	c.b.WriteString(Quote(path))
+	return c
+}
+
+// Pipe connects the standard output of the command to the standard input
+// of argv.
+func (c *ShellCommand) Pipe(argv ...string) *ShellCommand {
+	return c.add(" | ", argv)
+}
+
+// And runs argv if the command succeeds.
+func (c *ShellCommand) And(argv ...string) *ShellCommand {
+	return c.add(" && ", argv)
+}
+
+// Or runs argv if the command fails.
+func (c *ShellCommand) Or(argv ...string) *ShellCommand {
+	return c.add(" || ", argv)
+}
+
+// Then runs argv after the command.
+func (c *ShellCommand) Then(argv ...string) *ShellCommand {
+	return c.add("; ", argv)
+}
+
+// RedirectIn reads the standard input of the last command from path.
+func (c *ShellCommand) RedirectIn(path string) *ShellCommand {
+	return c.redirect(" < ", path)
+}
+
+// RedirectOut writes the standard output of the last command to path,
+// truncating it.
+func (c *ShellCommand) RedirectOut(path string) *ShellCommand {
+	return c.redirect(" > ", path)
+}
+
+// AppendOut appends the standard output of the last command to path.
+func (c *ShellCommand) AppendOut(path string) *ShellCommand {
+	return c.redirect(" >> ", path)
+}
+
+// RedirectErr writes the standard error of the last command to path,
+// truncating it.
+func (c *ShellCommand) RedirectErr(path string) *ShellCommand {
+	return c.redirect(" 2> ", path)
+}
+
+// ErrToOut sends the standard error of the last command to its standard
+// output.
+func (c *ShellCommand) ErrToOut() *ShellCommand {
+	c.b.WriteString(" 2>&1")
+	return c
+}
+
+// String returns the command line. Commands and operators rejected by Err
+// are left out.
+func (c *ShellCommand) String() string {
+	return c.b.String()
+}
+
+// Err returns the first error recorded while building the command, such
+// as an operator without a command, or nil.
+func (c *ShellCommand) Err() error {
+	return c.err
+}
+
+// Process returns a new Process that runs the command line with "sh -c".
+//
+// Returns the error from Err if the command line is incomplete.
+func (c *ShellCommand) Process() (*Process, error) {
+	if c.err != nil {
+		return nil, c.err
+	}
+	return New("sh", "-c", c.String()), nil
+}
+
+// ErrShellSyntax is returned by SplitCommand for input it cannot split
+// without a shell.
+var ErrShellSyntax = errors.New("shell syntax not supported")
+
+// SplitCommand splits a command line into arguments the way a POSIX shell
+// does, without running a shell, for example to pass a configured command
+// to New. Words are separated by blanks and newlines; single quotes,
+// double quotes and backslashes work as in the shell, and an unquoted #
+// at the start of a word begins a comment.
+//
+// Expansions and operators are not supported: unquoted |&;<>() and $ or `
+// outside single quotes return an error wrapping ErrShellSyntax, as does
+// an unterminated quote or escape. Glob characters and ~ are kept
+// literally.
+func SplitCommand(s string) ([]string, error) {
+	var (
+		args    []string
+		word    strings.Builder
+		inWord  bool // A word has started, possibly an empty quoted one
+		comment bool
+	)
+	for i := 0; i < len(s); i++ {
+		ch := s[i]
+		if comment {
+			comment = ch != '\n'
+			continue
+		}
+		switch {
+		case ch == ' ' || ch == '\t' || ch == '\n':
+			if inWord {
+				args = append(args, word.String())
+				word.Reset()
+				inWord = false
+			}
+		case ch == '#' && !inWord:
+			comment = true
+		case ch == '\\':
+			i++
+			if i == len(s) {
+				return nil, fmt.Errorf("%w: unterminated escape", ErrShellSyntax)
+			}
+			// An escaped newline continues the line
+			if s[i] != '\n' {
+				word.WriteByte(s[i])
+				inWord = true
+			}
+		case ch == '\'':
+			end := strings.IndexByte(s[i+1:], '\'')
+			if end < 0 {
+				return nil, fmt.Errorf("%w: unterminated single quote", ErrShellSyntax)
+			}
+			word.WriteString(s[i+1 : i+1+end])
+			i += end + 1
+			inWord = true
+		case ch == '"':
+			n, err := splitDoubleQuoted(s[i+1:], &word)
+			if err != nil {
+				return nil, err
+			}
+			i += n
+			inWord = true
+		case strings.IndexByte("|&;<>()$`", ch) >= 0:
+			return nil, fmt.Errorf("%w: unquoted %q at offset %d", ErrShellSyntax, ch, i)
+		default:
+			word.WriteByte(ch)
+			inWord = true
+		}
+	}
+	if inWord {
+		args = append(args, word.String())
+	}
+	return args, nil
+}
+
+// splitDoubleQuoted appends the content of a double-quoted string, which
+// s starts right after, to word and returns the length of the string
+// including the closing quote.
+func splitDoubleQuoted(s string, word *strings.Builder) (int, error) {
+	for i := 0; i < len(s); i++ {
+		switch ch := s[i]; ch {
+		case '"':
+			return i + 1, nil
+		case '\\':
+			// A backslash only escapes characters that are special here
+			if i+1 < len(s) && strings.IndexByte("$`\"\\\n", s[i+1]) >= 0 {
+				i++
+				if s[i] != '\n' {
+					word.WriteByte(s[i])
+				}
+				continue
+			}
+			word.WriteByte(ch)
+		case '$', '`':
+			return 0, fmt.Errorf("%w: %q in double quotes", ErrShellSyntax, ch)
+		default:
+			word.WriteByte(ch)
+		}
+	}
+	return 0, fmt.Errorf("%w: unterminated double quote", ErrShellSyntax)
+}
@@ -0,0 +1,204 @@
+package processctrl
+
+import (
+	"errors"
+	"os/exec"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// shellArgs are arguments with characters that are special to the shell.
+var shellArgs = []string{
+	"plain", "", "two words", "it's", `"double"`, "$HOME", "`id`", "a\\b",
+	"semi;colon", "pipe|amp&", "glob*?[x]", "~user", "new\nline", "tab\there",
+	"#hash", "-dash", "ünïcode", "'", "''", "a=b,c:d/e.f@g%h+i",
+}
+
+func TestQuote(t *testing.T) {
+	tests := map[string]string{
+		"plain":     "plain",
+		"":          "''",
+		"a=b/c.d-e": "a=b/c.d-e",
+		"two words": "'two words'",
+		"it's":      `'it'\''s'`,
+		"$HOME":     "'$HOME'",
+		"~":         "'~'",
+	}
+	for arg, want := range tests {
+		if got := Quote(arg); got != want {
+			t.Errorf("Quote(%q) = %s, want %s", arg, got, want)
+		}
+	}
+}
+
+func TestQuoteCommandRoundTrip(t *testing.T) {
+	line := QuoteCommand(shellArgs...)
+	args, err := SplitCommand(line)
+	if err != nil {
+		t.Fatalf("SplitCommand(%s) failed: %v", line, err)
+	}
+	if !reflect.DeepEqual(args, shellArgs) {
+		t.Errorf("SplitCommand(QuoteCommand()) = %q, want %q", args, shellArgs)
+	}
+}
+
+func TestQuoteCommandInShell(t *testing.T) {
+	if runtime.GOOS == windowsOS {
+		t.Skip("no POSIX shell on Windows")
+	}
+	// Every argument is printed on its own line, terminated by a NUL byte
+	out, err := exec.Command("sh", "-c", "printf '%s\\0' "+QuoteCommand(shellArgs...)).Output()
+	if err != nil {
+		t.Fatalf("sh failed: %v", err)
+	}
+	got := strings.Split(strings.TrimSuffix(string(out), "\x00"), "\x00")
+	if !reflect.DeepEqual(got, shellArgs) {
+		t.Errorf("Shell arguments = %q, want %q", got, shellArgs)
+	}
+}
+
+func TestQuoteCommandFirstWord(t *testing.T) {
+	tests := []struct {
+		got  string
+		want string
+	}{
+		{QuoteCommand("FOO=bar", "echo", "hi"), `'FOO=bar' echo hi`},
+		{QuoteCommand("if", "then"), `'if' then`},
+		{QuoteCommand("echo", "if", "a=b"), `echo if a=b`},
+		{QuoteCommand("%1", "x"), `'%1' x`},
+		{QuoteCommand("time", "ls"), `'time' ls`},
+		{QuoteCommand("a+b"), `'a+b'`},
+		{QuoteCommand("/opt/c++/bin/g++", "-v"), `/opt/c++/bin/g++ -v`},
+		{QuoteCommand("./x=/y"), `'./x=/y'`},
+		{QuoteCommand("my-tool_2.sh"), `my-tool_2.sh`},
+		{NewShellCommand("if").Pipe("cat").String(), `'if' | cat`},
+		{NewShellCommand("true").And("done").Or("x=1").String(), `true && 'done' || 'x=1'`},
+	}
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("Command line = %s, want %s", tt.got, tt.want)
+		}
+	}
+
+	if runtime.GOOS == windowsOS {
+		return
+	}
+	// The shell must run FOO=bar as a program instead of assigning it
+	out, err := exec.Command("sh", "-c", QuoteCommand("FOO=bar", "echo", "hi")).Output()
+	if err == nil || strings.Contains(string(out), "hi") {
+		t.Errorf("sh ran echo with output %q, error %v, want FOO=bar not found", out, err)
+	}
+}
+
+func TestCommandLine(t *testing.T) {
+	proc := New("grep", "-e", "a b", "it's")
+	if got, want := proc.CommandLine(), `grep -e 'a b' 'it'\''s'`; got != want {
+		t.Errorf("CommandLine() = %s, want %s", got, want)
+	}
+}
+
+func TestShellCommand(t *testing.T) {
+	cmd := NewShellCommand("grep", "-e", "a;b", "in file").
+		Pipe("sort", "-u").
+		RedirectOut("/tmp/out $x").
+		ErrToOut().
+		And("echo", "done").
+		Or("echo", "failed: $?").
+		Then("rm", "-f", "tmp*")
+	want := `grep -e 'a;b' 'in file' | sort -u > '/tmp/out $x' 2>&1 && echo done || echo 'failed: $?'; rm -f 'tmp*'`
+	if cmd.String() != want {
+		t.Errorf("String() = %s, want %s", cmd, want)
+	}
+
+	cmd = NewShellCommand("cat").RedirectIn("in").AppendOut("out").RedirectErr("err")
+	if want := "cat < in >> out 2> err"; cmd.String() != want {
+		t.Errorf("String() = %s, want %s", cmd, want)
+	}
+}
+
+func TestShellCommandEmptyCommand(t *testing.T) {
+	tests := []struct {
+		name string
+		cmd  *ShellCommand
+	}{
+		{"program", NewShellCommand()},
+		{"pipe", NewShellCommand("ls").Pipe()},
+		{"and", NewShellCommand("ls").And()},
+		{"or", NewShellCommand("ls").Or().ErrToOut()},
+		{"then", NewShellCommand("ls").Then().Pipe("wc", "-l")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.cmd.Err() == nil {
+				t.Fatalf("Err() = nil for %q", tt.cmd)
+			}
+			if strings.HasSuffix(strings.TrimSpace(tt.cmd.String()), "|") ||
+				strings.HasSuffix(strings.TrimSpace(tt.cmd.String()), "&&") {
+				t.Errorf("String() = %q, has a dangling operator", tt.cmd)
+			}
+			if proc, err := tt.cmd.Process(); err == nil || proc != nil {
+				t.Errorf("Process() = %v, %v, want an error", proc, err)
+			}
+		})
+	}
+
+	if cmd := NewShellCommand("ls").ErrToOut(); cmd.Err() != nil || cmd.String() != "ls 2>&1" {
+		t.Errorf("ErrToOut() = %q, %v", cmd, cmd.Err())
+	}
+}
+
+func TestShellCommandProcess(t *testing.T) {
+	if runtime.GOOS == windowsOS {
+		t.Skip("no POSIX shell on Windows")
+	}
+	proc, err := NewShellCommand("echo", "a;b", "$HOME").Pipe("tr", "a", "x").Process()
+	if err != nil {
+		t.Fatalf("Process() failed: %v", err)
+	}
+	stdout, _, err := proc.Run()
+	if err != nil {
+		t.Fatalf("Run() failed: %v", err)
+	}
+	var lines []string
+	for line := range stdout {
+		lines = append(lines, line)
+	}
+	if want := "x;b $HOME"; strings.Join(lines, "\n") != want {
+		t.Errorf("Output = %q, want %s", lines, want)
+	}
+}
+
+func TestSplitCommand(t *testing.T) {
+	tests := []struct {
+		line string
+		want []string
+	}{
+		{"", nil},
+		{"  ls   -l\t/tmp \n", []string{"ls", "-l", "/tmp"}},
+		{`echo 'a b' "c d" e\ f`, []string{"echo", "a b", "c d", "e f"}},
+		{`echo "x\"y\\z\a" ''`, []string{"echo", `x"y\z\a`, ""}},
+		{`echo a''b "c"'d'e`, []string{"echo", "ab", "cde"}},
+		{"echo one \\\ntwo", []string{"echo", "one", "two"}},
+		{"ls *.go ~ # list files\necho x#y", []string{"ls", "*.go", "~", "echo", "x#y"}},
+		{`echo '$HOME' \$HOME "\$HOME" '|;&'`, []string{"echo", "$HOME", "$HOME", "$HOME", "|;&"}},
+	}
+	for _, tt := range tests {
+		got, err := SplitCommand(tt.line)
+		if err != nil || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("SplitCommand(%q) = %q, %v, want %q", tt.line, got, err, tt.want)
+		}
+	}
+}
+
+func TestSplitCommandUnsupported(t *testing.T) {
+	for _, line := range []string{
+		"ls | wc", "a && b", "a; b", "cat < in", "echo > out", "(sub)", "echo $HOME",
+		"echo `id`", `echo "$HOME"`, `echo "x`, "echo 'x", `echo x\`, "a &",
+	} {
+		if args, err := SplitCommand(line); !errors.Is(err, ErrShellSyntax) {
+			t.Errorf("SplitCommand(%q) = %q, %v, want ErrShellSyntax", line, args, err)
+		}
+	}
+}
@@ -0,0 +1,212 @@
+//go:build linux
+
+// Package processctrl Linux pause state reconciliation
+//
+// This file keeps the paused state of processes in sync with the kernel.
+// A process can be stopped and continued outside of processctrl, e.g. by
+// kill -STOP/-CONT or SIGTSTP from its terminal, so the stopped state
+// reported in /proc/<pid>/stat is polled periodically and Pause/Resume
+// wait until the kernel confirms the transition.
+
+package processctrl
+
+import (
+	"fmt"
+	"os"
+	"time"
+)
+
+const (
+	// stateCheckInterval is how often the kernel's view of the stopped
+	// state is polled.
+	stateCheckInterval = 100 * time.Millisecond
+	// stateConfirmTimeout is how long Pause and Resume wait for the
+	// kernel to confirm the transition.
+	stateConfirmTimeout = 2 * time.Second
+	// stateConfirmPoll is the polling interval while waiting for
+	// confirmation.
+	stateConfirmPoll = time.Millisecond
+)
+
+// readStopped reports whether the process is stopped (by a signal or a
+// tracer). It returns os.ErrProcessDone if the process has exited or the
+// PID now refers to a different process.
+func readStopped(pid int, startTicks uint64) (bool, error) {
+	st, err := readProcStat(pid)
+	if err != nil {
+		return false, os.ErrProcessDone
+	}
+	if st.startTime != startTicks || st.state == 'Z' || st.state == 'X' {
+		return false, os.ErrProcessDone
+	}
+	return st.state == 'T' || st.state == 't', nil
+}
+
+// waitStopped polls until the stopped state of the process equals want or
+// the timeout expires.
+func waitStopped(pid int, startTicks uint64, want bool, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		stopped, err := readStopped(pid, startTicks)
+		if err != nil {
+			return err
+		}
+		if stopped == want {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			if want {
+				return fmt.Errorf("process did not stop within %v", timeout)
+			}
+			return fmt.Errorf("process did not continue within %v", timeout)
+		}
+		time.Sleep(stateConfirmPoll)
+	}
+}
+
+// watchStopped calls update every stateCheckInterval until update returns
+// false or done is closed. update reads the stopped state itself while
+// holding the owner's lock, so that an observation made before a
+// concurrent Pause or Resume is never applied after it.
+func watchStopped(done <-chan struct{}, update func() bool) {
+	ticker := time.NewTicker(stateCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+		}
+
+		if !update() {
+			return
+		}
+	}
+}
+
+// watchState records the start time of the program and starts
+// reconciling its paused state with the kernel. In a sandbox, this is the
+// state of the program rather than of the init process.
+// It must be called with p.mu held after the process has been started.
+func (p *Process) watchState() {
+	pid := p.programPID
+	st, err := readProcStat(pid)
+	if err != nil {
+		return
+	}
+	p.startTicks = st.startTime
+
+	go watchStopped(p.exited, func() bool {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+
+		if !p.running {
+			return false
+		}
+		if p.throttleStopped || p.transitioning {
+			// Stopped by the CPU throttle, or Pause or Resume is in control
+			return true
+		}
+		stopped, err := readStopped(pid, p.startTicks)
+		if err != nil {
+			return false
+		}
+		if !stopped && p.cgroupFreezes() {
+			// Frozen tasks are not reported as stopped in /proc
+			if frozen, err := cgroupFrozen(p.cgroup.path); err == nil {
+				stopped = frozen
+			}
+		}
+		if stopped != p.paused {
+			p.setPaused(stopped)
+			if !stopped {
+				p.cancelScheduledResume("continued outside processctrl")
+			}
+			p.events.emit(stopEventType(stopped), p.cmd.Process.Pid, "detected in /proc")
+		}
+		return true
+	})
+}
+
+// confirmStopped waits until the kernel reports the wanted stopped state
+// of the program. In a sandbox, the other processes are given the rest of
+// the timeout to follow, but only the program has to confirm.
+// It must be called with p.mu held, which is released during the wait.
+func (p *Process) confirmStopped(want bool) error {
+	if p.startTicks == 0 {
+		// Start time unknown, the state cannot be observed
+		return nil
+	}
+	pid, startTicks := p.programPID, p.startTicks
+	var others []int
+	if p.sandbox != nil {
+		others, _ = p.sandboxPids() // Ignore error - only the program must confirm
+	}
+	return p.waitUnlocked(func() error {
+		deadline := time.Now().Add(stateConfirmTimeout)
+		if err := waitStopped(pid, startTicks, want, stateConfirmTimeout); err != nil {
+			return err
+		}
+		for _, other := range others {
+			if st, err := readProcStat(other); err == nil && other != pid {
+				_ = waitStopped(other, st.startTime, want, time.Until(deadline)) // Ignore error - the process might have exited
+			}
+		}
+		return nil
+	})
+}
+
+// isStopped reports whether the kernel currently sees the program as
+// stopped. It does not need p.mu, since the PID and start time do not
+// change while the process runs.
+func (p *Process) isStopped() (bool, error) {
+	if p.startTicks == 0 {
+		return false, ErrNotSupported
+	}
+	return readStopped(p.programPID, p.startTicks)
+}
+
+// watchState starts reconciling the paused state of the attached process
+// with the kernel.
+func (a *AttachedProcess) watchState() {
+	go watchStopped(a.stop, func() bool {
+		a.mu.Lock()
+		defer a.mu.Unlock()
+
+		if a.checkRunning() != nil {
+			return false
+		}
+		if a.transitioning {
+			// Pause or Resume is in control
+			return true
+		}
+		stopped, err := readStopped(a.pid, a.handle.startTime)
+		if err != nil {
+			return false
+		}
+		if stopped != a.paused {
+			a.paused = stopped
+			a.events.emit(stopEventType(stopped), a.pid, "detected in /proc")
+		}
+		return true
+	})
+}
+
+// confirmStopped waits until the kernel reports the wanted stopped state
+// for the attached process.
+// It must be called with a.mu held, which is released during the wait.
+func (a *AttachedProcess) confirmStopped(want bool) error {
+	startTime := a.handle.startTime
+	return a.waitUnlocked(func() error {
+		return waitStopped(a.pid, startTime, want, stateConfirmTimeout)
+	})
+}
+
+// stopEventType returns the event reported for an external state change.
+func stopEventType(stopped bool) EventType {
+	if stopped {
+		return EventStopped
+	}
+	return EventContinued
+}
@@ -0,0 +1,188 @@
+//go:build linux
+
+package processctrl
+
+import (
+	"syscall"
+	"testing"
+	"time"
+)
+
+// waitForEvent returns the first event of the given type received within
+// the timeout, skipping other events.
+func waitForEvent(t *testing.T, events <-chan Event, typ EventType) Event {
+	t.Helper()
+
+	timeout := time.After(testTimeout * time.Second)
+	for {
+		select {
+		case ev, ok := <-events:
+			if !ok {
+				t.Fatalf("event channel closed while waiting for %v", typ)
+			}
+			if ev.Type == typ {
+				return ev
+			}
+		case <-timeout:
+			t.Fatalf("timed out waiting for %v event", typ)
+		}
+	}
+}
+
+func TestPauseConfirmedByKernel(t *testing.T) {
+	proc := New("sleep", "10")
+
+	stdout, stderr, err := proc.Run()
+	if err != nil {
+		t.Fatalf("Run() failed: %v", err)
+	}
+	go func() {
+		for range stderr {
+		}
+	}()
+	go func() {
+		for range stdout {
+		}
+	}()
+	defer func() { _ = proc.Kill() }()
+
+	if err := proc.Pause(); err != nil {
+		t.Fatalf("Pause() failed: %v", err)
+	}
+
+	// The kernel must already report the process as stopped
+	st, err := readProcStat(proc.PID())
+	if err != nil {
+		t.Fatalf("readProcStat() failed: %v", err)
+	}
+	if st.state != 'T' {
+		t.Errorf("Expected state T after Pause(), got %c", st.state)
+	}
+	waitForEvent(t, proc.Events(), EventPaused)
+
+	if err := proc.Resume(); err != nil {
+		t.Fatalf("Resume() failed: %v", err)
+	}
+	st, err = readProcStat(proc.PID())
+	if err != nil {
+		t.Fatalf("readProcStat() failed: %v", err)
+	}
+	if st.state == 'T' {
+		t.Error("Process still stopped after Resume()")
+	}
+	waitForEvent(t, proc.Events(), EventResumed)
+}
+
+func TestExternalStopContinue(t *testing.T) {
+	proc := New("sleep", "10")
+
+	stdout, stderr, err := proc.Run()
+	if err != nil {
+		t.Fatalf("Run() failed: %v", err)
+	}
+	go func() {
+		for range stderr {
+		}
+	}()
+	go func() {
+		for range stdout {
+		}
+	}()
+	defer func() { _ = proc.Kill() }()
+
+	if err := syscall.Kill(proc.PID(), syscall.SIGSTOP); err != nil {
+		t.Fatalf("kill -STOP failed: %v", err)
+	}
+	waitForEvent(t, proc.Events(), EventStopped)
+	if !proc.IsPaused() {
+		t.Error("IsPaused() should report an externally stopped process")
+	}
+
+	if err := syscall.Kill(proc.PID(), syscall.SIGCONT); err != nil {
+		t.Fatalf("kill -CONT failed: %v", err)
+	}
+	waitForEvent(t, proc.Events(), EventContinued)
+	if proc.IsPaused() {
+		t.Error("IsPaused() should report an externally continued process")
+	}
+
+	// Once continued externally, Resume has nothing to do
+	if err := proc.Resume(); err == nil {
+		t.Error("Resume() should fail on a running process")
+	}
+}
+
+func TestAttachExternalStop(t *testing.T) {
+	cmd := startExternal(t, "sleep", "10")
+
+	proc, err := Attach(cmd.Process.Pid)
+	if err != nil {
+		t.Fatalf("Attach() failed: %v", err)
+	}
+	defer func() { _ = proc.Kill() }()
+
+	if err := syscall.Kill(cmd.Process.Pid, syscall.SIGSTOP); err != nil {
+		t.Fatalf("kill -STOP failed: %v", err)
+	}
+	waitForEvent(t, proc.Events(), EventStopped)
+
+	if err := proc.Resume(); err != nil {
+		t.Fatalf("Resume() of externally stopped process failed: %v", err)
+	}
+	waitForEvent(t, proc.Events(), EventResumed)
+}
+
+func TestConfirmReleasesLock(t *testing.T) {
+	proc := New("sleep", "10")
+
+	stdout, stderr, err := proc.Run()
+	if err != nil {
+		t.Fatalf("Run() failed: %v", err)
+	}
+	go func() {
+		for range stderr {
+		}
+	}()
+	go func() {
+		for range stdout {
+		}
+	}()
+	defer func() { _ = proc.Kill() }()
+
+	// Simulate a transition that takes long to be confirmed
+	waiting := make(chan struct{})
+	release := make(chan struct{})
+	done := make(chan error, 1)
+	go func() {
+		proc.mu.Lock()
+		defer proc.mu.Unlock()
+		done <- proc.waitUnlocked(func() error {
+			close(waiting)
+			<-release
+			return nil
+		})
+	}()
+	<-waiting
+
+	queried := make(chan bool)
+	go func() { queried <- proc.IsRunning() && !proc.IsPaused() }()
+	select {
+	case ok := <-queried:
+		if !ok {
+			t.Error("Expected a running, unpaused process during the transition")
+		}
+	case <-time.After(testTimeout * time.Second):
+		t.Fatal("IsRunning() and IsPaused() blocked during the transition")
+	}
+	if err := proc.Pause(); err == nil {
+		t.Error("Pause() should fail while a transition is in progress")
+	}
+
+	close(release)
+	if err := <-done; err != nil {
+		t.Errorf("waitUnlocked() failed: %v", err)
+	}
+	if err := proc.Pause(); err != nil {
+		t.Errorf("Pause() after the transition failed: %v", err)
+	}
+}
@@ -0,0 +1,30 @@
+//go:build !linux
+
+// Package processctrl pause state fallback
+//
+// This file contains the pause state handling for platforms where the
+// kernel's view of the stopped state is not observed. The paused state
+// only reflects calls to Pause and Resume.
+
+package processctrl
+
+// watchState is a no-op on platforms without state reconciliation.
+func (p *Process) watchState() {}
+
+// confirmStopped is a no-op on platforms without state reconciliation.
+func (p *Process) confirmStopped(bool) error {
+	return nil
+}
+
+// isStopped is not supported on platforms without state reconciliation.
+func (p *Process) isStopped() (bool, error) {
+	return false, ErrNotSupported
+}
+
+// watchState is a no-op on platforms without state reconciliation.
+func (a *AttachedProcess) watchState() {}
+
+// confirmStopped is a no-op on platforms without state reconciliation.
+func (a *AttachedProcess) confirmStopped(bool) error {
+	return nil
+}
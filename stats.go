@@ -0,0 +1,176 @@
+package processctrl
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// Stats is a snapshot of the resources used by a process, or by a process
+// and all of its descendants.
+type Stats struct {
+	Time                   time.Time     // Time the snapshot was taken
+	PID                    int           // Process ID
+	State                  string        // Kernel scheduling state of the process (e.g. "R", "S", "T")
+	Processes              int           // Number of processes included in the snapshot
+	UserTime               time.Duration // CPU time spent in user mode
+	SystemTime             time.Duration // CPU time spent in kernel mode
+	CPUPercent             float64       // CPU usage since the previous snapshot, in percent of one CPU
+	RSS                    uint64        // Resident set size in bytes
+	VSZ                    uint64        // Virtual memory size in bytes
+	Threads                int           // Number of threads
+	FDs                    int           // Number of open file descriptors
+	ReadBytes              uint64        // Bytes read through system calls
+	WriteBytes             uint64        // Bytes written through system calls
+	DiskReadBytes          uint64        // Bytes fetched from storage
+	DiskWriteBytes         uint64        // Bytes sent to storage
+	VoluntaryCtxSwitches   uint64        // Context switches while waiting for a resource
+	InvoluntaryCtxSwitches uint64        // Context switches forced by the scheduler
+}
+
+// CPUTime returns the total CPU time of the snapshot.
+func (s *Stats) CPUTime() time.Duration {
+	return s.UserTime + s.SystemTime
+}
+
+// cpuSample is the CPU time of a process at the time of a snapshot. It is
+// used to compute the usage between consecutive snapshots.
+type cpuSample struct {
+	cpu time.Duration
+	at  time.Time
+}
+
+// update computes the CPU usage of s since the previous sample and
+// records s as the new previous sample. The first sample reports the
+// average usage since the process started.
+func (c *cpuSample) update(s *Stats, started time.Time) {
+	prev := *c
+	if prev.at.IsZero() {
+		prev.at = started
+	}
+	if elapsed := s.Time.Sub(prev.at); !prev.at.IsZero() && elapsed > 0 {
+		// Exited descendants may take their CPU time with them
+		if used := s.CPUTime() - prev.cpu; used > 0 {
+			s.CPUPercent = 100 * float64(used) / float64(elapsed)
+		}
+	}
+	*c = cpuSample{cpu: s.CPUTime(), at: s.Time}
+}
+
+// Stats returns the current resource usage of the process, read from
+// /proc/<pid>. CPUPercent is the usage since the previous call to Stats,
+// or since the process started for the first call.
+//
+// Only Linux is supported; other platforms return ErrNotSupported.
+//
+// Returns an error if the process is not running or its statistics
+// cannot be read.
+func (p *Process) Stats() (*Stats, error) {
+	return p.readStats(false, &p.statsCPU)
+}
+
+// TreeStats returns the resource usage of the process and all of its
+// descendants, summed up. CPU time includes descendants that have already
+// exited and been waited for, so CPUPercent is computed across the whole
+// tree. State refers to the process itself.
+//
+// Only Linux is supported; other platforms return ErrNotSupported.
+//
+// Returns an error if the process is not running or its statistics
+// cannot be read.
+func (p *Process) TreeStats() (*Stats, error) {
+	return p.readStats(true, &p.treeCPU)
+}
+
+// readStats reads a snapshot and computes its CPU usage relative to prev.
+func (p *Process) readStats(tree bool, prev *cpuSample) (*Stats, error) {
+	p.mu.RLock()
+	if !p.running || p.cmd == nil || p.cmd.Process == nil {
+		p.mu.RUnlock()
+		return nil, fmt.Errorf("process is not running: %w", os.ErrProcessDone)
+	}
+	pid, startTicks := p.programPID, p.startTicks
+	p.mu.RUnlock()
+
+	s, started, err := readStats(pid, startTicks, tree)
+	if err != nil {
+		return nil, err
+	}
+
+	p.statsMu.Lock()
+	prev.update(s, started)
+	p.statsMu.Unlock()
+	return s, nil
+}
+
+// StatsSampler delivers periodic resource snapshots of a process.
+type StatsSampler struct {
+	C        <-chan *Stats // Receives the snapshots
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+// Stop ends sampling and closes C. It is safe to call Stop more than
+// once.
+func (s *StatsSampler) Stop() {
+	s.stopOnce.Do(func() { close(s.stop) })
+}
+
+// SampleStats starts taking a snapshot every interval and delivering it
+// on the returned sampler's channel, which is closed when the process
+// exits or the sampler is stopped. Like time.Ticker, the sampler drops
+// snapshots if the receiver falls behind. Each sampler tracks CPU usage
+// independently of Stats, TreeStats and other samplers.
+//
+// Parameters:
+//   - interval: Time between snapshots; must be positive
+//   - tree: Aggregate over all descendants as with TreeStats
+//
+// Returns an error if the interval is invalid, the process is not running
+// or statistics are not supported on this platform.
+func (p *Process) SampleStats(interval time.Duration, tree bool) (*StatsSampler, error) {
+	if interval <= 0 {
+		return nil, fmt.Errorf("invalid sampling interval: %v", interval)
+	}
+
+	// Take the first sample synchronously to report errors to the caller
+	var prev cpuSample
+	first, err := p.readStats(tree, &prev)
+	if err != nil {
+		return nil, err
+	}
+
+	ch := make(chan *Stats, 1)
+	s := &StatsSampler{C: ch, stop: make(chan struct{})}
+	ch <- first
+
+	go func() {
+		defer close(ch)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-s.stop:
+				return
+			case <-p.exited:
+				return
+			case <-ticker.C:
+			}
+
+			stats, err := p.readStats(tree, &prev)
+			if err != nil {
+				return
+			}
+			select {
+			case ch <- stats:
+			default:
+				// Receiver is behind, drop the snapshot
+			}
+		}
+	}()
+
+	return s, nil
+}
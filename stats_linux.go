@@ -0,0 +1,142 @@
+//go:build linux
+
+// Package processctrl Linux resource statistics
+//
+// This file collects resource usage snapshots from /proc/<pid>/stat,
+// status, io and fd, optionally summed up over all descendants of a
+// process.
+
+package processctrl
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// statsSupported reports whether Stats and watchdogs are available.
+const statsSupported = true
+
+// pageSize is the size of a memory page, the unit of RSS in
+// /proc/<pid>/stat.
+var pageSize = uint64(os.Getpagesize())
+
+// readStats reads a snapshot of pid and, if tree is set, all of its
+// descendants. It also returns the start time of pid, or the zero time if
+// it is unknown.
+func readStats(pid int, startTicks uint64, tree bool) (*Stats, time.Time, error) {
+	root, err := readProcStat(pid)
+	if errors.Is(err, os.ErrNotExist) || errors.Is(err, syscall.ESRCH) ||
+		err == nil && startTicks != 0 && root.startTime != startTicks {
+		return nil, time.Time{}, fmt.Errorf("process is not running: %w", os.ErrProcessDone)
+	}
+	if err != nil {
+		return nil, time.Time{}, fmt.Errorf("failed to read process stat: %w", err)
+	}
+
+	var started time.Time
+	if bt, err := readBootTime(); err == nil {
+		started = bt.Add(time.Duration(root.startTime) * time.Second / clockTicks)
+	}
+
+	s := &Stats{Time: time.Now(), PID: pid, State: string(root.state)}
+	addProcStats(s, pid, root, tree)
+
+	if tree {
+		for _, child := range descendants(pid) {
+			if st, err := readProcStat(child); err == nil {
+				addProcStats(s, child, st, true)
+			}
+		}
+	}
+	return s, started, nil
+}
+
+// addProcStats adds the resource usage of a single process to s. With
+// withChildren, the CPU time of its waited-for children is included.
+// Files other than stat are skipped if they are not readable, e.g. for
+// processes that changed their credentials.
+func addProcStats(s *Stats, pid int, st *procStat, withChildren bool) {
+	utime, stime := st.utime, st.stime
+	if withChildren {
+		utime += st.cutime
+		stime += st.cstime
+	}
+
+	s.Processes++
+	s.UserTime += time.Duration(utime) * time.Second / clockTicks
+	s.SystemTime += time.Duration(stime) * time.Second / clockTicks
+	s.RSS += uint64(max(st.rss, 0)) * pageSize
+	s.VSZ += st.vsize
+	s.Threads += st.threads
+
+	if entries, err := os.ReadDir(fmt.Sprintf("/proc/%d/fd", pid)); err == nil {
+		s.FDs += len(entries)
+	}
+
+	if status, err := readProcStatus(pid); err == nil {
+		s.VoluntaryCtxSwitches += parseUintField(status["voluntary_ctxt_switches"])
+		s.InvoluntaryCtxSwitches += parseUintField(status["nonvoluntary_ctxt_switches"])
+	}
+
+	if data, err := os.ReadFile(fmt.Sprintf("/proc/%d/io", pid)); err == nil {
+		for _, line := range strings.Split(string(data), "\n") {
+			key, value, ok := strings.Cut(line, ":")
+			if !ok {
+				continue
+			}
+			v := parseUintField(value)
+			switch key {
+			case "rchar":
+				s.ReadBytes += v
+			case "wchar":
+				s.WriteBytes += v
+			case "read_bytes":
+				s.DiskReadBytes += v
+			case "write_bytes":
+				s.DiskWriteBytes += v
+			}
+		}
+	}
+}
+
+// parseUintField parses a numeric /proc field, returning 0 if it is
+// missing or malformed.
+func parseUintField(value string) uint64 {
+	v, _ := strconv.ParseUint(strings.TrimSpace(value), 10, 64)
+	return v
+}
+
+// descendants returns the process IDs of all descendants of pid, found by
+// scanning the parent process IDs of all processes in /proc.
+func descendants(pid int) []int {
+	entries, err := os.ReadDir("/proc")
+	if err != nil {
+		return nil
+	}
+
+	children := make(map[int][]int)
+	for _, e := range entries {
+		child, err := strconv.Atoi(e.Name())
+		if err != nil {
+			continue
+		}
+		if st, err := readProcStat(child); err == nil {
+			children[st.ppid] = append(children[st.ppid], child)
+		}
+	}
+
+	var out []int
+	queue := children[pid]
+	for len(queue) > 0 {
+		next := queue[0]
+		queue = queue[1:]
+		out = append(out, next)
+		queue = append(queue, children[next]...)
+	}
+	return out
+}
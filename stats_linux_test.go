@@ -0,0 +1,172 @@
+//go:build linux
+
+package processctrl
+
+import (
+	"errors"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestStats(t *testing.T) {
+	proc := New("sh", "-c", "true")
+	if _, err := proc.Stats(); err == nil {
+		t.Error("Stats() should fail before the process is started")
+	}
+
+	// Busy loop writing to stdout
+	proc = New("sh", "-c", "while :; do echo line; done")
+	startTest(t, proc)
+
+	time.Sleep(200 * time.Millisecond)
+	if _, err := proc.Stats(); err != nil {
+		t.Fatalf("Stats() failed: %v", err)
+	}
+	time.Sleep(300 * time.Millisecond)
+	s, err := proc.Stats()
+	if err != nil {
+		t.Fatalf("Stats() failed: %v", err)
+	}
+
+	if s.PID != proc.PID() || s.Processes != 1 {
+		t.Errorf("Unexpected PID %d or process count %d", s.PID, s.Processes)
+	}
+	if s.CPUPercent < 10 {
+		t.Errorf("Busy loop should use CPU, got %.1f%%", s.CPUPercent)
+	}
+	if s.CPUTime() <= 0 {
+		t.Errorf("CPU time should be positive, got %v", s.CPUTime())
+	}
+	if s.RSS == 0 || s.VSZ < s.RSS {
+		t.Errorf("Unexpected memory usage: RSS %d, VSZ %d", s.RSS, s.VSZ)
+	}
+	if s.Threads < 1 {
+		t.Errorf("Expected at least one thread, got %d", s.Threads)
+	}
+	if s.FDs < 3 {
+		t.Errorf("Expected at least the standard streams to be open, got %d fds", s.FDs)
+	}
+	if s.WriteBytes == 0 {
+		t.Error("Process writing to stdout should have written bytes")
+	}
+	if s.VoluntaryCtxSwitches+s.InvoluntaryCtxSwitches == 0 {
+		t.Error("Expected context switches")
+	}
+
+	if err := proc.Pause(); err != nil {
+		t.Fatalf("Pause() failed: %v", err)
+	}
+	if s, err = proc.Stats(); err != nil {
+		t.Fatalf("Stats() failed: %v", err)
+	}
+	if s.State != "T" {
+		t.Errorf("Expected state T for paused process, got %q", s.State)
+	}
+}
+
+func TestTreeStats(t *testing.T) {
+	proc := New("sh", "-c", "sleep 10 & sleep 10 & wait")
+	startTest(t, proc)
+
+	// Wait for the children to be started
+	deadline := time.Now().Add(testTimeout * time.Second)
+	for {
+		s, err := proc.TreeStats()
+		if err != nil {
+			t.Fatalf("TreeStats() failed: %v", err)
+		}
+		if s.Processes == 3 {
+			single, err := proc.Stats()
+			if err != nil {
+				t.Fatalf("Stats() failed: %v", err)
+			}
+			if s.RSS <= single.RSS || s.Threads <= single.Threads {
+				t.Errorf("Tree should use more resources than the process alone: %+v vs %+v", s, single)
+			}
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected 3 processes in the tree, got %d", s.Processes)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestSampleStats(t *testing.T) {
+	proc := New("sh", "-c", "sleep 10")
+	startTest(t, proc)
+
+	if _, err := proc.SampleStats(0, false); err == nil {
+		t.Error("SampleStats() should fail with a zero interval")
+	}
+
+	sampler, err := proc.SampleStats(20*time.Millisecond, false)
+	if err != nil {
+		t.Fatalf("SampleStats() failed: %v", err)
+	}
+
+	var last time.Time
+	for i := 0; i < 3; i++ {
+		select {
+		case s := <-sampler.C:
+			if !s.Time.After(last) {
+				t.Errorf("Snapshot %d is not newer than the previous one", i)
+			}
+			last = s.Time
+		case <-time.After(testTimeout * time.Second):
+			t.Fatal("Timed out waiting for snapshot")
+		}
+	}
+
+	sampler.Stop()
+	sampler.Stop()
+	timeout := time.After(testTimeout * time.Second)
+	for {
+		select {
+		case _, ok := <-sampler.C:
+			if !ok {
+				return
+			}
+		case <-timeout:
+			t.Fatal("Sampler channel should be closed after Stop()")
+		}
+	}
+}
+
+func TestSampleStatsClosedOnExit(t *testing.T) {
+	proc := New("sh", "-c", "sleep 0.2")
+	startTest(t, proc)
+
+	sampler, err := proc.SampleStats(20*time.Millisecond, true)
+	if err != nil {
+		t.Fatalf("SampleStats() failed: %v", err)
+	}
+
+	timeout := time.After(testTimeout * time.Second)
+	for {
+		select {
+		case _, ok := <-sampler.C:
+			if !ok {
+				return
+			}
+		case <-timeout:
+			t.Fatal("Sampler channel should be closed when the process exits")
+		}
+	}
+}
+
+func TestReadStatsExitedIsProcessDone(t *testing.T) {
+	// The watchdog stops only for an exited process, so the error must
+	// tell it apart from a failed read
+	proc := New("true")
+	startTest(t, proc)
+	waitResult(t, proc)
+
+	if _, _, err := readStats(proc.PID(), proc.startTicks, false); !errors.Is(err, os.ErrProcessDone) {
+		t.Errorf("readStats() error = %v, want %v", err, os.ErrProcessDone)
+	}
+	if _, err := proc.Stats(); !errors.Is(err, os.ErrProcessDone) {
+		t.Errorf("Stats() error = %v, want %v", err, os.ErrProcessDone)
+	}
+}
@@ -0,0 +1,18 @@
+//go:build !linux
+
+// Package processctrl resource statistics stub
+//
+// Resource statistics are read from /proc and are only available on
+// Linux.
+
+package processctrl
+
+import "time"
+
+// statsSupported reports whether Stats and watchdogs are available.
+const statsSupported = false
+
+// readStats is not supported on this platform.
+func readStats(int, uint64, bool) (*Stats, time.Time, error) {
+	return nil, time.Time{}, ErrNotSupported
+}
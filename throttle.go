@@ -0,0 +1,49 @@
+package processctrl
+
+import (
+	"fmt"
+	"math"
+)
+
+// SetCPULimit throttles the process to the given CPU usage by rapidly
+// alternating SIGSTOP and SIGCONT, similar to cpulimit. The limit is a
+// percentage of one CPU, so values above 100 are valid for multi-threaded
+// processes. A limit of 0 removes the throttle and continues the process.
+//
+// The limit can be set before the process is started and changed at any
+// time while it runs. Throttling is suspended while the process is paused
+// with Pause, PauseFor or PauseUntil, and resumes with Resume. The
+// throttle only affects the process itself, not its children.
+//
+// Only Linux is supported, since CPU usage is measured through /proc;
+// other platforms return ErrNotSupported.
+//
+// Parameters:
+//   - pct: Target CPU usage in percent of one CPU, or 0 to disable
+//
+// Returns an error if the limit is negative or not supported.
+func (p *Process) SetCPULimit(pct float64) error {
+	if pct < 0 || math.IsNaN(pct) || math.IsInf(pct, 0) {
+		return fmt.Errorf("invalid CPU limit: %v", pct)
+	}
+	if !throttleSupported {
+		return ErrNotSupported
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.cpuLimit = pct
+	if p.running {
+		p.startThrottle()
+	}
+	return nil
+}
+
+// CPULimit returns the CPU limit set with SetCPULimit, or 0 if the process
+// is not throttled.
+func (p *Process) CPULimit() float64 {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.cpuLimit
+}
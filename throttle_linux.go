@@ -0,0 +1,162 @@
+//go:build linux
+
+// Package processctrl Linux CPU throttling
+//
+// This file implements CPU duty-cycle throttling. The process runs for a
+// fraction of each throttle period and is stopped for the remainder. The
+// fraction is adjusted continuously based on the CPU time measured in
+// /proc/<pid>/stat, so the process converges to the target usage. In a
+// sandbox, the CPU time of the program is measured and every process in
+// the sandbox is stopped.
+
+package processctrl
+
+import (
+	"syscall"
+	"time"
+)
+
+const (
+	// throttleSupported reports whether SetCPULimit is available.
+	throttleSupported = true
+	// throttlePeriod is the length of one run/stop cycle.
+	throttlePeriod = 100 * time.Millisecond
+	// throttleMinRate is the smallest fraction of a period the process
+	// is allowed to run, so that usage can still be measured.
+	throttleMinRate = 0.01
+	// throttleSmoothing is the weight of the latest sample in the
+	// exponential moving average of the measured usage.
+	throttleSmoothing = 0.5
+)
+
+// startThrottle starts the throttle loop if a limit is set and the loop is
+// not already running.
+// It must be called with p.mu held while the process is running.
+func (p *Process) startThrottle() {
+	if p.cpuLimit <= 0 || p.throttling {
+		return
+	}
+	p.throttling = true
+	go p.throttle(p.programPID, p.startTicks)
+}
+
+// releaseThrottle continues the process if it is currently stopped by the
+// throttle, so that other operations see it running.
+// It must be called with p.mu held.
+func (p *Process) releaseThrottle() {
+	if p.throttleStopped {
+		_ = p.signalAll(syscall.SIGCONT) // Ignore error - process might already be dead
+		p.throttleStopped = false
+	}
+}
+
+// throttle is the throttle loop. It runs until the limit is removed or
+// the process exits.
+func (p *Process) throttle(pid int, startTicks uint64) {
+	rate := 1.0
+	usage := -1.0
+	lastCPU, err := readCPUTime(pid, startTicks)
+	lastTime := time.Now()
+
+	for err == nil {
+		p.mu.Lock()
+		limit := p.cpuLimit
+		if limit <= 0 || !p.running {
+			p.throttling = false
+			p.releaseThrottle()
+			p.mu.Unlock()
+			return
+		}
+		p.mu.Unlock()
+
+		var cpu time.Duration
+		if cpu, err = readCPUTime(pid, startTicks); err != nil {
+			break
+		}
+		now := time.Now()
+		if elapsed := now.Sub(lastTime); elapsed > 0 {
+			sample := float64(cpu-lastCPU) / float64(elapsed)
+			if usage < 0 {
+				usage = sample
+			} else {
+				usage = throttleSmoothing*sample + (1-throttleSmoothing)*usage
+			}
+		}
+		lastCPU, lastTime = cpu, now
+
+		rate = nextThrottleRate(rate, usage, limit/100)
+		run := time.Duration(rate * float64(throttlePeriod))
+
+		p.throttleSet(false)
+		if !p.throttleSleep(run) {
+			break
+		}
+		if rate < 1 {
+			p.throttleSet(true)
+			if !p.throttleSleep(throttlePeriod - run) {
+				break
+			}
+		}
+	}
+
+	p.mu.Lock()
+	p.throttling = false
+	p.mu.Unlock()
+}
+
+// nextThrottleRate adjusts the fraction of each period the process may run
+// so that the measured usage approaches the target.
+func nextThrottleRate(rate, usage, target float64) float64 {
+	switch {
+	case usage < 0:
+		return rate
+	case usage == 0:
+		// Idle or not yet measurable, allow it to run more
+		rate *= 2
+	default:
+		rate *= target / usage
+	}
+	return min(max(rate, throttleMinRate), 1)
+}
+
+// throttleSet stops or continues the process, or every process in its
+// sandbox, for the throttle, unless it is paused or being paused or
+// resumed, in which case Pause and Resume are in control.
+func (p *Process) throttleSet(stop bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if !p.running || p.paused || p.transitioning || p.cpuLimit <= 0 || stop == p.throttleStopped {
+		return
+	}
+
+	sig := syscall.SIGCONT
+	if stop {
+		sig = syscall.SIGSTOP
+	}
+	if p.signalAll(sig) == nil {
+		p.throttleStopped = stop
+	}
+}
+
+// throttleSleep waits for d and reports false if the process exited.
+func (p *Process) throttleSleep(d time.Duration) bool {
+	select {
+	case <-p.exited:
+		return false
+	case <-time.After(d):
+		return true
+	}
+}
+
+// readCPUTime returns the user and system CPU time consumed by the process.
+func readCPUTime(pid int, startTicks uint64) (time.Duration, error) {
+	st, err := readProcStat(pid)
+	if err != nil {
+		return 0, err
+	}
+	if st.startTime != startTicks {
+		return 0, syscall.ESRCH
+	}
+	return time.Duration(st.utime+st.stime) * time.Second / clockTicks, nil
+}
@@ -0,0 +1,97 @@
+//go:build linux
+
+package processctrl
+
+import (
+	"testing"
+	"time"
+)
+
+// measureCPU returns the CPU usage of the process over the given period
+// as a percentage of one CPU.
+func measureCPU(t *testing.T, proc *Process, period time.Duration) float64 {
+	t.Helper()
+
+	proc.mu.RLock()
+	pid, ticks := proc.programPID, proc.startTicks
+	proc.mu.RUnlock()
+
+	before, err := readCPUTime(pid, ticks)
+	if err != nil {
+		t.Fatalf("readCPUTime() failed: %v", err)
+	}
+	time.Sleep(period)
+	after, err := readCPUTime(pid, ticks)
+	if err != nil {
+		t.Fatalf("readCPUTime() failed: %v", err)
+	}
+	return 100 * float64(after-before) / float64(period)
+}
+
+func TestSetCPULimit(t *testing.T) {
+	proc := New("sh", "-c", "while :; do :; done")
+	if err := proc.SetCPULimit(20); err != nil {
+		t.Fatalf("SetCPULimit() failed: %v", err)
+	}
+	startTest(t, proc)
+
+	// Let the throttle converge before measuring
+	time.Sleep(time.Second)
+	if usage := measureCPU(t, proc, 2*time.Second); usage > 40 || usage < 5 {
+		t.Errorf("Expected CPU usage around 20%%, got %.1f%%", usage)
+	}
+	if proc.IsPaused() {
+		t.Error("Throttled process must not be reported as paused")
+	}
+
+	// Removing the limit lets the process run freely again
+	if err := proc.SetCPULimit(0); err != nil {
+		t.Fatalf("SetCPULimit(0) failed: %v", err)
+	}
+	time.Sleep(200 * time.Millisecond)
+	if usage := measureCPU(t, proc, time.Second); usage < 60 {
+		t.Errorf("Expected unthrottled CPU usage, got %.1f%%", usage)
+	}
+}
+
+func TestCPULimitWithPause(t *testing.T) {
+	proc := New("sh", "-c", "while :; do :; done")
+	if err := proc.SetCPULimit(30); err != nil {
+		t.Fatalf("SetCPULimit() failed: %v", err)
+	}
+	startTest(t, proc)
+	time.Sleep(300 * time.Millisecond)
+
+	if err := proc.Pause(); err != nil {
+		t.Fatalf("Pause() failed: %v", err)
+	}
+
+	// The throttle must not continue a paused process
+	if usage := measureCPU(t, proc, 500*time.Millisecond); usage > 0 {
+		t.Errorf("Paused process consumed %.1f%% CPU", usage)
+	}
+	if !proc.IsPaused() {
+		t.Error("Process should stay paused while throttled")
+	}
+
+	if err := proc.Resume(); err != nil {
+		t.Fatalf("Resume() failed: %v", err)
+	}
+	time.Sleep(500 * time.Millisecond)
+	if usage := measureCPU(t, proc, time.Second); usage == 0 || usage > 60 {
+		t.Errorf("Expected throttled CPU usage after Resume(), got %.1f%%", usage)
+	}
+}
+
+func TestSetCPULimitInvalid(t *testing.T) {
+	proc := New("sleep", "1")
+	if err := proc.SetCPULimit(-1); err == nil {
+		t.Error("SetCPULimit() should reject a negative limit")
+	}
+	if err := proc.SetCPULimit(50); err != nil {
+		t.Errorf("SetCPULimit() before start failed: %v", err)
+	}
+	if got := proc.CPULimit(); got != 50 {
+		t.Errorf("CPULimit() = %v, want 50", got)
+	}
+}
@@ -0,0 +1,24 @@
+//go:build !linux
+
+// Package processctrl CPU throttling stub
+//
+// CPU throttling relies on /proc for measuring CPU usage and is only
+// available on Linux.
+
+package processctrl
+
+import "time"
+
+// throttleSupported reports whether SetCPULimit is available.
+const throttleSupported = false
+
+// startThrottle is a no-op on platforms without CPU throttling.
+func (p *Process) startThrottle() {}
+
+// releaseThrottle is a no-op on platforms without CPU throttling.
+func (p *Process) releaseThrottle() {}
+
+// readCPUTime is not supported on platforms without CPU throttling.
+func readCPUTime(int, uint64) (time.Duration, error) {
+	return 0, ErrNotSupported
+}
@@ -0,0 +1,99 @@
+package processctrl
+
+import (
+	"fmt"
+	"time"
+)
+
+// PauseFor suspends the process and schedules an automatic Resume after
+// the given duration. See PauseUntil for details.
+//
+// Parameters:
+//   - d: How long the process should stay paused (must be positive)
+//
+// Returns an error if the duration is not positive or the process cannot
+// be paused (see Pause).
+func (p *Process) PauseFor(d time.Duration) error {
+	if d <= 0 {
+		return fmt.Errorf("invalid pause duration: %v", d)
+	}
+	return p.PauseUntil(time.Now().Add(d))
+}
+
+// PauseUntil suspends the process and schedules an automatic Resume at
+// the given time. An EventResumeScheduled event is emitted when the resume
+// is scheduled and EventResumed when it happens.
+//
+// The scheduled resume is canceled, with an EventResumeCanceled event, if
+// the process is resumed manually, continued outside of processctrl,
+// terminated or exits while paused.
+//
+// Parameters:
+//   - t: Time at which the process should be resumed (must be in the future)
+//
+// Returns an error if the time is not in the future or the process cannot
+// be paused (see Pause).
+func (p *Process) PauseUntil(t time.Time) error {
+	if !time.Now().Before(t) {
+		return fmt.Errorf("resume time %v is not in the future", t)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if err := p.pauseLocked(); err != nil {
+		return err
+	}
+
+	// Confirming the pause can take a while, so the delay is computed
+	// only now that the process is paused
+	p.resumeSeq++
+	seq := p.resumeSeq
+	p.resumeTimer = time.AfterFunc(time.Until(t), func() {
+		p.scheduledResume(seq)
+	})
+	p.resumeAt = t
+	p.events.emit(EventResumeScheduled, p.cmd.Process.Pid, t.Format(time.RFC3339))
+	return nil
+}
+
+// ResumeAt returns the time of the pending automatic resume scheduled by
+// PauseFor or PauseUntil. The boolean is false if no resume is pending.
+func (p *Process) ResumeAt() (time.Time, bool) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	if p.resumeTimer == nil {
+		return time.Time{}, false
+	}
+	return p.resumeAt, true
+}
+
+// scheduledResume is run by the resume timer with the sequence number of
+// the schedule it belongs to. It does nothing if that schedule has been
+// canceled or replaced in the meantime.
+func (p *Process) scheduledResume(seq uint64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.resumeTimer == nil || p.resumeSeq != seq {
+		return
+	}
+	p.resumeTimer = nil
+
+	if err := p.resumeLocked("scheduled resume"); err != nil {
+		p.events.emit(EventResumeCanceled, p.cmd.Process.Pid, err.Error())
+	}
+}
+
+// cancelScheduledResume stops a pending automatic resume and emits an
+// EventResumeCanceled event with the given reason.
+// It must be called with p.mu held.
+func (p *Process) cancelScheduledResume(reason string) {
+	if p.resumeTimer == nil {
+		return
+	}
+	p.resumeTimer.Stop()
+	p.resumeTimer = nil
+	p.events.emit(EventResumeCanceled, p.cmd.Process.Pid, reason)
+}
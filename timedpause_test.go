@@ -0,0 +1,133 @@
+package processctrl
+
+import (
+	"os"
+	"runtime"
+	"testing"
+	"time"
+)
+
+// newLongRunning returns a process that runs for several seconds.
+func newLongRunning() *Process {
+	if runtime.GOOS == windowsOS {
+		return New("ping", "-n", "10", "localhost")
+	}
+	return New("sleep", "10")
+}
+
+// expectEvents reads events until all wanted types have been seen in order.
+func expectEvents(t *testing.T, events <-chan Event, want ...EventType) []Event {
+	t.Helper()
+
+	var got []Event
+	timeout := time.After(testTimeout * time.Second)
+	for len(want) > 0 {
+		select {
+		case ev, ok := <-events:
+			if !ok {
+				t.Fatalf("event channel closed while waiting for %v", want[0])
+			}
+			got = append(got, ev)
+			if ev.Type == want[0] {
+				want = want[1:]
+			}
+		case <-timeout:
+			t.Fatalf("timed out waiting for %v event, got %v", want[0], got)
+		}
+	}
+	return got
+}
+
+func TestPauseForResumesAutomatically(t *testing.T) {
+	proc := newLongRunning()
+	startTest(t, proc)
+
+	if err := proc.PauseFor(200 * time.Millisecond); err != nil {
+		t.Fatalf("PauseFor() failed: %v", err)
+	}
+	if !proc.IsPaused() {
+		t.Error("Process should be paused after PauseFor()")
+	}
+	if _, ok := proc.ResumeAt(); !ok {
+		t.Error("ResumeAt() should report the scheduled resume")
+	}
+
+	expectEvents(t, proc.Events(), EventPaused, EventResumeScheduled, EventResumed)
+
+	if proc.IsPaused() {
+		t.Error("Process should not be paused after the scheduled resume")
+	}
+	if _, ok := proc.ResumeAt(); ok {
+		t.Error("ResumeAt() should report no pending resume")
+	}
+}
+
+func TestPauseForCanceledByResume(t *testing.T) {
+	proc := newLongRunning()
+	startTest(t, proc)
+
+	if err := proc.PauseFor(200 * time.Millisecond); err != nil {
+		t.Fatalf("PauseFor() failed: %v", err)
+	}
+	if err := proc.Resume(); err != nil {
+		t.Fatalf("Resume() failed: %v", err)
+	}
+	expectEvents(t, proc.Events(), EventResumeScheduled, EventResumeCanceled, EventResumed)
+
+	// The canceled timer must not resume or pause anything later
+	if err := proc.Pause(); err != nil {
+		t.Fatalf("Pause() failed: %v", err)
+	}
+	time.Sleep(400 * time.Millisecond)
+	if !proc.IsPaused() {
+		t.Error("Canceled scheduled resume resumed the process")
+	}
+}
+
+func TestPauseUntilCanceledByTerminate(t *testing.T) {
+	proc := newLongRunning()
+	startTest(t, proc)
+
+	if err := proc.PauseUntil(time.Now().Add(time.Hour)); err != nil {
+		t.Fatalf("PauseUntil() failed: %v", err)
+	}
+	if err := proc.Terminate(); err != nil {
+		t.Fatalf("Terminate() failed: %v", err)
+	}
+	if _, ok := proc.ResumeAt(); ok {
+		t.Error("Terminate() should cancel the scheduled resume")
+	}
+}
+
+func TestPauseForProcessExitsWhilePaused(t *testing.T) {
+	proc := newLongRunning()
+	startTest(t, proc)
+
+	if err := proc.PauseFor(time.Hour); err != nil {
+		t.Fatalf("PauseFor() failed: %v", err)
+	}
+	if err := proc.Signal(os.Kill); err != nil {
+		t.Fatalf("Signal() failed: %v", err)
+	}
+
+	got := expectEvents(t, proc.Events(), EventResumeCanceled, EventExited)
+	for _, ev := range got {
+		if ev.Type == EventResumed {
+			t.Error("Exited process must not be resumed")
+		}
+	}
+}
+
+func TestPauseForInvalid(t *testing.T) {
+	proc := New("echo", "test")
+
+	if err := proc.PauseFor(0); err == nil {
+		t.Error("PauseFor(0) should fail")
+	}
+	if err := proc.PauseUntil(time.Now().Add(-time.Second)); err == nil {
+		t.Error("PauseUntil() with a past time should fail")
+	}
+	if err := proc.PauseFor(time.Second); err == nil {
+		t.Error("PauseFor() should fail on non-running process")
+	}
+}
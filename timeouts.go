@@ -0,0 +1,163 @@
+package processctrl
+
+import (
+	"fmt"
+	"time"
+)
+
+// timeoutCheckInterval is how often the limits set with SetTimeouts are
+// checked.
+const timeoutCheckInterval = 100 * time.Millisecond
+
+// Timeouts are limits on how long a process may run. Unlike a context
+// deadline, which covers total wall-clock time, they exclude the time the
+// process spends paused. A zero value disables the corresponding limit.
+type Timeouts struct {
+	MaxActive time.Duration // Maximum runtime, excluding time spent paused
+	Idle      time.Duration // Maximum time without a line on stdout or stderr, excluding time spent paused
+	CPU       time.Duration // Maximum CPU time (user and system) of the process itself
+}
+
+// SetTimeouts limits the active runtime, idle time and CPU time of the
+// process, replacing any previous limits. It can be called before or while
+// the process is running; Timeouts{} removes all limits.
+//
+// When a limit is exceeded the process is stopped gracefully, as with
+// Terminate, and the Reason of its exit result is ExitMaxRuntime,
+// ExitIdleTimeout or ExitCPUBudget. Limits are checked every 100ms.
+//
+// The active runtime is counted from the start of the process. The idle
+// time is counted from the last line of output, or from the call to
+// SetTimeouts if the process has not produced output since. Output that
+// does not end with a newline is not seen until the line is complete.
+//
+// The CPU budget is only supported on Linux, since CPU time is read from
+// /proc; setting it on other platforms returns ErrNotSupported.
+//
+// Returns an error if a limit is negative or not supported.
+func (p *Process) SetTimeouts(t Timeouts) error {
+	if t.MaxActive < 0 || t.Idle < 0 || t.CPU < 0 {
+		return fmt.Errorf("invalid timeouts: %+v", t)
+	}
+	if t.CPU > 0 && !statsSupported {
+		return ErrNotSupported
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.timeouts = t
+	p.timeoutSeq++
+	p.startTimeouts()
+	return nil
+}
+
+// ActiveTime returns how long the process has been running, excluding
+// the time it spent paused. It returns 0 if the process has not been
+// started.
+func (p *Process) ActiveTime() time.Duration {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	if p.startedAt.IsZero() {
+		return 0
+	}
+	return p.activeTime(time.Now())
+}
+
+// activeTime returns the active runtime at now.
+// It must be called with p.mu held.
+func (p *Process) activeTime(now time.Time) time.Duration {
+	active := now.Sub(p.startedAt) - p.pausedTotal
+	if p.paused {
+		active -= now.Sub(p.pausedAt)
+	}
+	return active
+}
+
+// setPaused updates the paused state and accounts for the time spent
+// paused. It must be called with p.mu held.
+func (p *Process) setPaused(paused bool) {
+	if paused == p.paused {
+		return
+	}
+	now := time.Now()
+	if paused {
+		p.pausedAt = now
+	} else {
+		p.pausedTotal += now.Sub(p.pausedAt)
+	}
+	p.paused = paused
+}
+
+// startTimeouts starts the timeout loop if any limit is set.
+// It must be called with p.mu held.
+func (p *Process) startTimeouts() {
+	if p.timeouts == (Timeouts{}) || !p.running {
+		return
+	}
+	go p.runTimeouts(p.timeouts, p.timeoutSeq, p.programPID, p.startTicks)
+}
+
+// runTimeouts is the timeout loop. It runs until the limits are replaced,
+// one of them is exceeded or the process exits.
+func (p *Process) runTimeouts(t Timeouts, seq uint64, pid int, startTicks uint64) {
+	ticker := time.NewTicker(timeoutCheckInterval)
+	defer ticker.Stop()
+
+	var idle time.Duration
+	last := time.Now()
+	lines := p.outputLines.Load()
+
+	for {
+		select {
+		case <-p.exited:
+			return
+		case <-ticker.C:
+		}
+
+		now := time.Now()
+		p.mu.RLock()
+		if p.timeoutSeq != seq || !p.running {
+			p.mu.RUnlock()
+			return
+		}
+		paused := p.paused
+		active := p.activeTime(now)
+		p.mu.RUnlock()
+
+		if n := p.outputLines.Load(); n != lines {
+			lines = n
+			idle = 0
+		} else if !paused {
+			idle += now.Sub(last)
+		}
+		last = now
+
+		reason := ExitNormal
+		switch {
+		case t.MaxActive > 0 && active >= t.MaxActive:
+			reason = ExitMaxRuntime
+		case t.Idle > 0 && idle >= t.Idle:
+			reason = ExitIdleTimeout
+		case t.CPU > 0:
+			if cpu, err := readCPUTime(pid, startTicks); err == nil && cpu >= t.CPU {
+				reason = ExitCPUBudget
+			}
+		}
+		if reason == ExitNormal {
+			continue
+		}
+
+		p.mu.Lock()
+		current := p.timeoutSeq == seq && p.running
+		if current {
+			p.setExitReason(reason, "")
+		}
+		p.mu.Unlock()
+		if current {
+			_ = p.Terminate() // Ignore error - process might have exited
+		}
+		return
+	}
+}
@@ -0,0 +1,78 @@
+//go:build linux || darwin
+
+package processctrl
+
+import (
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestMaxActiveExcludesPausedTime(t *testing.T) {
+	proc := New("sh", "-c", "exec sleep 10")
+	if err := proc.SetTimeouts(Timeouts{MaxActive: 400 * time.Millisecond}); err != nil {
+		t.Fatalf("SetTimeouts() failed: %v", err)
+	}
+	startTest(t, proc)
+
+	if err := proc.Pause(); err != nil {
+		t.Fatalf("Pause() failed: %v", err)
+	}
+	time.Sleep(600 * time.Millisecond)
+	if !proc.IsRunning() {
+		t.Fatal("Time spent paused should not count against MaxActive")
+	}
+	if active := proc.ActiveTime(); active >= 400*time.Millisecond {
+		t.Errorf("Active time should exclude the pause, got %v", active)
+	}
+	if err := proc.Resume(); err != nil {
+		t.Fatalf("Resume() failed: %v", err)
+	}
+
+	result := waitResult(t, proc)
+	if result.Reason != ExitMaxRuntime {
+		t.Errorf("Expected reason %v, got %v", ExitMaxRuntime, result.Reason)
+	}
+}
+
+func TestIdleTimeout(t *testing.T) {
+	start := time.Now()
+	proc := New("sh", "-c", "echo a; sleep 0.3; echo b >&2; exec sleep 10")
+	if err := proc.SetTimeouts(Timeouts{Idle: 500 * time.Millisecond}); err != nil {
+		t.Fatalf("SetTimeouts() failed: %v", err)
+	}
+	startTest(t, proc)
+
+	result := waitResult(t, proc)
+	if result.Reason != ExitIdleTimeout {
+		t.Errorf("Expected reason %v, got %v", ExitIdleTimeout, result.Reason)
+	}
+	// Output on stderr after 0.3s resets the idle timer
+	if elapsed := time.Since(start); elapsed < 800*time.Millisecond {
+		t.Errorf("Process was stopped after %v, before being idle for 500ms", elapsed)
+	}
+}
+
+func TestCPUBudget(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("CPU time is measured through /proc on Linux only")
+	}
+
+	proc := New("sh", "-c", "while :; do :; done")
+	if err := proc.SetTimeouts(Timeouts{CPU: 300 * time.Millisecond}); err != nil {
+		t.Fatalf("SetTimeouts() failed: %v", err)
+	}
+	startTest(t, proc)
+
+	result := waitResult(t, proc)
+	if result.Reason != ExitCPUBudget {
+		t.Errorf("Expected reason %v, got %v", ExitCPUBudget, result.Reason)
+	}
+}
+
+func TestSetTimeoutsInvalid(t *testing.T) {
+	proc := New("sleep", "1")
+	if err := proc.SetTimeouts(Timeouts{Idle: -time.Second}); err == nil {
+		t.Error("SetTimeouts() should fail with a negative timeout")
+	}
+}
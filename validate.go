@@ -0,0 +1,198 @@
+package processctrl
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
+)
+
+// Errors returned by Validate and Run, wrapped with details, that explain
+// why a program cannot be started.
+var (
+	// ErrNotFound means the program or the working directory does not
+	// exist, or the program is not in the search path.
+	ErrNotFound = errors.New("not found")
+	// ErrPermission means the program is not executable, or the working
+	// directory cannot be entered.
+	ErrPermission = errors.New("permission denied")
+	// ErrBadInterpreter means the interpreter in the shebang line of a
+	// script, or the program it runs through env, does not exist or is
+	// not executable.
+	ErrBadInterpreter = errors.New("bad interpreter")
+)
+
+// SetDir sets the working directory of the process, or the controller's
+// working directory if dir is empty. A relative program path containing a
+// separator is resolved relative to it. It must be called before the
+// process is started.
+//
+// Returns an error if the process is running.
+func (p *Process) SetDir(dir string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.running {
+		return fmt.Errorf("cannot change the working directory of a running process")
+	}
+	p.dir = dir
+	return nil
+}
+
+// SetSearchPath sets the directories searched for a program name without
+// a path separator, or restores the search of the PATH environment
+// variable if dirs is nil. It must be called before the process is
+// started.
+//
+// Returns an error if a directory is not absolute or the process is
+// running.
+func (p *Process) SetSearchPath(dirs []string) error {
+	if dirs != nil {
+		dirs = append([]string{}, dirs...)
+		for _, dir := range dirs {
+			if !filepath.IsAbs(dir) {
+				return fmt.Errorf("search path directories must be absolute: %s", dir)
+			}
+		}
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.running {
+		return fmt.Errorf("cannot change the search path of a running process")
+	}
+	p.searchPath = dirs
+	return nil
+}
+
+// Validate checks that the process can be started, without starting it:
+// the working directory exists and can be entered, the program is found
+// in the search path, exists and is executable, and the interpreter of a
+// script with a shebang line, as well as the program it runs through
+// env, is available. Programs executed from memory are not checked.
+//
+// Validate cannot foresee every problem, for example missing shared
+// libraries, but Run reports the same errors if the program fails to
+// start for one of these reasons.
+//
+// Returns an error wrapping ErrNotFound, ErrPermission or
+// ErrBadInterpreter describing the first problem found, or nil.
+func (p *Process) Validate() error {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	_, err := p.validate()
+	return err
+}
+
+// validate checks that the process can be started and returns the path
+// of the program.
+// It must be called with p.mu held.
+func (p *Process) validate() (string, error) {
+	if p.dir != "" {
+		info, err := os.Stat(p.dir)
+		switch {
+		case errors.Is(err, fs.ErrNotExist):
+			return "", fmt.Errorf("%w: working directory %s", ErrNotFound, p.dir)
+		case errors.Is(err, fs.ErrPermission):
+			return "", fmt.Errorf("%w: working directory %s", ErrPermission, p.dir)
+		case err != nil:
+			return "", fmt.Errorf("invalid working directory: %w", err)
+		case !info.IsDir():
+			return "", fmt.Errorf("%w: working directory %s is not a directory", ErrNotFound, p.dir)
+		}
+	}
+	if p.image != nil {
+		return "", nil
+	}
+
+	path, err := p.resolveProgram()
+	if err != nil {
+		return "", err
+	}
+	if err := checkInterpreter(path); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// resolveProgram returns the path of the program, found in the search
+// path if its name has no separator.
+// It must be called with p.mu held.
+func (p *Process) resolveProgram() (string, error) {
+	if strings.ContainsAny(p.program, `/\`) || filepath.IsAbs(p.program) {
+		path := p.program
+		if p.dir != "" && !filepath.IsAbs(path) {
+			path = filepath.Join(p.dir, path)
+		}
+		if _, err := lookPath(path); err != nil {
+			return "", err
+		}
+		return p.program, nil
+	}
+	if p.searchPath == nil {
+		return lookPath(p.program)
+	}
+
+	var permErr error
+	for _, dir := range p.searchPath {
+		path, err := lookPath(filepath.Join(dir, p.program))
+		if err == nil {
+			return path, nil
+		}
+		if errors.Is(err, ErrPermission) && permErr == nil {
+			permErr = err
+		}
+	}
+	if permErr != nil {
+		return "", permErr
+	}
+	return "", fmt.Errorf("%w: %s in %s", ErrNotFound, p.program, strings.Join(p.searchPath, string(filepath.ListSeparator)))
+}
+
+// lookPath resolves file like exec.LookPath and classifies its errors.
+func lookPath(file string) (string, error) {
+	path, err := exec.LookPath(file)
+	if err == nil {
+		return path, nil
+	}
+	// Executing a directory fails with EACCES
+	if errors.Is(err, fs.ErrPermission) || errors.Is(err, syscall.EISDIR) {
+		return "", fmt.Errorf("%w: %s is not executable", ErrPermission, file)
+	}
+	if errors.Is(err, exec.ErrNotFound) || errors.Is(err, fs.ErrNotExist) || errors.Is(err, exec.ErrDot) {
+		return "", fmt.Errorf("%w: %w", ErrNotFound, err)
+	}
+	return "", err
+}
+
+// prepareProgram applies the working directory and search path to the
+// command.
+// It must be called with p.mu held before the command is started.
+func (p *Process) prepareProgram() {
+	p.cmd.Dir = p.dir
+	if p.searchPath == nil || strings.ContainsAny(p.program, `/\`) || filepath.IsAbs(p.program) {
+		return
+	}
+	path, err := p.resolveProgram()
+	if err != nil {
+		p.cmd.Err = err
+		return
+	}
+	p.cmd.Path, p.cmd.Err = path, nil
+}
+
+// startError returns the error for a program that failed to start with
+// err, with the cause found by validate if there is one.
+// It must be called with p.mu held.
+func (p *Process) startError(err error) error {
+	if _, verr := p.validate(); verr != nil {
+		return fmt.Errorf("failed to start process: %w", verr)
+	}
+	return fmt.Errorf("failed to start process: %w", err)
+}
@@ -0,0 +1,63 @@
+//go:build linux || darwin
+
+// Package processctrl Unix program validation
+//
+// Scripts are started by the kernel through the interpreter in their
+// shebang line, so a missing interpreter fails the start like a missing
+// program. This file checks the interpreter before the script runs.
+
+package processctrl
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// maxShebangLen is the longest shebang line that is read.
+const maxShebangLen = 256
+
+// checkInterpreter checks the interpreter of the script at path and, if
+// the interpreter is env, the program it runs. Files that cannot be read
+// are not checked.
+func checkInterpreter(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+
+	head := make([]byte, maxShebangLen)
+	n, err := io.ReadFull(f, head)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return nil
+	}
+	line, _, _ := bytes.Cut(head[:n], []byte("\n"))
+	fields := shebang(string(line))
+	if len(fields) == 0 {
+		return nil
+	}
+
+	interpreter := fields[0]
+	if _, err := lookPath(interpreter); err != nil {
+		return fmt.Errorf("%w: %s: %w", ErrBadInterpreter, path, err)
+	}
+	if filepath.Base(interpreter) != "env" {
+		return nil
+	}
+	// env runs the first argument that is not an option or assignment
+	for _, field := range fields[1:] {
+		if strings.HasPrefix(field, "-") || strings.Contains(field, "=") {
+			continue
+		}
+		if _, err := lookPath(field); err != nil {
+			return fmt.Errorf("%w: %s: %w", ErrBadInterpreter, path, err)
+		}
+		break
+	}
+	return nil
+}
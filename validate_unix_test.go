@@ -0,0 +1,120 @@
+//go:build linux || darwin
+
+package processctrl
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeProgram writes an executable file with the given content and mode.
+func writeProgram(t *testing.T, dir, name, content string, mode os.FileMode) string {
+	t.Helper()
+
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(content), mode); err != nil {
+		t.Fatalf("Failed to write %s: %v", path, err)
+	}
+	return path
+}
+
+// checkStartError checks that Validate and Run fail with target.
+func checkStartError(t *testing.T, proc *Process, target error) {
+	t.Helper()
+
+	if err := proc.Validate(); !errors.Is(err, target) {
+		t.Errorf("Validate() = %v, want %v", err, target)
+	}
+	if _, _, err := proc.Run(); !errors.Is(err, target) {
+		t.Errorf("Run() error = %v, want %v", err, target)
+	}
+}
+
+func TestValidateNotFound(t *testing.T) {
+	checkStartError(t, New("no-such-program-xyz"), ErrNotFound)
+	checkStartError(t, New(filepath.Join(t.TempDir(), "missing")), ErrNotFound)
+}
+
+func TestValidatePermission(t *testing.T) {
+	dir := t.TempDir()
+	checkStartError(t, New(writeProgram(t, dir, "noexec", "#!/bin/sh\n", 0o644)), ErrPermission)
+	checkStartError(t, New(dir), ErrPermission)
+}
+
+func TestValidateBadInterpreter(t *testing.T) {
+	dir := t.TempDir()
+	checkStartError(t, New(writeProgram(t, dir, "missing", "#!/no/such/interpreter\n", 0o755)), ErrBadInterpreter)
+
+	// env starts, so only Validate notices the missing program
+	proc := New(writeProgram(t, dir, "env", "#!/usr/bin/env -S no-such-interpreter-xyz -u\n", 0o755))
+	if err := proc.Validate(); !errors.Is(err, ErrBadInterpreter) {
+		t.Errorf("Validate() = %v, want ErrBadInterpreter", err)
+	}
+
+	for _, content := range []string{"#!/bin/sh\necho ok\n", "#!/usr/bin/env sh\necho ok\n", "echo ok\n"} {
+		if err := New(writeProgram(t, dir, "valid", content, 0o755)).Validate(); err != nil {
+			t.Errorf("Validate() of %q = %v, want nil", content, err)
+		}
+	}
+}
+
+func TestValidateDir(t *testing.T) {
+	dir := t.TempDir()
+	file := writeProgram(t, dir, "file", "", 0o644)
+	for _, bad := range []string{filepath.Join(dir, "missing"), file} {
+		proc := New("true")
+		if err := proc.SetDir(bad); err != nil {
+			t.Fatalf("SetDir() failed: %v", err)
+		}
+		checkStartError(t, proc, ErrNotFound)
+	}
+
+	// A relative program path is resolved in the working directory
+	writeProgram(t, dir, "prog", "#!/bin/sh\npwd\n", 0o755)
+	proc := New("./prog")
+	if err := proc.SetDir(dir); err != nil {
+		t.Fatalf("SetDir() failed: %v", err)
+	}
+	if err := proc.Validate(); err != nil {
+		t.Fatalf("Validate() = %v, want nil", err)
+	}
+	lines, _ := runCollect(t, proc)
+	want, _ := filepath.EvalSymlinks(dir)
+	if len(lines) != 1 || (lines[0] != dir && lines[0] != want) {
+		t.Errorf("Output = %q, want %s", lines, dir)
+	}
+}
+
+func TestSetSearchPath(t *testing.T) {
+	dir := t.TempDir()
+	writeProgram(t, dir, "tool", "#!/bin/sh\necho from search path\n", 0o755)
+	writeProgram(t, dir, "noexec", "#!/bin/sh\n", 0o644)
+
+	proc := New("tool")
+	if err := proc.SetSearchPath([]string{filepath.Join(dir, "missing"), dir}); err != nil {
+		t.Fatalf("SetSearchPath() failed: %v", err)
+	}
+	lines, _ := runCollect(t, proc)
+	if strings.Join(lines, "\n") != "from search path" {
+		t.Errorf("Output = %q, want from search path", lines)
+	}
+
+	proc = New("sh")
+	if err := proc.SetSearchPath([]string{dir}); err != nil {
+		t.Fatalf("SetSearchPath() failed: %v", err)
+	}
+	checkStartError(t, proc, ErrNotFound)
+
+	proc = New("noexec")
+	if err := proc.SetSearchPath([]string{dir}); err != nil {
+		t.Fatalf("SetSearchPath() failed: %v", err)
+	}
+	checkStartError(t, proc, ErrPermission)
+
+	if err := New("tool").SetSearchPath([]string{"relative"}); err == nil {
+		t.Error("SetSearchPath() with a relative directory succeeded")
+	}
+}
@@ -0,0 +1,13 @@
+//go:build windows
+
+// Package processctrl Windows program validation
+//
+// Windows does not run scripts through shebang lines, so there is no
+// interpreter to check.
+
+package processctrl
+
+// checkInterpreter is a no-op on Windows.
+func checkInterpreter(path string) error {
+	return nil
+}
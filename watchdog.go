@@ -0,0 +1,303 @@
+package processctrl
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"syscall"
+	"time"
+)
+
+// defaultWatchdogInterval is how often the watchdog samples the process if
+// no interval is configured.
+const defaultWatchdogInterval = time.Second
+
+// Metric identifies the value of a Stats snapshot that a watchdog rule
+// checks.
+type Metric int
+
+const (
+	MetricCPUPercent Metric = iota // CPU usage in percent of one CPU
+	MetricCPUTime                  // Total CPU time in seconds
+	MetricRSS                      // Resident set size in bytes
+	MetricVSZ                      // Virtual memory size in bytes
+	MetricThreads                  // Number of threads
+	MetricFDs                      // Number of open file descriptors
+	MetricReadBytes                // Bytes read through system calls
+	MetricWriteBytes               // Bytes written through system calls
+	MetricProcesses                // Number of processes in the tree
+)
+
+// String returns a human-readable name for the metric.
+func (m Metric) String() string {
+	switch m {
+	case MetricCPUPercent:
+		return "cpu"
+	case MetricCPUTime:
+		return "cpu-time"
+	case MetricRSS:
+		return "rss"
+	case MetricVSZ:
+		return "vsz"
+	case MetricThreads:
+		return "threads"
+	case MetricFDs:
+		return "fds"
+	case MetricReadBytes:
+		return "read-bytes"
+	case MetricWriteBytes:
+		return "write-bytes"
+	case MetricProcesses:
+		return "processes"
+	default:
+		return fmt.Sprintf("Metric(%d)", int(m))
+	}
+}
+
+// value returns the metric from a snapshot.
+func (m Metric) value(s *Stats) float64 {
+	switch m {
+	case MetricCPUPercent:
+		return s.CPUPercent
+	case MetricCPUTime:
+		return s.CPUTime().Seconds()
+	case MetricRSS:
+		return float64(s.RSS)
+	case MetricVSZ:
+		return float64(s.VSZ)
+	case MetricThreads:
+		return float64(s.Threads)
+	case MetricFDs:
+		return float64(s.FDs)
+	case MetricReadBytes:
+		return float64(s.ReadBytes)
+	case MetricWriteBytes:
+		return float64(s.WriteBytes)
+	case MetricProcesses:
+		return float64(s.Processes)
+	default:
+		return 0
+	}
+}
+
+// format formats a value of the metric for messages.
+func (m Metric) format(v float64) string {
+	if m == MetricCPUPercent || m == MetricCPUTime {
+		return fmt.Sprintf("%.1f", v)
+	}
+	return fmt.Sprintf("%.0f", v)
+}
+
+// WatchdogAction is what the watchdog does when a rule fires.
+type WatchdogAction int
+
+const (
+	// ActionEvent only emits an EventWatchdog event.
+	ActionEvent WatchdogAction = iota
+	// ActionPause pauses the process.
+	ActionPause
+	// ActionTerminate stops the process gracefully, as Terminate does.
+	ActionTerminate
+	// ActionKill kills the process immediately.
+	ActionKill
+)
+
+// String returns a human-readable name for the action.
+func (a WatchdogAction) String() string {
+	switch a {
+	case ActionEvent:
+		return "event"
+	case ActionPause:
+		return "pause"
+	case ActionTerminate:
+		return "terminate"
+	case ActionKill:
+		return "kill"
+	default:
+		return fmt.Sprintf("WatchdogAction(%d)", int(a))
+	}
+}
+
+// WatchdogRule is a condition on the resource usage of a process and the
+// action taken when it holds. For example, a rule with Metric MetricRSS,
+// Above 2 GiB, For 30s and Action ActionTerminate stops a process whose
+// resident memory has exceeded 2 GiB for 30 seconds.
+//
+// A rule fires once when its condition has held for the given duration,
+// and fires again only after the condition has cleared in between.
+type WatchdogRule struct {
+	Name   string         // Identifies the rule in events and exit results
+	Metric Metric         // Value to check
+	Above  float64        // The condition holds while the value exceeds this
+	For    time.Duration  // How long the condition must hold; 0 fires on the first sample
+	Action WatchdogAction // What to do when the rule fires
+}
+
+// Watchdog configures the enforcement of resource rules on a process.
+// Every Interval a Stats snapshot is taken and all rules are evaluated.
+type Watchdog struct {
+	Interval time.Duration  // Sampling interval; defaults to 1s
+	Tree     bool           // Evaluate rules on the whole process tree as with TreeStats
+	Rules    []WatchdogRule // Rules to enforce
+}
+
+// RuleFiring records a watchdog rule that fired.
+type RuleFiring struct {
+	Rule   string         // Name of the rule
+	Action WatchdogAction // Action taken
+	Value  float64        // Value of the metric when the rule fired
+	Time   time.Time      // Time the rule fired
+}
+
+// SetWatchdog enforces the given resource rules on the process, replacing
+// any previous watchdog, or removes the watchdog if w is nil. It can be
+// called before or while the process is running.
+//
+// Every firing emits an EventWatchdog event and is recorded in the
+// Firings of the exit result. If a rule terminates or kills the process,
+// the exit result's Reason is ExitWatchdog and Rule holds its name.
+//
+// Only Linux is supported, since rules are evaluated on Stats snapshots;
+// other platforms return ErrNotSupported.
+//
+// Returns an error if a rule is invalid or watchdogs are not supported.
+func (p *Process) SetWatchdog(w *Watchdog) error {
+	if w == nil {
+		p.mu.Lock()
+		p.watchdog = nil
+		p.watchdogSeq++
+		p.mu.Unlock()
+		return nil
+	}
+
+	cfg := *w
+	cfg.Rules = append([]WatchdogRule(nil), w.Rules...)
+	if cfg.Interval < 0 {
+		return fmt.Errorf("invalid watchdog interval: %v", cfg.Interval)
+	}
+	if cfg.Interval == 0 {
+		cfg.Interval = defaultWatchdogInterval
+	}
+	for _, r := range cfg.Rules {
+		if r.Name == "" {
+			return fmt.Errorf("watchdog rule has no name")
+		}
+		if r.Metric < MetricCPUPercent || r.Metric > MetricProcesses {
+			return fmt.Errorf("watchdog rule %q has invalid metric %v", r.Name, r.Metric)
+		}
+		if r.Action < ActionEvent || r.Action > ActionKill {
+			return fmt.Errorf("watchdog rule %q has invalid action %v", r.Name, r.Action)
+		}
+		if r.For < 0 || math.IsNaN(r.Above) {
+			return fmt.Errorf("watchdog rule %q has invalid condition", r.Name)
+		}
+	}
+	if !statsSupported {
+		return ErrNotSupported
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.watchdog = &cfg
+	p.watchdogSeq++
+	p.startWatchdog()
+	return nil
+}
+
+// startWatchdog starts the watchdog loop if a watchdog is set.
+// It must be called with p.mu held.
+func (p *Process) startWatchdog() {
+	if p.watchdog == nil || !p.running {
+		return
+	}
+	go p.runWatchdog(p.watchdog, p.watchdogSeq)
+}
+
+// runWatchdog is the watchdog loop. It runs until the watchdog is replaced
+// or removed, or the process exits. Samples that cannot be read for other
+// reasons are skipped.
+func (p *Process) runWatchdog(w *Watchdog, seq uint64) {
+	ticker := time.NewTicker(w.Interval)
+	defer ticker.Stop()
+
+	var prev cpuSample
+	since := make([]time.Time, len(w.Rules)) // start of the current violation
+	fired := make([]bool, len(w.Rules))
+
+	for {
+		select {
+		case <-p.exited:
+			return
+		case <-ticker.C:
+		}
+
+		p.mu.RLock()
+		current := p.watchdogSeq == seq
+		p.mu.RUnlock()
+		if !current {
+			return
+		}
+
+		s, err := p.readStats(w.Tree, &prev)
+		if errors.Is(err, os.ErrProcessDone) || errors.Is(err, syscall.ESRCH) || errors.Is(err, ErrNotSupported) {
+			return
+		}
+		if err != nil {
+			// A failed read, e.g. during an exec, skips only this sample
+			continue
+		}
+
+		for i, r := range w.Rules {
+			value := r.Metric.value(s)
+			if value <= r.Above {
+				since[i] = time.Time{}
+				fired[i] = false
+				continue
+			}
+			if since[i].IsZero() {
+				since[i] = s.Time
+			}
+			if !fired[i] && s.Time.Sub(since[i]) >= r.For {
+				fired[i] = true
+				if !p.fireRule(r, value, seq) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// fireRule records the firing of a rule and carries out its action. It
+// reports false if the watchdog should stop because the process is being
+// stopped or the watchdog was replaced.
+func (p *Process) fireRule(r WatchdogRule, value float64, seq uint64) bool {
+	p.mu.Lock()
+	if p.watchdogSeq != seq || !p.running {
+		p.mu.Unlock()
+		return false
+	}
+	p.firings = append(p.firings, RuleFiring{Rule: r.Name, Action: r.Action, Value: value, Time: time.Now()})
+	p.events.emit(EventWatchdog, p.cmd.Process.Pid,
+		fmt.Sprintf("rule %q: %s %s above %s, action %s",
+			r.Name, r.Metric, r.Metric.format(value), r.Metric.format(r.Above), r.Action))
+	if r.Action == ActionTerminate || r.Action == ActionKill {
+		p.setExitReason(ExitWatchdog, r.Name)
+	}
+	p.mu.Unlock()
+
+	switch r.Action {
+	case ActionPause:
+		if !p.IsPaused() {
+			_ = p.Pause() // Ignore error - process might have exited or been paused concurrently
+		}
+	case ActionTerminate:
+		_ = p.Terminate() // Ignore error - process might have exited
+		return false
+	case ActionKill:
+		_ = p.killWithSignal(0, false) // Ignore error - process might have exited
+		return false
+	}
+	return true
+}
@@ -0,0 +1,140 @@
+//go:build linux
+
+package processctrl
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWatchdogTerminate(t *testing.T) {
+	proc := New("sleep", "10")
+	err := proc.SetWatchdog(&Watchdog{
+		Interval: 20 * time.Millisecond,
+		Rules: []WatchdogRule{
+			{Name: "too-many-threads", Metric: MetricThreads, Above: 0, For: 50 * time.Millisecond, Action: ActionTerminate},
+		},
+	})
+	if err != nil {
+		t.Fatalf("SetWatchdog() failed: %v", err)
+	}
+
+	stdout, stderr, err := proc.Run()
+	if err != nil {
+		t.Fatalf("Run() failed: %v", err)
+	}
+	go func() {
+		for range stderr {
+		}
+	}()
+	go func() {
+		for range stdout {
+		}
+	}()
+	t.Cleanup(func() { _ = proc.Kill() })
+
+	events := expectEvents(t, proc.Events(), EventWatchdog, EventExited)
+	for _, ev := range events {
+		if ev.Type == EventWatchdog && !strings.Contains(ev.Message, "too-many-threads") {
+			t.Errorf("Watchdog event should name the rule, got %q", ev.Message)
+		}
+	}
+
+	result, err := proc.Result()
+	if err != nil {
+		t.Fatalf("Result() failed: %v", err)
+	}
+	if result.Reason != ExitWatchdog || result.Rule != "too-many-threads" {
+		t.Errorf("Expected exit by watchdog rule too-many-threads, got %v %q", result.Reason, result.Rule)
+	}
+	if len(result.Firings) != 1 || result.Firings[0].Action != ActionTerminate {
+		t.Errorf("Expected one terminate firing, got %+v", result.Firings)
+	}
+}
+
+func TestWatchdogPauseOnCPU(t *testing.T) {
+	proc := New("sh", "-c", "while :; do :; done")
+	startTest(t, proc)
+
+	err := proc.SetWatchdog(&Watchdog{
+		Interval: 50 * time.Millisecond,
+		Rules: []WatchdogRule{
+			{Name: "busy", Metric: MetricCPUPercent, Above: 30, For: 100 * time.Millisecond, Action: ActionPause},
+		},
+	})
+	if err != nil {
+		t.Fatalf("SetWatchdog() failed: %v", err)
+	}
+
+	expectEvents(t, proc.Events(), EventWatchdog, EventPaused)
+	if !proc.IsPaused() {
+		t.Error("Process should be paused by the watchdog")
+	}
+	if !proc.IsRunning() {
+		t.Error("Pause action should not stop the process")
+	}
+}
+
+func TestWatchdogEventOnly(t *testing.T) {
+	proc := newLongRunning()
+	startTest(t, proc)
+
+	err := proc.SetWatchdog(&Watchdog{
+		Interval: 20 * time.Millisecond,
+		Rules: []WatchdogRule{
+			{Name: "fds", Metric: MetricFDs, Above: 0, Action: ActionEvent},
+		},
+	})
+	if err != nil {
+		t.Fatalf("SetWatchdog() failed: %v", err)
+	}
+
+	expectEvents(t, proc.Events(), EventWatchdog)
+
+	// The rule stays violated, but fires only once
+	time.Sleep(100 * time.Millisecond)
+	if err := proc.SetWatchdog(nil); err != nil {
+		t.Fatalf("SetWatchdog(nil) failed: %v", err)
+	}
+	if !proc.IsRunning() {
+		t.Fatal("Event action should not stop the process")
+	}
+	if err := proc.Kill(); err != nil {
+		t.Fatalf("Kill() failed: %v", err)
+	}
+
+	result, err := proc.Result()
+	if err != nil {
+		t.Fatalf("Result() failed: %v", err)
+	}
+	if result.Reason != ExitNormal {
+		t.Errorf("Expected reason %v, got %v", ExitNormal, result.Reason)
+	}
+	if len(result.Firings) != 1 || result.Firings[0].Rule != "fds" {
+		t.Errorf("Expected a single firing of rule fds, got %+v", result.Firings)
+	}
+}
+
+func TestSetWatchdogInvalid(t *testing.T) {
+	proc := New("sleep", "1")
+
+	tests := []struct {
+		name string
+		w    *Watchdog
+	}{
+		{"negative interval", &Watchdog{Interval: -time.Second}},
+		{"unnamed rule", &Watchdog{Rules: []WatchdogRule{{Metric: MetricRSS}}}},
+		{"invalid metric", &Watchdog{Rules: []WatchdogRule{{Name: "x", Metric: Metric(99)}}}},
+		{"invalid action", &Watchdog{Rules: []WatchdogRule{{Name: "x", Action: WatchdogAction(99)}}}},
+		{"negative duration", &Watchdog{Rules: []WatchdogRule{{Name: "x", For: -time.Second}}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := proc.SetWatchdog(tt.w); err == nil {
+				t.Error("SetWatchdog() should fail")
+			}
+		})
+	}
+}